@@ -9,7 +9,10 @@ import (
 
 	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/config"
 	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/database"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/middleware"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/repository"
 	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/routes"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/service"
 	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/utils"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -116,12 +119,15 @@ func main() {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"}, // For development. In production, specify your frontend origin.
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Operation-Batch-ID"}, // Added X-Operation-Batch-ID
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Operation-Batch-ID", middleware.RequestIDHeader}, // Added X-Operation-Batch-ID
+		ExposeHeaders:    []string{"Content-Length", middleware.RequestIDHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
+	// Tag every request with an ID, recorded on the history entries it writes
+	r.Use(middleware.RequestID())
+
 	// Setup routes
 	// Repository and service initialization is now handled within SetupRoutes or passed to it.
 	// For this structure, SetupRoutes takes care of it using the global database.DB.
@@ -138,6 +144,13 @@ func main() {
 	// Setup backup manager
 	backupManager := utils.NewBackupManager(cfg)
 
+	// Repositories used by the scheduled jobs below
+	loteRepository := repository.NewLoteRepository(database.DB)
+	productRepository := repository.NewProductRepository(database.DB, loteRepository)
+	historyRepository := repository.NewHistoryRepository(database.DB)
+	stockSnapshotRepository := repository.NewStockSnapshotRepository(database.DB)
+	historyArchiveRepository := repository.NewHistoryArchiveRepository(database.DB)
+
 	// Set up cron job for weekly backups (Sunday at 3:00 AM)
 	c := cron.New()
 	_, err := c.AddFunc("0 3 * * 0", func() {
@@ -152,10 +165,115 @@ func main() {
 	if err != nil {
 		log.Printf("Erro ao configurar agendamento de backup: %v", err)
 	} else {
-		c.Start()
 		log.Println("Agendamento de backup configurado")
 	}
 
+	// Set up cron job for daily stock snapshots (every day at 23:55), used by point-in-time reports
+	stockReportService := service.NewStockReportService(historyRepository, productRepository, stockSnapshotRepository)
+	_, err = c.AddFunc("55 23 * * *", func() {
+		log.Println("Registrando snapshot diário de estoque...")
+		takenAt, err := stockReportService.TakeSnapshotForAllUsers()
+		if err != nil {
+			log.Printf("Erro ao registrar snapshot de estoque: %v", err)
+		} else {
+			log.Printf("Snapshot de estoque registrado em %s", takenAt.Format(time.RFC3339))
+		}
+	})
+	if err != nil {
+		log.Printf("Erro ao configurar agendamento de snapshot de estoque: %v", err)
+	} else {
+		log.Println("Agendamento de snapshot de estoque configurado")
+	}
+
+	// Set up cron job for the nightly consistency check (every day at 2:00 AM); discrepancies are only logged
+	consistencyService := service.NewConsistencyService(productRepository, historyRepository, historyArchiveRepository, stockSnapshotRepository, database.DB)
+	_, err = c.AddFunc("0 2 * * *", func() {
+		log.Println("Executando verificação de consistência do estoque...")
+		reports, err := consistencyService.CheckAllUsers()
+		if err != nil {
+			log.Printf("Erro ao verificar consistência do estoque: %v", err)
+			return
+		}
+		for userID, report := range reports {
+			for _, d := range report.Discrepancies {
+				log.Printf("Inconsistência (usuário %d) no produto %s (%s): %v", userID, d.ProductID, d.ProductName, d.Issues)
+			}
+		}
+	})
+	if err != nil {
+		log.Printf("Erro ao configurar agendamento de verificação de consistência: %v", err)
+	} else {
+		log.Println("Agendamento de verificação de consistência configurado")
+	}
+
+	// Set up cron job for the monthly history archival (1st day of the month at 4:00 AM), if a retention is configured
+	if cfg.History.RetentionMonths > 0 {
+		historyArchiveService := service.NewHistoryArchiveService(historyRepository, historyArchiveRepository, stockSnapshotRepository, stockReportService, database.DB, cfg.History)
+		_, err = c.AddFunc("0 4 1 * *", func() {
+			log.Printf("Arquivando histórico com mais de %d meses...", cfg.History.RetentionMonths)
+			runs, err := historyArchiveService.ArchiveAllUsers()
+			if err != nil {
+				log.Printf("Erro ao arquivar histórico: %v", err)
+				return
+			}
+			for _, run := range runs {
+				log.Printf("Histórico do usuário %d: %d registros arquivados em %d arquivos", run.UserID, run.EntriesArchived, len(run.Archives))
+			}
+		})
+		if err != nil {
+			log.Printf("Erro ao configurar agendamento de arquivamento do histórico: %v", err)
+		} else {
+			log.Println("Agendamento de arquivamento do histórico configurado")
+		}
+	}
+
+	// Set up cron job for the monthly stock closing (1st day of the month at 0:10), closing the month that just ended
+	closingService := service.NewClosingService(repository.NewStockClosingRepository(database.DB), productRepository,
+		repository.NewProductParametersRepository(database.DB), stockReportService)
+	_, err = c.AddFunc("10 0 1 * *", func() {
+		log.Println("Executando fechamento mensal do estoque...")
+		closings, err := closingService.ClosePreviousMonthForAllUsers()
+		if err != nil {
+			log.Printf("Erro ao executar fechamento mensal do estoque: %v", err)
+			return
+		}
+		for _, closing := range closings {
+			log.Printf("Fechamento de %s registrado: %d produtos, %d lotes", closing.Period, closing.ProductCount, closing.LoteCount)
+		}
+	})
+	if err != nil {
+		log.Printf("Erro ao configurar agendamento de fechamento mensal: %v", err)
+	} else {
+		log.Println("Agendamento de fechamento mensal configurado")
+	}
+
+	// Set up cron job for the scheduled email reports (every hour, on the hour), if an SMTP server is configured
+	if cfg.SMTP.Host != "" {
+		emailReportService := service.NewEmailReportService(repository.NewEmailReportRepository(database.DB), productRepository,
+			repository.NewDashboardRepository(database.DB), utils.NewMailer(cfg.SMTP))
+		_, err = c.AddFunc("0 * * * *", func() {
+			deliveries, err := emailReportService.SendDue(time.Now())
+			if err != nil {
+				log.Printf("Erro ao enviar relatórios por e-mail: %v", err)
+				return
+			}
+			for _, d := range deliveries {
+				if d.Status == service.DeliveryFailed {
+					log.Printf("Falha ao enviar relatório %s para %v: %s", d.ReportType, d.Recipients, d.Error)
+				}
+			}
+			if len(deliveries) > 0 {
+				log.Printf("%d relatórios por e-mail processados", len(deliveries))
+			}
+		})
+		if err != nil {
+			log.Printf("Erro ao configurar agendamento de relatórios por e-mail: %v", err)
+		} else {
+			log.Println("Agendamento de relatórios por e-mail configurado")
+		}
+	}
+	c.Start()
+
 	// Start the server
 	port := cfg.Port
 	log.Printf("Servidor rodando na porta %s", port)
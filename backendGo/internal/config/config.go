@@ -3,6 +3,7 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -14,6 +15,8 @@ type Config struct {
     DBConfig DBConfig
     JWT      JWTConfig
     Admin    AdminConfig
+    History  HistoryConfig
+    SMTP     SMTPConfig
 }
 
 // DBConfig holds database configuration
@@ -31,6 +34,22 @@ type JWTConfig struct {
     Expiration time.Duration
 }
 
+// HistoryConfig holds history retention settings
+type HistoryConfig struct {
+    RetentionMonths int    // Entries older than this many months are archived; 0 disables archival
+    ArchiveDir      string // Where archive files are written
+}
+
+// SMTPConfig holds the SMTP server used to email the scheduled reports
+type SMTPConfig struct {
+    Host     string // Empty disables email delivery
+    Port     string
+    Username string // Empty sends without authentication, e.g. to a local SMTP sink
+    Password string
+    From     string
+    TLS      string // "starttls" (required upgrade), "tls" (implicit TLS) or "none"
+}
+
 // AdminConfig holds admin credentials
 type AdminConfig struct {
     Username string
@@ -55,6 +74,16 @@ func LoadConfig() *Config {
         log.Fatalf("Invalid JWT_EXPIRATION format: %v", err)
     }
 
+    retentionMonths, err := strconv.Atoi(getEnv("HISTORY_RETENTION_MONTHS", "0"))
+    if err != nil || retentionMonths < 0 {
+        log.Fatalf("Invalid HISTORY_RETENTION_MONTHS: %q", os.Getenv("HISTORY_RETENTION_MONTHS"))
+    }
+
+    smtpTLS := getEnv("SMTP_TLS", "starttls")
+    if smtpTLS != "starttls" && smtpTLS != "tls" && smtpTLS != "none" {
+        log.Fatalf("Invalid SMTP_TLS: %q (expected starttls, tls or none)", smtpTLS)
+    }
+
     return &Config{
         Port: getEnv("PORT", "3000"),
         DBConfig: DBConfig{
@@ -72,6 +101,18 @@ func LoadConfig() *Config {
             Username: getEnv("ADMIN_USERNAME", "admin"),
             Password: getEnv("ADMIN_PASSWORD", "admin"),
         },
+        History: HistoryConfig{
+            RetentionMonths: retentionMonths,
+            ArchiveDir:      getEnv("HISTORY_ARCHIVE_DIR", "archives/history"),
+        },
+        SMTP: SMTPConfig{
+            Host:     getEnv("SMTP_HOST", ""),
+            Port:     getEnv("SMTP_PORT", "587"),
+            Username: getEnv("SMTP_USERNAME", ""),
+            Password: getEnv("SMTP_PASSWORD", ""),
+            From:     getEnv("SMTP_FROM", "estoque@localhost"),
+            TLS:      smtpTLS,
+        },
     }
 }
 
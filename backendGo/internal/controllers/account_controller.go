@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// maxAccountArchiveSize is the largest account export accepted by the import.
+const maxAccountArchiveSize = 200 << 20
+
+// AccountController handles the export and restore of all of a user's data
+type AccountController struct {
+	service service.AccountService
+}
+
+// NewAccountController creates a new account controller
+func NewAccountController(service service.AccountService) *AccountController {
+	return &AccountController{service: service}
+}
+
+// Export godoc
+// @Summary Export all of the account's data
+// @Description Streams a versioned archive with the caller's products and lotes, supplier mappings, received invoices and whole history (archived months included). As a ZIP it holds manifest.json, products.json, settings.json and history.jsonl; as JSON it is one document with the same keys.
+// @Tags account
+// @Produce application/zip
+// @Produce json
+// @Param format query string false "zip (default) or json"
+// @Success 200 {file} file
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/account/export [get]
+// @Security BearerAuth
+func (ac *AccountController) Export(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	format := c.DefaultQuery("format", service.AccountArchiveZIP)
+	contentType := "application/zip"
+	switch format {
+	case service.AccountArchiveZIP:
+	case service.AccountArchiveJSON:
+		contentType = "application/json"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format. Must be zip or json"})
+		return
+	}
+
+	actor := actorFromContext(c, userID.(int))
+	fileName := fmt.Sprintf("conta_%s_%s.%s", actor.Username, time.Now().Format("2006-01-02"), format)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	c.Status(http.StatusOK)
+
+	// The archive is streamed, so a failure midway can only cut the response short.
+	if err := ac.service.Export(format, actor, c.Writer); err != nil {
+		log.Printf("WARN: account export for user %d failed: %v", actor.UserID, err)
+		c.Abort()
+	}
+}
+
+// Import godoc
+// @Summary Restore an account export
+// @Description Restores an archive produced by GET /api/account/export (ZIP or JSON) into the caller's account, which must have no products and no history. Products, lotes, history entries and batches get new IDs, and the references between them are rewritten; history keeps its dates and is chained anew.
+// @Tags account
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Account export (ZIP or JSON)"
+// @Success 201 {object} models.AccountImportResult
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 409 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/account/import [post]
+// @Security BearerAuth
+func (ac *AccountController) Import(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxAccountArchiveSize+1<<20) // Room for the multipart envelope
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "An account export is required in the 'file' form field (at most 200 MB)"})
+		return
+	}
+	if fileHeader.Size > maxAccountArchiveSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The account export must have at most 200 MB"})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read the uploaded file: " + err.Error()})
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read the uploaded file: " + err.Error()})
+		return
+	}
+
+	result, err := ac.service.Import(data, actorFromContext(c, userID.(int)))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidAccountArchive):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrAccountNotEmpty):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import account: " + err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, result)
+}
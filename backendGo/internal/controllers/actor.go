@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// actorFromContext describes who is making the request, from the values set by
+// AuthMiddleware and RequestID, for the history entries the request writes. userID is the
+// owner of the account.
+func actorFromContext(c *gin.Context, userID int) models.Actor {
+	return models.Actor{
+		UserID:      userID,
+		ActorUserID: c.GetInt("actorUserID"),
+		Username:    c.GetString("username"),
+		ClientIP:    c.ClientIP(),
+		UserAgent:   c.Request.UserAgent(),
+		RequestID:   c.GetString("requestID"),
+	}
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"log"
 	"net/http"
 	"time"
 
@@ -39,10 +40,11 @@ func (ac *AuthController) Login(c *gin.Context) {
     // Check if user exists
     var user models.User
     var hashedPassword string
+    var tokenVersion int
     err := database.DB.QueryRow(
-        "SELECT id, username, password FROM users WHERE username = $1", 
+        "SELECT id, username, password, active, role, token_version FROM users WHERE username = $1", 
         loginRequest.Username,
-    ).Scan(&user.ID, &user.Username, &hashedPassword)
+    ).Scan(&user.ID, &user.Username, &hashedPassword, &user.Active, &user.Role, &tokenVersion)
 
     if err != nil {
         c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não encontrado"})
@@ -55,8 +57,17 @@ func (ac *AuthController) Login(c *gin.Context) {
         return
     }
 
+    if !user.Active {
+        c.JSON(http.StatusForbidden, gin.H{"error": "Usuário desativado"})
+        return
+    }
+
+    if _, err := database.DB.Exec("UPDATE users SET last_login = CURRENT_TIMESTAMP WHERE id = $1", user.ID); err != nil {
+        log.Printf("WARN: failed to record login of user %d: %v", user.ID, err)
+    }
+
     // Generate JWT token
-    token, err := middleware.GenerateToken(user.ID, user.Username, ac.Config)
+    token, err := middleware.GenerateToken(user.ID, user.Username, user.Role, tokenVersion, ac.Config)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar token"})
         return
@@ -67,6 +78,7 @@ func (ac *AuthController) Login(c *gin.Context) {
         "user": gin.H{
             "id":       user.ID,
             "username": user.Username,
+            "role":     user.Role,
         },
     })
 }
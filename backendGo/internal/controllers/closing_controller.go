@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/repository"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ClosingController handles the monthly stock closings
+type ClosingController struct {
+	service service.ClosingService
+}
+
+// NewClosingController creates a new closing controller
+func NewClosingController(service service.ClosingService) *ClosingController {
+	return &ClosingController{service: service}
+}
+
+// respondIfPeriodClosed answers 409 when err is a change refused because it is dated inside a
+// closed month, and reports whether it did.
+func respondIfPeriodClosed(c *gin.Context, err error) bool {
+	if !repository.IsPeriodClosed(err) {
+		return false
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "The stock period is closed; changes dated inside a closed month are refused"})
+	return true
+}
+
+// List godoc
+// @Summary List monthly closings
+// @Description Lists the caller's monthly closings, newest first, with their product and lote counts and total value, without the products.
+// @Tags closings
+// @Produce json
+// @Success 200 {array} models.StockClosing
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/closings [get]
+// @Security BearerAuth
+func (cc *ClosingController) List(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	closings, err := cc.service.List(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list stock closings: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, closings)
+}
+
+// Get godoc
+// @Summary Get a monthly closing
+// @Description Returns the closing of a month with the frozen quantity, unit cost and value of every product and lote.
+// @Tags closings
+// @Produce json
+// @Param period path string true "Month (YYYY-MM)"
+// @Success 200 {object} models.StockClosing
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 404 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/closings/{period} [get]
+// @Security BearerAuth
+func (cc *ClosingController) Get(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	closing, err := cc.service.Get(c.Param("period"), userID.(int))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidClosingPeriod) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock closing: " + err.Error()})
+		return
+	}
+	if closing == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stock closing not found"})
+		return
+	}
+	c.JSON(http.StatusOK, closing)
+}
+
+// Create godoc
+// @Summary Close a month
+// @Description Freezes the quantity and value (at the current unit costs) of every product and lote at the end of a month that is over, from the stock reconstructed at that instant. Changes dated inside a closed month are refused from then on, including restored history archives and account imports. Closings cannot be changed or removed.
+// @Tags closings
+// @Accept json
+// @Produce json
+// @Param closing body models.StockClosingInput true "Month to close"
+// @Success 201 {object} models.StockClosing
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 409 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/closings [post]
+// @Security BearerAuth
+func (cc *ClosingController) Create(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var input models.StockClosingInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	closing, err := cc.service.Close(input.Period, actorFromContext(c, userID.(int)))
+	switch {
+	case errors.Is(err, service.ErrInvalidClosingPeriod):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrClosingExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "Period " + input.Period + " is already closed"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to close period: " + err.Error()})
+	default:
+		c.JSON(http.StatusCreated, closing)
+	}
+}
+
+// Compare godoc
+// @Summary Compare two monthly closings
+// @Description Compares the quantity and value of every product between two closings. Without periods, compares the two latest closings; with only "to", that closing and the previous one.
+// @Tags closings
+// @Produce json
+// @Param from query string false "Earlier month (YYYY-MM)"
+// @Param to query string false "Later month (YYYY-MM)"
+// @Success 200 {object} models.StockClosingComparison
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 404 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/closings/compare [get]
+// @Security BearerAuth
+func (cc *ClosingController) Compare(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	comparison, err := cc.service.Compare(c.Query("from"), c.Query("to"), userID.(int))
+	switch {
+	case errors.Is(err, service.ErrInvalidClosingPeriod):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrClosingNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compare stock closings: " + err.Error()})
+	default:
+		c.JSON(http.StatusOK, comparison)
+	}
+}
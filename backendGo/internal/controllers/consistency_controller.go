@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ConsistencyController handles the reconciliation of products, lotes and history
+type ConsistencyController struct {
+	service service.ConsistencyService
+}
+
+// NewConsistencyController creates a new consistency controller
+func NewConsistencyController(service service.ConsistencyService) *ConsistencyController {
+	return &ConsistencyController{service: service}
+}
+
+// Check godoc
+// @Summary Check stock consistency
+// @Description Compares products.quantity with the sum of lotes and with the replayed history, per product.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} models.ConsistencyReport
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/admin/consistency [get]
+// @Security BearerAuth
+func (cc *ConsistencyController) Check(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	report, err := cc.service.Check(userID.(int), false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check consistency: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// Fix godoc
+// @Summary Fix stock inconsistencies
+// @Description Runs the consistency check and fixes every discrepancy, recording the corrections as one history batch.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} models.ConsistencyReport
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/admin/consistency/fix [post]
+// @Security BearerAuth
+func (cc *ConsistencyController) Fix(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	report, err := cc.service.Check(userID.(int), true)
+	if err != nil {
+		if respondIfPeriodClosed(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fix consistency: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
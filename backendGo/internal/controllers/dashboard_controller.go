@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// DashboardController handles the dashboard summary
+type DashboardController struct {
+	service service.DashboardService
+}
+
+// NewDashboardController creates a new dashboard controller
+func NewDashboardController(service service.DashboardService) *DashboardController {
+	return &DashboardController{service: service}
+}
+
+// Get godoc
+// @Summary Get the dashboard summary
+// @Description Returns the number of products and lotes, the stock totals per unit (quantity, expired lotes and quantity, lotes expiring within 7, 30 and 90 days), the low-stock products, the latest history batches of the last 30 days and the status of the last database backup.
+// @Tags dashboard
+// @Produce json
+// @Success 200 {object} models.Dashboard
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/dashboard [get]
+// @Security BearerAuth
+func (dc *DashboardController) Get(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	dashboard, err := dc.service.GetDashboard(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build dashboard: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, dashboard)
+}
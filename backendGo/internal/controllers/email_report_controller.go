@@ -0,0 +1,233 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// EmailReportController handles the scheduled email reports and their delivery log
+type EmailReportController struct {
+	service service.EmailReportService
+}
+
+// NewEmailReportController creates a new email report controller
+func NewEmailReportController(service service.EmailReportService) *EmailReportController {
+	return &EmailReportController{service: service}
+}
+
+// List godoc
+// @Summary List email reports
+// @Description Lists the caller's scheduled email reports.
+// @Tags email-reports
+// @Produce json
+// @Success 200 {array} models.EmailReport
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/email-reports [get]
+// @Security BearerAuth
+func (ec *EmailReportController) List(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	reports, err := ec.service.List(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list email reports: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, reports)
+}
+
+// Create godoc
+// @Summary Create an email report
+// @Description Schedules a report (stock_summary, expiring_lotes or low_stock) to be emailed to up to 20 recipients daily, weekly on a weekday (0 Sunday to 6, default Monday) or monthly on a day (1 to 28, default 1), at an hour of the server's time (default 7). daysAhead is the expiry window of the expiring lotes report (default 30). With skipEmpty, scheduled runs without rows send nothing.
+// @Tags email-reports
+// @Accept json
+// @Produce json
+// @Param report body models.EmailReportInput true "Email report"
+// @Success 201 {object} models.EmailReport
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/email-reports [post]
+// @Security BearerAuth
+func (ec *EmailReportController) Create(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var input models.EmailReportInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	report, err := ec.service.Create(input, userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create email report: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, report)
+}
+
+// Update godoc
+// @Summary Replace an email report
+// @Description Replaces the settings of an email report; omitted schedule fields take their defaults.
+// @Tags email-reports
+// @Accept json
+// @Produce json
+// @Param id path int true "Email report ID"
+// @Param report body models.EmailReportInput true "Email report"
+// @Success 200 {object} models.EmailReport
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 404 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/email-reports/{id} [put]
+// @Security BearerAuth
+func (ec *EmailReportController) Update(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	reportID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email report ID"})
+		return
+	}
+	var input models.EmailReportInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	report, err := ec.service.Update(reportID, input, userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update email report: " + err.Error()})
+		return
+	}
+	if report == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Email report not found"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// Delete godoc
+// @Summary Delete an email report
+// @Description Removes an email report. Its deliveries stay in the delivery log.
+// @Tags email-reports
+// @Param id path int true "Email report ID"
+// @Success 204
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 404 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/email-reports/{id} [delete]
+// @Security BearerAuth
+func (ec *EmailReportController) Delete(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	reportID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email report ID"})
+		return
+	}
+
+	found, err := ec.service.Delete(reportID, userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete email report: " + err.Error()})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Email report not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Send godoc
+// @Summary Send an email report now
+// @Description Sends an email report immediately, even if it is disabled or has no rows, and returns the logged delivery. A delivery whose status is failed carries the SMTP error.
+// @Tags email-reports
+// @Produce json
+// @Param id path int true "Email report ID"
+// @Success 200 {object} models.EmailReportDelivery
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 404 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Failure 503 {object} gin.H{"error": "message"}
+// @Router /api/email-reports/{id}/send [post]
+// @Security BearerAuth
+func (ec *EmailReportController) Send(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	reportID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email report ID"})
+		return
+	}
+
+	delivery, err := ec.service.SendNow(reportID, userID.(int))
+	if err != nil {
+		if errors.Is(err, service.ErrEmailNotConfigured) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send email report: " + err.Error()})
+		return
+	}
+	if delivery == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Email report not found"})
+		return
+	}
+	c.JSON(http.StatusOK, delivery)
+}
+
+// ListDeliveries godoc
+// @Summary List email report deliveries
+// @Description Lists the caller's latest email report deliveries, newest first: scheduled and manual, sent, failed or skipped.
+// @Tags email-reports
+// @Produce json
+// @Param limit query int false "Maximum number of deliveries (default 50, up to 500)"
+// @Success 200 {array} models.EmailReportDelivery
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/email-reports/deliveries [get]
+// @Security BearerAuth
+func (ec *EmailReportController) ListDeliveries(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	if limit > service.MaxEmailReportDeliveries {
+		limit = service.MaxEmailReportDeliveries
+	}
+
+	deliveries, err := ec.service.ListDeliveries(userID.(int), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list email report deliveries: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
@@ -1,12 +1,21 @@
 package controllers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
 	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/service"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/utils"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
 )
 
 // HistoryController handles history-related requests
@@ -19,7 +28,52 @@ func NewHistoryController(service service.HistoryService) *HistoryController {
 	return &HistoryController{service: service}
 }
 
+// parseHistoryFilter reads the optional history filters from the query string:
+// from, to (YYYY-MM-DD or RFC3339), entity_type, action, product_id, actor_user_id and q (product name).
+func parseHistoryFilter(c *gin.Context) (models.HistoryFilter, error) {
+	filter := models.HistoryFilter{
+		EntityType: c.Query("entity_type"),
+		Action:     c.Query("action"),
+		ProductID:  c.Query("product_id"),
+		Search:     strings.TrimSpace(c.Query("q")),
+	}
+
+	if from := c.Query("from"); from != "" {
+		t, err := parseDateBound(from, false)
+		if err != nil {
+			return filter, err
+		}
+		filter.From = &t
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := parseDateBound(to, true)
+		if err != nil {
+			return filter, err
+		}
+		filter.To = &t
+	}
+	if actor := c.Query("actor_user_id"); actor != "" {
+		id, err := strconv.Atoi(actor)
+		if err != nil || id <= 0 {
+			return filter, fmt.Errorf("invalid actor_user_id %q", actor)
+		}
+		filter.ActorUserID = &id
+	}
+	if filter.From != nil && filter.To != nil && filter.To.Before(*filter.From) {
+		return filter, fmt.Errorf("'from' must not be after 'to'")
+	}
+
+	switch filter.EntityType {
+	case "", service.EntityTypeProduct, service.EntityTypeLote, service.EntityTypeProductBatchContext:
+	default:
+		return filter, fmt.Errorf("invalid entity_type. Must be 'product', 'lote' or 'product_batch_context'")
+	}
+
+	return filter, nil
+}
+
 // GetAll gets all history records with pagination
+// Supports the filters read by parseHistoryFilter.
 func (hc *HistoryController) GetAll(c *gin.Context) {
 	limitQuery := c.DefaultQuery("limit", "20")
 	offsetQuery := c.DefaultQuery("offset", "0")
@@ -43,11 +97,17 @@ func (hc *HistoryController) GetAll(c *gin.Context) {
 		return
 	}
 
+	filter, err := parseHistoryFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// If batch_id is provided, get by batch_id instead
 	if batchID != "" {
 		historyEntries, fetchErr = hc.service.GetByBatchID(batchID, userID.(int))
 	} else {
-		historyEntries, fetchErr = hc.service.GetHistory(limit, offset, userID.(int))
+		historyEntries, fetchErr = hc.service.GetHistory(filter, limit, offset, userID.(int))
 	}
 
 	if fetchErr != nil {
@@ -61,27 +121,65 @@ func (hc *HistoryController) GetAll(c *gin.Context) {
 	c.JSON(http.StatusOK, historyEntries)
 }
 
-// Create adds a new history entry (potentially from client, if design allows)
+// Create godoc
+// @Summary Record a history entry
+// @Description Validates a history entry against the schema of its entity type and the caller's products and lotes, then stores it with the server time.
+// @Tags history
+// @Accept json
+// @Produce json
+// @Param entry body models.HistoryEntryInput true "History entry"
+// @HeaderParam X-Operation-Batch-ID header string false "Optional Batch ID for grouping operations"
+// @Success 201 {object} gin.H{"message": "string", "id": "string", "batch_id": "string"}
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/history [post]
+// @Security BearerAuth
 func (hc *HistoryController) Create(c *gin.Context) {
-	var historyEntry models.History
-	if err := c.ShouldBindJSON(&historyEntry); err != nil {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var input models.HistoryEntryInput
+	if err := bindStrictJSON(c, &input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid history data: " + err.Error()})
 		return
 	}
 
-	err := hc.service.CreateRawHistoryEntry(historyEntry)
+	entries, err := hc.service.RecordClientEntries([]models.HistoryEntryInput{input}, actorFromContext(c, userID.(int)), c.GetHeader("X-Operation-Batch-ID"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create history entry: " + err.Error()})
+		respondHistoryWriteError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "Histórico adicionado com sucesso",
-		"id":      historyEntry.ID,
-		"batch_id": historyEntry.BatchID,
+		"message":  "Histórico adicionado com sucesso",
+		"id":       entries[0].ID,
+		"batch_id": entries[0].BatchID,
 	})
 }
 
+// bindStrictJSON binds the request body into v, rejecting fields that v does not declare.
+func bindStrictJSON(c *gin.Context, v interface{}) error {
+	dec := json.NewDecoder(c.Request.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	return binding.Validator.ValidateStruct(v)
+}
+
+// respondHistoryWriteError answers 400 for rejected client entries and 500 otherwise.
+func respondHistoryWriteError(c *gin.Context, err error) {
+	var validationErr *service.HistoryValidationError
+	if errors.As(err, &validationErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid history data: " + validationErr.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create history entry: " + err.Error()})
+}
+
 // GetHistoryForEntity retrieves history for a specific entity (product or lote)
 // @Summary Get history for a specific entity
 // @Description Retrieves all history records for a given entity type and ID.
@@ -124,38 +222,49 @@ func (hc *HistoryController) GetHistoryForEntity(c *gin.Context) {
 
 // CreateBatch handles the creation of multiple history entries in a single batch
 // @Summary Create multiple history entries in one batch
-// @Description Creates multiple history entries with a shared batch ID
+// @Description Validates every entry like POST /api/history and stores them atomically with a shared batch ID
 // @Tags history
 // @Accept json
 // @Produce json
-// @Param entries body []models.History true "Array of history entries (BatchID will be set automatically)"
+// @Param entries body []models.HistoryEntryInput true "Array of history entries"
+// @HeaderParam X-Operation-Batch-ID header string false "Optional Batch ID; a new one is generated if missing"
 // @Success 201 {object} gin.H{"message": "string", "batch_id": "string", "count": int}
 // @Failure 400 {object} gin.H{"error": "string"}
 // @Failure 500 {object} gin.H{"error": "string"}
 // @Router /api/history/batch [post]
 // @Security BearerAuth
 func (hc *HistoryController) CreateBatch(c *gin.Context) {
-	var historyEntries []models.History
-	if err := c.ShouldBindJSON(&historyEntries); err != nil {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var inputs []models.HistoryEntryInput
+	if err := bindStrictJSON(c, &inputs); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid batch history data: " + err.Error()})
 		return
 	}
 
-	if len(historyEntries) == 0 {
+	if len(inputs) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Empty batch - no history entries provided"})
 		return
 	}
 
-	batchID, err := hc.service.CreateBatch(historyEntries)
+	batchID := c.GetHeader("X-Operation-Batch-ID")
+	if batchID == "" {
+		batchID = uuid.NewString()
+	}
+	entries, err := hc.service.RecordClientEntries(inputs, actorFromContext(c, userID.(int)), batchID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create history batch: " + err.Error()})
+		respondHistoryWriteError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "History batch created successfully",
+		"message":  "History batch created successfully",
 		"batch_id": batchID,
-		"count": len(historyEntries),
+		"count":    len(entries),
 	})
 }
 
@@ -202,6 +311,13 @@ func (hc *HistoryController) GetByBatch(c *gin.Context) {
 // @Produce json
 // @Param page query int false "Page number for batch pagination" default(1)
 // @Param pageSize query int false "Number of batches per page" default(10)
+// @Param from query string false "Only batches with entries on or after this date (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "Only batches with entries on or before this date (YYYY-MM-DD or RFC3339)"
+// @Param entity_type query string false "product, lote or product_batch_context"
+// @Param action query string false "created, updated or deleted"
+// @Param product_id query string false "Entries of the product and of its lotes"
+// @Param actor_user_id query int false "Entries of changes made by this user"
+// @Param q query string false "Text contained in the product name"
 // @Success 200 {object} models.PaginatedHistoryBatchGroups
 // @Failure 400 {object} gin.H{"error": "string"} "Invalid query parameters"
 // @Failure 500 {object} gin.H{"error": "string"}
@@ -227,7 +343,13 @@ func (hc *HistoryController) GetGrouped(c *gin.Context) {
 		return
 	}
 
-	paginatedGroups, err := hc.service.GetGroupedHistory(page, pageSize, userID.(int))
+	filter, err := parseHistoryFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	paginatedGroups, err := hc.service.GetGroupedHistory(filter, page, pageSize, userID.(int))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch grouped history: " + err.Error()})
 		return
@@ -238,7 +360,7 @@ func (hc *HistoryController) GetGrouped(c *gin.Context) {
 
 // CreateProductBatchContext godoc
 // @Summary Create a product batch context history entry
-// @Description Records a snapshot of a product's state (name, quantity before/after) for a given batch of operations.
+// @Description Records a snapshot of a product's state (name, quantity before/after) for a given batch of operations. The product must belong to the caller or have history of the caller.
 // @Tags history
 // @Accept json
 // @Produce json
@@ -264,7 +386,7 @@ func (hc *HistoryController) CreateProductBatchContext(c *gin.Context) {
 		return
 	}
 
-	if err := c.ShouldBindJSON(&payload); err != nil {
+	if err := bindStrictJSON(c, &payload); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload: " + err.Error()})
 		return
 	}
@@ -274,12 +396,186 @@ func (hc *HistoryController) CreateProductBatchContext(c *gin.Context) {
 		return
 	}
 
-	// Use a distinct EntityType for these records
-	err := hc.service.RecordChange(service.EntityTypeProductBatchContext, payload.ProductID, payload, userID.(int), operationBatchID)
+	// Use a distinct EntityType for these records, validated like any client-submitted entry
+	changes, err := json.Marshal(payload)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record product batch context: " + err.Error()})
 		return
 	}
+	input := models.HistoryEntryInput{EntityType: service.EntityTypeProductBatchContext, EntityID: payload.ProductID, Changes: changes}
+	if _, err := hc.service.RecordClientEntries([]models.HistoryEntryInput{input}, actorFromContext(c, userID.(int)), operationBatchID); err != nil {
+		respondHistoryWriteError(c, err)
+		return
+	}
 
 	c.JSON(http.StatusCreated, gin.H{"message": "Product batch context recorded successfully"})
-}
\ No newline at end of file
+}
+
+// VerifyChain godoc
+// @Summary Verify the history hash chain
+// @Description Walks the caller's tamper-evident history chain and reports the first broken link, if any.
+// @Tags history
+// @Produce json
+// @Success 200 {object} models.HistoryChainVerification
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/history/verify [get]
+// @Security BearerAuth
+func (hc *HistoryController) VerifyChain(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	result, err := hc.service.VerifyChain(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify history chain: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetAuditTrail godoc
+// @Summary Get the history audit trail
+// @Description Lists the history entries like GET /api/history, with the hash chain links (seq, prevHash, hash) kept out of it. Admin only.
+// @Tags history
+// @Produce json
+// @Param limit query int false "Page size (default 20)"
+// @Param offset query int false "Entries to skip"
+// @Success 200 {array} models.HistoryAuditEntry
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 403 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/admin/history/audit [get]
+// @Security BearerAuth
+func (hc *HistoryController) GetAuditTrail(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	filter, err := parseHistoryFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entries, err := hc.service.GetAuditTrail(filter, limit, offset, userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch history audit trail: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// GetProductTimeline godoc
+// @Summary Get the timeline of a product
+// @Description Merges product, lote and batch-context history of one product in chronological order, with the running product quantity after each event.
+// @Tags history
+// @Produce json
+// @Param product_id path string true "Product ID"
+// @Param from query string false "Only events on or after this date (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "Only events on or before this date (YYYY-MM-DD or RFC3339)"
+// @Success 200 {object} models.ProductTimeline
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 404 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/history/timeline/{product_id} [get]
+// @Security BearerAuth
+func (hc *HistoryController) GetProductTimeline(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var from, to *time.Time
+	if value := c.Query("from"); value != "" {
+		t, err := parseDateBound(value, false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		from = &t
+	}
+	if value := c.Query("to"); value != "" {
+		t, err := parseDateBound(value, true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		to = &t
+	}
+
+	timeline, err := hc.service.GetProductTimeline(c.Param("product_id"), from, to, userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build product timeline: " + err.Error()})
+		return
+	}
+	if timeline == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+	c.JSON(http.StatusOK, timeline)
+}
+
+// Export godoc
+// @Summary Export history as CSV or XLSX
+// @Description Streams the history entries matching the filters in chronological order, one row per entry, with the product and lote change fields flattened into columns.
+// @Tags history
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "csv (default) or xlsx"
+// @Param from query string false "Only entries on or after this date (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "Only entries on or before this date (YYYY-MM-DD or RFC3339)"
+// @Param entity_type query string false "product, lote or product_batch_context"
+// @Param action query string false "created, updated or deleted"
+// @Param product_id query string false "Entries of a product and of its lotes"
+// @Param actor_user_id query int false "Entries of changes made by this user"
+// @Param q query string false "Product name search"
+// @Success 200 {file} file
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Router /api/history/export [get]
+// @Security BearerAuth
+func (hc *HistoryController) Export(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", utils.TableFormatCSV))
+	if format != utils.TableFormatCSV && format != utils.TableFormatXLSX {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format. Must be 'csv' or 'xlsx'"})
+		return
+	}
+	filter, err := parseHistoryFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fileName := fmt.Sprintf("historico_%s.%s", time.Now().Format("2006-01-02"), format)
+	c.Header("Content-Type", utils.TableContentType(format))
+	c.Header("Content-Disposition", `attachment; filename="`+fileName+`"`)
+	c.Status(http.StatusOK)
+
+	// Once streaming has started the status can no longer change; a failure leaves a truncated file.
+	w, err := utils.NewTableWriter(format, c.Writer, "Histórico")
+	if err == nil {
+		err = hc.service.ExportHistory(filter, userID.(int), w)
+	}
+	if err != nil {
+		log.Printf("WARN: history export for user %d failed: %v", userID.(int), err)
+		c.Abort()
+	}
+}
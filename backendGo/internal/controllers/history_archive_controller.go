@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// HistoryArchiveController handles the archival and restoration of old history
+type HistoryArchiveController struct {
+	service         service.HistoryArchiveService
+	retentionMonths int // Default retention when the request does not set one
+}
+
+// NewHistoryArchiveController creates a new history archive controller
+func NewHistoryArchiveController(service service.HistoryArchiveService, retentionMonths int) *HistoryArchiveController {
+	return &HistoryArchiveController{service: service, retentionMonths: retentionMonths}
+}
+
+// List godoc
+// @Summary List history archives
+// @Description Lists the caller's history archive files, newest first.
+// @Tags admin
+// @Produce json
+// @Success 200 {array} models.HistoryArchive
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/admin/history/archives [get]
+// @Security BearerAuth
+func (hc *HistoryArchiveController) List(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	archives, err := hc.service.ListArchives(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list history archives: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, archives)
+}
+
+// Archive godoc
+// @Summary Archive old history now
+// @Description Moves the caller's history older than the retention period to compressed archive files, keeping monthly stock snapshots.
+// @Tags admin
+// @Produce json
+// @Param months query int false "Retention in months (defaults to HISTORY_RETENTION_MONTHS)"
+// @Success 200 {object} models.HistoryArchiveRun
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/admin/history/archives [post]
+// @Security BearerAuth
+func (hc *HistoryArchiveController) Archive(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	months := hc.retentionMonths
+	if value := c.Query("months"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "months must be a positive integer"})
+			return
+		}
+		months = parsed
+	}
+
+	run, err := hc.service.Archive(userID.(int), months)
+	if err != nil {
+		if errors.Is(err, service.ErrRetentionDisabled) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "History retention is disabled; set HISTORY_RETENTION_MONTHS or the months parameter"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to archive history: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
+
+// Restore godoc
+// @Summary Restore a history archive
+// @Description Re-imports the entries of an archive file into the history, after checking its checksum.
+// @Tags admin
+// @Produce json
+// @Param id path int true "Archive ID"
+// @Success 200 {object} gin.H{"message": "string", "restored": int}
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 404 {object} gin.H{"error": "message"}
+// @Failure 409 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/admin/history/archives/{id}/restore [post]
+// @Security BearerAuth
+func (hc *HistoryArchiveController) Restore(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	archiveID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid archive ID"})
+		return
+	}
+
+	restored, err := hc.service.Restore(archiveID, userID.(int))
+	switch {
+	case errors.Is(err, service.ErrArchiveNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "History archive not found"})
+	case errors.Is(err, service.ErrArchiveRestored):
+		c.JSON(http.StatusConflict, gin.H{"error": "History archive was already restored"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore history archive: " + err.Error()})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "History archive restored successfully", "restored": restored})
+	}
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportFileSize is the largest spreadsheet accepted by the import.
+const maxImportFileSize = 10 << 20
+
+// ImportController handles bulk imports of products and lotes
+type ImportController struct {
+	service service.ImportService
+}
+
+// NewImportController creates a new import controller
+func NewImportController(service service.ImportService) *ImportController {
+	return &ImportController{service: service}
+}
+
+// Import godoc
+// @Summary Import products and lotes from a spreadsheet
+// @Description Reads an XLSX or CSV file in the layout of the product export ("Tipo", "Nome Produto", "Unidade Produto", "Qtd Total Produto", "Qtd Lote", "Validade Lote") and validates every row. With dry_run it only reports the per-row errors; otherwise, if every row is valid, it creates all products and lotes in one transaction and one history batch.
+// @Tags import
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "XLSX or CSV spreadsheet"
+// @Param dry_run query bool false "Only validate, creating nothing"
+// @HeaderParam X-Operation-Batch-ID header string false "Optional Batch ID for the history of the import"
+// @Success 200 {object} models.ImportResult "Dry run"
+// @Success 201 {object} models.ImportResult "Imported"
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 422 {object} models.ImportResult "Invalid rows; nothing was created"
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/import [post]
+// @Security BearerAuth
+func (ic *ImportController) Import(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	dryRun := false
+	if value := c.Query("dry_run"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dry_run. Must be true or false"})
+			return
+		}
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportFileSize+1<<20) // Room for the multipart envelope
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A spreadsheet is required in the 'file' form field (at most 10 MB)"})
+		return
+	}
+	if fileHeader.Size > maxImportFileSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The spreadsheet must have at most 10 MB"})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read the uploaded file: " + err.Error()})
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read the uploaded file: " + err.Error()})
+		return
+	}
+
+	result, err := ic.service.Import(data, dryRun, actorFromContext(c, userID.(int)), c.GetHeader("X-Operation-Batch-ID"))
+	if err != nil {
+		if respondIfPeriodClosed(c, err) {
+			return
+		}
+		if errors.Is(err, service.ErrInvalidImportFile) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import products: " + err.Error()})
+		return
+	}
+
+	switch {
+	case result.Applied:
+		c.JSON(http.StatusCreated, result)
+	case !result.DryRun:
+		c.JSON(http.StatusUnprocessableEntity, result)
+	default:
+		c.JSON(http.StatusOK, result)
+	}
+}
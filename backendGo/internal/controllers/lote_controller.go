@@ -60,8 +60,11 @@ func (lc *LoteController) CreateLote(c *gin.Context) {
         return
     }
 
-	createdLote, err := lc.service.CreateLote(productID, loteReq, userID.(int), operationBatchID)
+	createdLote, err := lc.service.CreateLote(productID, loteReq, actorFromContext(c, userID.(int)), operationBatchID)
 	if err != nil {
+		if respondIfPeriodClosed(c, err) {
+			return
+		}
 		// Basic error type checking, can be more granular
 		if err.Error() == fmt.Sprintf("product with ID %s not found", productID) {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
@@ -145,8 +148,11 @@ func (lc *LoteController) UpdateLote(c *gin.Context) {
         return
     }
 
-	updatedLote, err := lc.service.UpdateLote(loteID, loteReq, userID.(int), operationBatchID)
+	updatedLote, err := lc.service.UpdateLote(loteID, loteReq, actorFromContext(c, userID.(int)), operationBatchID)
 	if err != nil {
+		if respondIfPeriodClosed(c, err) {
+			return
+		}
 		if err.Error() == fmt.Sprintf("lote with ID %s not found", loteID) {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		} else if _, ok := err.(validator.ValidationErrors); ok {
@@ -184,8 +190,11 @@ func (lc *LoteController) DeleteLote(c *gin.Context) {
 		return
 	}
 
-	err := lc.service.DeleteLote(loteID, userID.(int), operationBatchID)
+	err := lc.service.DeleteLote(loteID, actorFromContext(c, userID.(int)), operationBatchID)
 	if err != nil {
+		if respondIfPeriodClosed(c, err) {
+			return
+		}
 		if err.Error() == fmt.Sprintf("lote with ID %s not found", loteID) {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		} else {
@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// maxNFeFileSize is the largest NF-e XML accepted.
+const maxNFeFileSize = 5 << 20
+
+// NFeController handles the receipt of stock from NF-e invoices
+type NFeController struct {
+	service service.NFeService
+}
+
+// NewNFeController creates a new NF-e controller
+func NewNFeController(service service.NFeService) *NFeController {
+	return &NFeController{service: service}
+}
+
+// Import godoc
+// @Summary Receive stock from an NF-e invoice
+// @Description Parses an NF-e XML, maps each item to a product (mapping sent in the request, mapping saved for the supplier code, or same product name) and turns each lot of the rastro group into a lote. Unless dry_run is set or an item is invalid, creates all lotes in one history batch whose entries carry the invoice key, and remembers the mappings.
+// @Tags nfe
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "NF-e XML (nfeProc or NFe)"
+// @Param mappings formData string false "JSON array of {supplierCode, productId, unitFactor}"
+// @Param dry_run query bool false "Only map and validate, creating nothing"
+// @Param skip_untracked query bool false "Leave out items without lots instead of rejecting the invoice"
+// @HeaderParam X-Operation-Batch-ID header string false "Optional Batch ID for the history of the receipt"
+// @Success 200 {object} models.NFeImportResult "Dry run"
+// @Success 201 {object} models.NFeImportResult "Received"
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 409 {object} gin.H{"error": "message"}
+// @Failure 422 {object} models.NFeImportResult "Invalid items; nothing was created"
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/nfe/import [post]
+// @Security BearerAuth
+func (nc *NFeController) Import(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var opts service.NFeImportOptions
+	for name, target := range map[string]*bool{"dry_run": &opts.DryRun, "skip_untracked": &opts.SkipUntracked} {
+		if value := c.Query(name); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name + ". Must be true or false"})
+				return
+			}
+			*target = parsed
+		}
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxNFeFileSize+1<<20) // Room for the multipart envelope
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "An NF-e XML is required in the 'file' form field (at most 5 MB)"})
+		return
+	}
+	if fileHeader.Size > maxNFeFileSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The NF-e XML must have at most 5 MB"})
+		return
+	}
+	if value := c.PostForm("mappings"); value != "" {
+		if err := json.Unmarshal([]byte(value), &opts.Mappings); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mappings: " + err.Error()})
+			return
+		}
+		for _, m := range opts.Mappings {
+			if err := binding.Validator.ValidateStruct(m); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mappings: " + err.Error()})
+				return
+			}
+		}
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read the uploaded file: " + err.Error()})
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read the uploaded file: " + err.Error()})
+		return
+	}
+
+	result, err := nc.service.Import(data, opts, actorFromContext(c, userID.(int)), c.GetHeader("X-Operation-Batch-ID"))
+	if err != nil {
+		if respondIfPeriodClosed(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrInvalidNFe), errors.Is(err, service.ErrInvalidNFeMapping):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrNFeAlreadyImported):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import NF-e: " + err.Error()})
+		}
+		return
+	}
+
+	switch {
+	case result.Applied:
+		c.JSON(http.StatusCreated, result)
+	case !result.DryRun:
+		c.JSON(http.StatusUnprocessableEntity, result)
+	default:
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// ListImports godoc
+// @Summary List received NF-e invoices
+// @Description Lists the caller's imported invoices, newest first, with the history batch of each.
+// @Tags nfe
+// @Produce json
+// @Success 200 {array} models.NFeImport
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/nfe/imports [get]
+// @Security BearerAuth
+func (nc *NFeController) ListImports(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	imports, err := nc.service.ListImports(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list NF-e imports: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, imports)
+}
+
+// ListMappings godoc
+// @Summary List supplier code mappings
+// @Description Lists the products the caller's supplier codes are received as.
+// @Tags nfe
+// @Produce json
+// @Success 200 {array} models.SupplierProductMapping
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/nfe/mappings [get]
+// @Security BearerAuth
+func (nc *NFeController) ListMappings(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	mappings, err := nc.service.ListMappings(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list supplier mappings: " + err.Error()})
+		return
+	}
+	if mappings == nil {
+		mappings = []models.SupplierProductMapping{}
+	}
+	c.JSON(http.StatusOK, mappings)
+}
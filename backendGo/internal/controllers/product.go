@@ -1,30 +1,35 @@
 package controllers
 
 import (
+	"bytes"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
 	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/repository"
 	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/service"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 // ProductController handles product-related requests
 type ProductController struct {
-	repo       repository.ProductRepository
-	historySvc service.HistoryService
+	repo           repository.ProductRepository
+	historySvc     service.HistoryService
+	exportSvc      service.ProductExportService
+	consumptionSvc service.ConsumptionService
 }
 
 // NewProductController creates a new product controller
-func NewProductController(repo repository.ProductRepository, historySvc service.HistoryService) *ProductController {
-	return &ProductController{repo: repo, historySvc: historySvc}
+func NewProductController(repo repository.ProductRepository, historySvc service.HistoryService, exportSvc service.ProductExportService, consumptionSvc service.ConsumptionService) *ProductController {
+	return &ProductController{repo: repo, historySvc: historySvc, exportSvc: exportSvc, consumptionSvc: consumptionSvc}
 }
 
 // GetAll returns all products
 // @Summary Get all products
-// @Description Retrieves a list of all products in the system.
+// @Description Retrieves a list of all products in the system, each with its days of cover at the consumption rate of the last 30 days.
 // @Tags products
 // @Produce json
 // @Success 200 {array} models.Product
@@ -46,9 +51,63 @@ func (pc *ProductController) GetAll(c *gin.Context) {
 	if products == nil {
 		products = []models.Product{}
 	}
+	// Days of cover are informative; the list is still served without them
+	if err := pc.consumptionSvc.FillDaysOfCover(products, userID.(int)); err != nil {
+		log.Printf("WARN: failed to compute days of cover for user %d: %v", userID.(int), err)
+	}
 	c.JSON(http.StatusOK, products)
 }
 
+// Export returns the product spreadsheet
+// @Summary Export products and lotes
+// @Description Produces the product spreadsheet in the layout of the frontend export ("Tipo", "Nome Produto", "Unidade Produto", "Qtd Total Produto", "Qtd Lote", "Validade Lote"): each product row is followed by its lotes.
+// @Tags products
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Produce text/csv
+// @Produce json
+// @Param format query string false "xlsx (default), csv or json"
+// @Success 200 {array} models.ProductExportRow
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/products/export [get]
+// @Security BearerAuth
+func (pc *ProductController) Export(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", utils.TableFormatXLSX))
+	switch format {
+	case "json":
+		rows, err := pc.exportSvc.GetExportRows(userID.(int))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export products: " + err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, rows)
+		return
+	case utils.TableFormatXLSX, utils.TableFormatCSV:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format. Must be 'xlsx', 'csv' or 'json'"})
+		return
+	}
+
+	// The product list is small enough to build in memory, so a failure can still be reported.
+	var buf bytes.Buffer
+	w, err := utils.NewTableWriter(format, &buf, "Produtos", service.ProductExportColumnWidths...)
+	if err == nil {
+		err = pc.exportSvc.ExportProducts(userID.(int), w)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export products: " + err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="produtos_estoque.`+format+`"`)
+	c.Data(http.StatusOK, utils.TableContentType(format), buf.Bytes())
+}
+
 // GetByID returns a specific product by ID
 // @Summary Get a product by ID
 // @Description Retrieves a product by its ID, including its lotes.
@@ -119,6 +178,9 @@ func (pc *ProductController) Create(c *gin.Context) {
 
 	err := pc.repo.Create(&product)
 	if err != nil {
+		if respondIfPeriodClosed(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product: " + err.Error()})
 		return
 	}
@@ -128,11 +190,12 @@ func (pc *ProductController) Create(c *gin.Context) {
 	changeDetail := models.ProductChange{
 		ProductID:      product.ID,
 		ProductName:    product.Name,
+		Unit:           product.Unit,
 		Action:         "created",
 		QuantityAfter:  &qtyAfter, // Assign address of qtyAfter
 		IsNewProduct:   true,
 	}
-	if err := pc.historySvc.RecordChange(service.EntityTypeProduct, product.ID, changeDetail, userID.(int), operationBatchID); err != nil {
+	if err := pc.historySvc.RecordChange(service.EntityTypeProduct, product.ID, changeDetail, actorFromContext(c, userID.(int)), operationBatchID); err != nil {
 		// Log or handle history recording error, but don't fail the main operation
 	}
 
@@ -233,6 +296,9 @@ func (pc *ProductController) Update(c *gin.Context) {
 
 	err = pc.repo.Update(&productToUpdate) // Pass the selectively updated product
 	if err != nil {
+		if respondIfPeriodClosed(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product: " + err.Error()})
 		return
 	}
@@ -244,7 +310,7 @@ func (pc *ProductController) Update(c *gin.Context) {
 			Action:        "product_details_updated",
 			ChangedFields: changedFields,
 		}
-		if histErr := pc.historySvc.RecordChange(service.EntityTypeProduct, productID, changeDetail, userID.(int), operationBatchID); histErr != nil {
+		if histErr := pc.historySvc.RecordChange(service.EntityTypeProduct, productID, changeDetail, actorFromContext(c, userID.(int)), operationBatchID); histErr != nil {
 			log.Printf("WARN: Failed to record history for product update %s: %v", productID, histErr)
 		}
 	}
@@ -292,6 +358,9 @@ func (pc *ProductController) Delete(c *gin.Context) {
 
 	err = pc.repo.Delete(productID, userID.(int))
 	if err != nil {
+		if respondIfPeriodClosed(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete product: " + err.Error()})
 		return
 	}
@@ -301,11 +370,12 @@ func (pc *ProductController) Delete(c *gin.Context) {
 	changeDetail := models.ProductChange{
 		ProductID:        productID,
 		ProductName:      existingProduct.Name,
+		Unit:             existingProduct.Unit,
 		Action:           "deleted",
 		QuantityBefore:   &qtyBefore, // Assign address of qtyBefore
 		IsProductRemoval: true,
 	}
-	if err := pc.historySvc.RecordChange(service.EntityTypeProduct, productID, changeDetail, userID.(int), operationBatchID); err != nil {
+	if err := pc.historySvc.RecordChange(service.EntityTypeProduct, productID, changeDetail, actorFromContext(c, userID.(int)), operationBatchID); err != nil {
 		// Log or handle history recording error
 	}
 
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// ProductParametersController handles the per-product parameters used by the reports
+type ProductParametersController struct {
+	repo        repository.ProductParametersRepository
+	productRepo repository.ProductRepository
+}
+
+// NewProductParametersController creates a new product parameters controller
+func NewProductParametersController(repo repository.ProductParametersRepository, productRepo repository.ProductRepository) *ProductParametersController {
+	return &ProductParametersController{repo: repo, productRepo: productRepo}
+}
+
+// Get godoc
+// @Summary Get the parameters of a product
+// @Description Returns the product's parameters (unit cost, minimum and maximum quantity, preferred supplier, storage location, SPED item code); unset parameters are null.
+// @Tags products
+// @Produce json
+// @Param product_id path string true "Product ID"
+// @Success 200 {object} models.ProductParameters
+// @Failure 404 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/products/{product_id}/parameters [get]
+// @Security BearerAuth
+func (ppc *ProductParametersController) Get(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	productID := c.Param("product_id")
+
+	product, err := ppc.productRepo.GetByID(productID, userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch product by ID: " + err.Error()})
+		return
+	}
+	if product == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	params, err := ppc.repo.Get(productID, userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch product parameters: " + err.Error()})
+		return
+	}
+	if params == nil {
+		params = &models.ProductParameters{ProductID: productID}
+	}
+	c.JSON(http.StatusOK, params)
+}
+
+// Update godoc
+// @Summary Replace the parameters of a product
+// @Description Saves the product's parameters; a parameter that is omitted or null is cleared.
+// @Tags products
+// @Accept json
+// @Produce json
+// @Param product_id path string true "Product ID"
+// @Param parameters body models.ProductParametersInput true "Parameters"
+// @Success 200 {object} models.ProductParameters
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 404 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/products/{product_id}/parameters [put]
+// @Security BearerAuth
+func (ppc *ProductParametersController) Update(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	productID := c.Param("product_id")
+
+	var input models.ProductParametersInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+	if input.MinQuantity != nil && input.MaxQuantity != nil && *input.MaxQuantity < *input.MinQuantity {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "maxQuantity must not be less than minQuantity"})
+		return
+	}
+
+	product, err := ppc.productRepo.GetByID(productID, userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch product by ID: " + err.Error()})
+		return
+	}
+	if product == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	params := &models.ProductParameters{
+		ProductID:                 productID,
+		UnitCost:                  input.UnitCost,
+		MinQuantity:               input.MinQuantity,
+		MaxQuantity:               input.MaxQuantity,
+		PreferredSupplierDocument: input.PreferredSupplierDocument,
+		Location:                  input.Location,
+		Code:                      input.Code,
+	}
+	if err := ppc.repo.Save(params, userID.(int)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save product parameters: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, params)
+}
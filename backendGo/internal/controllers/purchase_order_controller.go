@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// PurchaseOrderController handles reorder suggestions and the purchase orders drafted from them
+type PurchaseOrderController struct {
+	service service.ReorderService
+}
+
+// NewPurchaseOrderController creates a new purchase order controller
+func NewPurchaseOrderController(service service.ReorderService) *PurchaseOrderController {
+	return &PurchaseOrderController{service: service}
+}
+
+// GetSuggestions godoc
+// @Summary Get reorder suggestions
+// @Description Lists the products whose stock reaches the reorder point (minimum quantity plus consumption during the supplier's lead time) within the horizon, with the suggested quantity, order date and preferred supplier. The preferred supplier is the one set in the product parameters or, without one, the last supplier that delivered the product on an NF-e.
+// @Tags reports
+// @Produce json
+// @Param forecast_window query int false "Window in days of the consumption rate (default 30)"
+// @Param horizon query int false "Days ahead to look for reorder points (default 7)"
+// @Success 200 {object} models.ReorderSuggestionReport
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/reports/reorder-suggestions [get]
+// @Security BearerAuth
+func (pc *PurchaseOrderController) GetSuggestions(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	forecastDays := service.DefaultForecastWindowDays
+	if value := c.Query("forecast_window"); value != "" {
+		var err error
+		if forecastDays, err = parseWindowDays(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	horizonDays := service.DefaultReorderHorizonDays
+	if value := c.Query("horizon"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 0 || days > service.MaxConsumptionWindowDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid horizon. Must be a number of days between 0 and " + strconv.Itoa(service.MaxConsumptionWindowDays)})
+			return
+		}
+		horizonDays = days
+	}
+
+	report, err := pc.service.GetSuggestions(forecastDays, horizonDays, userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute reorder suggestions: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// CreateFromSuggestions godoc
+// @Summary Draft purchase orders from reorder suggestions
+// @Description Turns the selected products into draft purchase orders, one per supplier. Each item orders the quantity sent with it or, without one, the product's current suggested quantity.
+// @Tags purchase-orders
+// @Accept json
+// @Produce json
+// @Param input body models.DraftOrdersInput true "Selected products"
+// @Success 201 {array} models.PurchaseOrder
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/purchase-orders/from-suggestions [post]
+// @Security BearerAuth
+func (pc *PurchaseOrderController) CreateFromSuggestions(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var input models.DraftOrdersInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	orders, err := pc.service.CreateDraftOrders(input, userID.(int))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidDraftOrder) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create purchase orders: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, orders)
+}
+
+// List godoc
+// @Summary List purchase orders
+// @Description Lists the caller's purchase orders with their items, newest first.
+// @Tags purchase-orders
+// @Produce json
+// @Success 200 {array} models.PurchaseOrder
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/purchase-orders [get]
+// @Security BearerAuth
+func (pc *PurchaseOrderController) List(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	orders, err := pc.service.ListOrders(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list purchase orders: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, orders)
+}
+
+// GetByID godoc
+// @Summary Get a purchase order
+// @Tags purchase-orders
+// @Produce json
+// @Param id path int true "Purchase order ID"
+// @Success 200 {object} models.PurchaseOrder
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 404 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/purchase-orders/{id} [get]
+// @Security BearerAuth
+func (pc *PurchaseOrderController) GetByID(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	orderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid purchase order ID"})
+		return
+	}
+
+	order, err := pc.service.GetOrder(orderID, userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch purchase order: " + err.Error()})
+		return
+	}
+	if order == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Purchase order not found"})
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
@@ -0,0 +1,433 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ReportController handles stock report requests
+type ReportController struct {
+	stockSvc       service.StockReportService
+	consumptionSvc service.ConsumptionService
+	inventorySvc   service.InventorySheetService
+	spedSvc        service.SpedService
+}
+
+// NewReportController creates a new report controller
+func NewReportController(stockSvc service.StockReportService, consumptionSvc service.ConsumptionService, inventorySvc service.InventorySheetService, spedSvc service.SpedService) *ReportController {
+	return &ReportController{stockSvc: stockSvc, consumptionSvc: consumptionSvc, inventorySvc: inventorySvc, spedSvc: spedSvc}
+}
+
+// parseReportDate accepts either an RFC3339 timestamp or a plain YYYY-MM-DD date.
+// A plain date means the end of that day in the server's timezone, so
+// "stock on 2025-03-01" includes every change made on March 1st.
+func parseReportDate(value string) (time.Time, error) {
+	return parseDateBound(value, true)
+}
+
+// parseDateBound accepts either an RFC3339 timestamp or a plain YYYY-MM-DD date, which
+// is taken as the start (endOfDay false) or the end (endOfDay true) of that day in the server's timezone.
+func parseDateBound(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD or RFC3339", value)
+	}
+	if endOfDay {
+		return day.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+	}
+	return day, nil
+}
+
+// GetStockAt godoc
+// @Summary Get stock at a past instant
+// @Description Reconstructs the quantity of every product and lote at the given date from snapshots and history.
+// @Tags reports
+// @Produce json
+// @Param date query string true "YYYY-MM-DD (end of day) or RFC3339 timestamp"
+// @Success 200 {object} models.StockAtReport
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/reports/stock-at [get]
+// @Security BearerAuth
+func (rc *ReportController) GetStockAt(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	dateQuery := c.Query("date")
+	if dateQuery == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date query parameter is required"})
+		return
+	}
+	at, err := parseReportDate(dateQuery)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := rc.stockSvc.GetStockAt(at, userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reconstruct stock: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// GetStockDiff godoc
+// @Summary Compare stock between two dates
+// @Description Lists products and lotes whose quantity differs between 'from' and 'to'.
+// @Tags reports
+// @Produce json
+// @Param from query string true "YYYY-MM-DD (end of day) or RFC3339 timestamp"
+// @Param to query string true "YYYY-MM-DD (end of day) or RFC3339 timestamp"
+// @Success 200 {object} models.StockDiffReport
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/reports/stock-diff [get]
+// @Security BearerAuth
+func (rc *ReportController) GetStockDiff(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	fromQuery, toQuery := c.Query("from"), c.Query("to")
+	if fromQuery == "" || toQuery == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to query parameters are required"})
+		return
+	}
+	from, err := parseReportDate(fromQuery)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	to, err := parseReportDate(toQuery)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'from' must not be after 'to'"})
+		return
+	}
+
+	report, err := rc.stockSvc.GetStockDiff(from, to, userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compare stock: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// CreateSnapshot godoc
+// @Summary Take a stock snapshot now
+// @Description Stores the current products and lotes as a base for future point-in-time reports.
+// @Tags reports
+// @Produce json
+// @Success 201 {object} gin.H{"message": "string", "takenAt": "string"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/reports/snapshots [post]
+// @Security BearerAuth
+func (rc *ReportController) CreateSnapshot(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	takenAt, err := rc.stockSvc.TakeSnapshot(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to take snapshot: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Snapshot created successfully", "takenAt": takenAt})
+}
+
+// parseWindowDays parses a number of days between 1 and service.MaxConsumptionWindowDays.
+func parseWindowDays(value string) (int, error) {
+	days, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || days < 1 || days > service.MaxConsumptionWindowDays {
+		return 0, fmt.Errorf("invalid window %q, expected a number of days between 1 and %d", value, service.MaxConsumptionWindowDays)
+	}
+	return days, nil
+}
+
+// GetConsumption godoc
+// @Summary Get consumption statistics and stock-out forecast
+// @Description For every product, computes the consumption (lote quantity decreases and removals of lotes not yet expired) over each window, its seasonality by calendar month over the last 24 months, and the days of cover and projected stock-out date at the average daily consumption of the forecast window.
+// @Tags reports
+// @Produce json
+// @Param windows query string false "Comma-separated windows in days (default 7,30,90)"
+// @Param forecast_window query int false "Window in days of the consumption rate used by the forecast (default 30)"
+// @Success 200 {object} models.ConsumptionReport
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/reports/consumption [get]
+// @Security BearerAuth
+func (rc *ReportController) GetConsumption(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	windows := service.DefaultConsumptionWindows
+	if value := c.Query("windows"); value != "" {
+		windows = nil
+		for _, part := range strings.Split(value, ",") {
+			days, err := parseWindowDays(part)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			windows = append(windows, days)
+		}
+	}
+	forecastDays := service.DefaultForecastWindowDays
+	if value := c.Query("forecast_window"); value != "" {
+		var err error
+		if forecastDays, err = parseWindowDays(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	report, err := rc.consumptionSvc.GetConsumptionReport(windows, forecastDays, userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute consumption: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// GetExpiryWaste godoc
+// @Summary Get the projected expiry waste
+// @Description Combines each product's consumption rate with the expiry dates of its lotes, consumed first-expired first-out, to estimate how much of each lote will expire before it is used, valued at the product's unit cost when one is set.
+// @Tags reports
+// @Produce json
+// @Param forecast_window query int false "Window in days of the consumption rate (default 30)"
+// @Success 200 {object} models.ExpiryWasteReport
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/reports/expiry-waste [get]
+// @Security BearerAuth
+func (rc *ReportController) GetExpiryWaste(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	forecastDays := service.DefaultForecastWindowDays
+	if value := c.Query("forecast_window"); value != "" {
+		var err error
+		if forecastDays, err = parseWindowDays(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	report, err := rc.consumptionSvc.GetExpiryWasteReport(forecastDays, userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to project expiry waste: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// parseSharePercent reads a cumulative share limit of the ABC analysis, a percentage between 0 and 100.
+func parseSharePercent(value string) (float64, error) {
+	share, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || share <= 0 || share > 100 {
+		return 0, fmt.Errorf("invalid share %q, expected a percentage greater than 0 and at most 100", value)
+	}
+	return share, nil
+}
+
+// GetABC godoc
+// @Summary Get the ABC classification of the products
+// @Description Values each product's consumption (lote quantity decreases and removals of lotes not yet expired) in the period at its unit cost and classifies the products by cumulative share of the total value: class A up to class_a percent, class B up to class_b percent, class C for the rest. Products without a unit cost are listed last, without a class.
+// @Tags reports
+// @Produce json
+// @Param from query string false "YYYY-MM-DD (start of day) or RFC3339 timestamp (default 90 days before 'to')"
+// @Param to query string false "YYYY-MM-DD (end of day) or RFC3339 timestamp (default now)"
+// @Param class_a query number false "Cumulative share in percent covered by class A (default 80)"
+// @Param class_b query number false "Cumulative share in percent covered by classes A and B (default 95)"
+// @Success 200 {object} models.ABCReport
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/reports/abc [get]
+// @Security BearerAuth
+func (rc *ReportController) GetABC(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var err error
+	to := time.Now()
+	if value := c.Query("to"); value != "" {
+		if to, err = parseDateBound(value, true); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	from := to.AddDate(0, 0, -service.DefaultABCPeriodDays)
+	if value := c.Query("from"); value != "" {
+		if from, err = parseDateBound(value, false); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'from' must not be after 'to'"})
+		return
+	}
+
+	classA, classB := service.DefaultABCClassALimit, service.DefaultABCClassBLimit
+	if value := c.Query("class_a"); value != "" {
+		if classA, err = parseSharePercent(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if value := c.Query("class_b"); value != "" {
+		if classB, err = parseSharePercent(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if classB < classA {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "class_b must not be less than class_a"})
+		return
+	}
+
+	report, err := rc.consumptionSvc.GetABCReport(from, to, classA, classB, userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to classify products: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// GetAging godoc
+// @Summary Get the stock aging report
+// @Description Buckets the quantity of every lote by the days since it entered stock and by the days until it expires, per product and per unit, and flags slow movers: stock that is on average at least 90 days old and would last more than 180 days at the consumption rate of the forecast window (or is not consumed at all).
+// @Tags reports
+// @Produce json
+// @Param forecast_window query int false "Window in days of the consumption rate (default 30)"
+// @Success 200 {object} models.StockAgingReport
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/reports/aging [get]
+// @Security BearerAuth
+func (rc *ReportController) GetAging(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	forecastDays := service.DefaultForecastWindowDays
+	if value := c.Query("forecast_window"); value != "" {
+		var err error
+		if forecastDays, err = parseWindowDays(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	report, err := rc.consumptionSvc.GetAgingReport(forecastDays, userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build aging report: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// GetInventoryPDF godoc
+// @Summary Print the inventory sheet
+// @Description Produces an A4 PDF with every product and its lotes (expiry date, quantity), the products' storage locations, a blank column for the counted quantity, totals per unit and signature lines. Every page carries the generation time, the user and its number.
+// @Tags reports
+// @Produce application/pdf
+// @Success 200 {file} file
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/reports/inventory.pdf [get]
+// @Security BearerAuth
+func (rc *ReportController) GetInventoryPDF(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// The sheet is built in memory, so a failure can still be reported.
+	var buf bytes.Buffer
+	if err := rc.inventorySvc.WriteInventoryPDF(actorFromContext(c, userID.(int)), &buf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate inventory sheet: " + err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="inventario_%s.pdf"`, time.Now().Format("2006-01-02")))
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
+
+// GetSpedBlocoH godoc
+// @Summary Export the SPED Fiscal inventory (Bloco H)
+// @Description Generates block H of the SPED Fiscal (EFD ICMS/IPI) file: H001, H005, one H010 per product in stock and H990, in ISO-8859-1. The inventory comes from a monthly closing, valued at its frozen unit costs, or from the stock at the end of a day, valued at the current unit costs. Every product in stock needs an item code (the COD_ITEM of its register 0200), a unit and a unit cost in its parameters; otherwise nothing is exported and the missing fields are listed.
+// @Tags reports
+// @Produce plain
+// @Param closing query string false "Closed month (YYYY-MM); either closing or date is required"
+// @Param date query string false "Inventory date (YYYY-MM-DD)"
+// @Param account query string false "Accounting account of the items (COD_CTA)"
+// @Success 200 {file} file
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 404 {object} gin.H{"error": "message"}
+// @Failure 422 {object} gin.H{"error": "message", "issues": []models.SpedIssue}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/reports/sped-bloco-h [get]
+// @Security BearerAuth
+func (rc *ReportController) GetSpedBlocoH(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	opts := models.SpedBlocoHOptions{
+		Closing: c.Query("closing"),
+		Date:    c.Query("date"),
+		Account: strings.TrimSpace(c.Query("account")),
+	}
+	blocoH, err := rc.spedSvc.BuildBlocoH(opts, userID.(int))
+	var validationErr *service.SpedValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Mandatory SPED fields are missing or invalid", "issues": validationErr.Issues})
+	case errors.Is(err, service.ErrInvalidSpedOptions), errors.Is(err, service.ErrInvalidClosingPeriod):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrClosingNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate SPED Bloco H: " + err.Error()})
+	default:
+		day, _ := time.Parse("2006-01-02", blocoH.InventoryDate)
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="bloco_h_%s.txt"`, day.Format("02012006")))
+		c.Data(http.StatusOK, "text/plain; charset=iso-8859-1", blocoH.Content)
+	}
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// supplierDocumentPattern matches a CPF (11 digits) or a CNPJ (14 digits) without punctuation.
+var supplierDocumentPattern = regexp.MustCompile(`^(\d{11}|\d{14})$`)
+
+// SupplierController handles the suppliers and their lead times
+type SupplierController struct {
+	repo repository.SupplierRepository
+}
+
+// NewSupplierController creates a new supplier controller
+func NewSupplierController(repo repository.SupplierRepository) *SupplierController {
+	return &SupplierController{repo: repo}
+}
+
+// List godoc
+// @Summary List suppliers
+// @Description Lists the caller's suppliers with their lead times.
+// @Tags suppliers
+// @Produce json
+// @Success 200 {array} models.Supplier
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/suppliers [get]
+// @Security BearerAuth
+func (sc *SupplierController) List(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	suppliers, err := sc.repo.List(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list suppliers: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, suppliers)
+}
+
+// Save godoc
+// @Summary Create or replace a supplier
+// @Description Saves the name and lead time (days from order to receipt) of the supplier with the given CNPJ or CPF, digits only.
+// @Tags suppliers
+// @Accept json
+// @Produce json
+// @Param document path string true "CNPJ (14 digits) or CPF (11 digits)"
+// @Param supplier body models.SupplierInput true "Supplier"
+// @Success 200 {object} models.Supplier
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/suppliers/{document} [put]
+// @Security BearerAuth
+func (sc *SupplierController) Save(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	document := c.Param("document")
+	if !supplierDocumentPattern.MatchString(document) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document. Must be a CNPJ (14 digits) or CPF (11 digits)"})
+		return
+	}
+	var input models.SupplierInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	supplier := &models.Supplier{Document: document, Name: input.Name, LeadTimeDays: input.LeadTimeDays}
+	if err := sc.repo.Save(supplier, userID.(int)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save supplier: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, supplier)
+}
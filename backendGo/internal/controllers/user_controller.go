@@ -0,0 +1,314 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// UserController handles the user accounts
+type UserController struct {
+	service service.UserService
+}
+
+// NewUserController creates a new user controller
+func NewUserController(service service.UserService) *UserController {
+	return &UserController{service: service}
+}
+
+// respondUserError answers the errors shared by the user routes that change accounts.
+func respondUserError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrUsernameTaken), errors.Is(err, service.ErrEmailTaken), errors.Is(err, service.ErrProtectedUser):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrNewAccount):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + ": " + err.Error()})
+	}
+}
+
+// List godoc
+// @Summary List users
+// @Description Lists the users of the caller's account with their profile, status and last login. Admin only.
+// @Tags users
+// @Produce json
+// @Success 200 {array} models.User
+// @Failure 403 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/users [get]
+// @Security BearerAuth
+func (uc *UserController) List(c *gin.Context) {
+	accountID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	users, err := uc.service.List(accountID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list users: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, users)
+}
+
+// Get godoc
+// @Summary Get a user
+// @Description Returns the profile, status and last login of a user of the caller's account; users of other accounts are not found. Admin only.
+// @Tags users
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} models.User
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 403 {object} gin.H{"error": "message"}
+// @Failure 404 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/users/{id} [get]
+// @Security BearerAuth
+func (uc *UserController) Get(c *gin.Context) {
+	accountID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := uc.service.Get(id, accountID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user: " + err.Error()})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// Create godoc
+// @Summary Create a user
+// @Description Creates an active user as a member of the caller's account: the user works on the account's products, lotes and history, within what the role allows. The role defaults to operator. With newAccount, which only the ADMIN_USERNAME account may use (403 otherwise), the user owns a new, empty account instead and the role defaults to admin. Admin only.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param user body models.UserCreateInput true "User"
+// @Success 201 {object} models.User
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 403 {object} gin.H{"error": "message"}
+// @Failure 409 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/users [post]
+// @Security BearerAuth
+func (uc *UserController) Create(c *gin.Context) {
+	accountID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var input models.UserCreateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	user, err := uc.service.Create(input, accountID.(int))
+	if err != nil {
+		respondUserError(c, err, "create user")
+		return
+	}
+	c.JSON(http.StatusCreated, user)
+}
+
+// Update godoc
+// @Summary Update a user
+// @Description Replaces a user's email and full name (omitted fields are cleared) and, when given, the role and the password, all at once. Tokens issued with the previous role or password stop being accepted. The username cannot change, and the admin account keeps the admin role. Users of other accounts are not found. Admin only.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param user body models.UserUpdateInput true "User profile"
+// @Success 200 {object} models.User
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 403 {object} gin.H{"error": "message"}
+// @Failure 404 {object} gin.H{"error": "message"}
+// @Failure 409 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/users/{id} [put]
+// @Security BearerAuth
+func (uc *UserController) Update(c *gin.Context) {
+	accountID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	var input models.UserUpdateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	user, err := uc.service.Update(id, input, accountID.(int))
+	if err != nil {
+		respondUserError(c, err, "update user")
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// setActive enables or disables the user of the route, if the caller's account has it.
+func (uc *UserController) setActive(c *gin.Context, active bool) {
+	accountID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := uc.service.SetActive(id, active, accountID.(int))
+	if err != nil {
+		respondUserError(c, err, "update user status")
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// Disable godoc
+// @Summary Disable a user
+// @Description Disables a user, keeping their data: they cannot sign in and their tokens stop being accepted. The admin account cannot be disabled. Admin only.
+// @Tags users
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} models.User
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 403 {object} gin.H{"error": "message"}
+// @Failure 404 {object} gin.H{"error": "message"}
+// @Failure 409 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/users/{id}/disable [post]
+// @Security BearerAuth
+func (uc *UserController) Disable(c *gin.Context) {
+	uc.setActive(c, false)
+}
+
+// Enable godoc
+// @Summary Enable a user
+// @Description Enables a disabled user again. Admin only.
+// @Tags users
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} models.User
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 403 {object} gin.H{"error": "message"}
+// @Failure 404 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/users/{id}/enable [post]
+// @Security BearerAuth
+func (uc *UserController) Enable(c *gin.Context) {
+	uc.setActive(c, true)
+}
+
+// Delete godoc
+// @Summary Delete a user
+// @Description Removes a user with everything they own: products, lotes, history and its archives, closings, suppliers, orders, settings and the members of their account. Removing a member leaves the account's data in place. The admin account cannot be deleted, and users of other accounts are not found. Admin only.
+// @Tags users
+// @Param id path int true "User ID"
+// @Success 204
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 403 {object} gin.H{"error": "message"}
+// @Failure 404 {object} gin.H{"error": "message"}
+// @Failure 409 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/users/{id} [delete]
+// @Security BearerAuth
+func (uc *UserController) Delete(c *gin.Context) {
+	accountID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	found, err := uc.service.Delete(id, accountID.(int))
+	if err != nil {
+		respondUserError(c, err, "delete user")
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ChangePassword godoc
+// @Summary Change one's own password
+// @Description Replaces the caller's password after checking the current one. Every token already issued, including the one of this request, stops being accepted, so the user signs in again.
+// @Tags auth
+// @Accept json
+// @Param password body models.PasswordChangeInput true "Current and new password"
+// @Success 204
+// @Failure 400 {object} gin.H{"error": "message"}
+// @Failure 500 {object} gin.H{"error": "message"}
+// @Router /api/auth/password [put]
+// @Security BearerAuth
+func (uc *UserController) ChangePassword(c *gin.Context) {
+	userID, exists := c.Get("actorUserID")
+	accountID, accountExists := c.Get("userID")
+	if !exists || !accountExists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var input models.PasswordChangeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	found, err := uc.service.ChangePassword(userID.(int), accountID.(int), input)
+	switch {
+	case errors.Is(err, service.ErrWrongPassword):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password: " + err.Error()})
+	case !found:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
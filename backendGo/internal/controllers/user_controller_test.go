@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/repository"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// accountUserRepo keeps users in memory and, like the SQL queries, only finds the users of the
+// given account.
+type accountUserRepo struct {
+	users map[int]*models.User
+}
+
+func (r *accountUserRepo) find(id, accountID int) *models.User {
+	if user := r.users[id]; user != nil && user.AccountID == accountID {
+		return user
+	}
+	return nil
+}
+
+func (r *accountUserRepo) List(accountID int) ([]models.User, error) {
+	users := []models.User{}
+	for _, user := range r.users {
+		if user.AccountID == accountID {
+			users = append(users, *user)
+		}
+	}
+	return users, nil
+}
+
+func (r *accountUserRepo) GetByID(id, accountID int) (*models.User, error) {
+	if user := r.find(id, accountID); user != nil {
+		copied := *user
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (r *accountUserRepo) Create(user *models.User, accountID int) error {
+	user.ID = len(r.users) + 100
+	user.AccountID = accountID
+	if accountID == 0 {
+		user.AccountID = user.ID
+	}
+	r.users[user.ID] = user
+	return nil
+}
+
+func (r *accountUserRepo) Update(user *models.User, passwordHash string, accountID int) (bool, error) {
+	stored := r.find(user.ID, accountID)
+	if stored == nil {
+		return false, nil
+	}
+	stored.Email, stored.FullName = user.Email, user.FullName
+	if user.Role != "" {
+		stored.Role = user.Role
+	}
+	*user = *stored
+	return true, nil
+}
+
+func (r *accountUserRepo) SetActive(id int, active bool, accountID int) (bool, error) {
+	stored := r.find(id, accountID)
+	if stored != nil {
+		stored.Active = active
+	}
+	return stored != nil, nil
+}
+
+func (r *accountUserRepo) SetPassword(id int, passwordHash string, accountID int) (bool, error) {
+	stored := r.find(id, accountID)
+	if stored != nil {
+		stored.Password = passwordHash
+	}
+	return stored != nil, nil
+}
+
+func (r *accountUserRepo) Delete(id, accountID int) (bool, error) {
+	if r.find(id, accountID) == nil {
+		return false, nil
+	}
+	delete(r.users, id)
+	return true, nil
+}
+
+type noArchiveRepo struct {
+	repository.HistoryArchiveRepository
+}
+
+func (noArchiveRepo) List(userID int) ([]models.HistoryArchive, error) {
+	return nil, nil
+}
+
+func newAccountUserRouter(repo *accountUserRepo, accountID int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	uc := NewUserController(service.NewUserService(repo, noArchiveRepo{}, "", "admin"))
+	router := gin.New()
+	users := router.Group("/api/users", func(c *gin.Context) {
+		c.Set("userID", accountID)
+		c.Set("actorUserID", accountID)
+	})
+	users.GET("", uc.List)
+	users.POST("", uc.Create)
+	users.GET("/:id", uc.Get)
+	users.PUT("/:id", uc.Update)
+	users.POST("/:id/disable", uc.Disable)
+	users.POST("/:id/enable", uc.Enable)
+	users.DELETE("/:id", uc.Delete)
+	return router
+}
+
+func TestUserRoutesAreScopedToTheAccount(t *testing.T) {
+	repo := &accountUserRepo{users: map[int]*models.User{
+		1: {ID: 1, Username: "admin", Role: models.RoleAdmin, Active: true, AccountID: 1},
+		2: {ID: 2, Username: "maria", Role: models.RoleOperator, Active: true, AccountID: 1},
+		5: {ID: 5, Username: "joao", Role: models.RoleAdmin, Active: true, AccountID: 5},
+		6: {ID: 6, Username: "ana", Role: models.RoleOperator, Active: true, AccountID: 5},
+	}}
+	router := newAccountUserRouter(repo, 1)
+
+	requests := []struct {
+		method, path, body string
+		wantStatus         int
+	}{
+		{http.MethodGet, "/api/users/2", "", http.StatusOK},
+		{http.MethodGet, "/api/users/6", "", http.StatusNotFound},
+		{http.MethodPut, "/api/users/6", `{"role": "viewer"}`, http.StatusNotFound},
+		{http.MethodPost, "/api/users/6/disable", "", http.StatusNotFound},
+		{http.MethodPost, "/api/users/5/enable", "", http.StatusNotFound},
+		{http.MethodDelete, "/api/users/6", "", http.StatusNotFound},
+		{http.MethodDelete, "/api/users/5", "", http.StatusNotFound},
+	}
+	for _, tc := range requests {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tc.wantStatus {
+			t.Errorf("%s %s: status %d, want %d: %s", tc.method, tc.path, w.Code, tc.wantStatus, w.Body.String())
+		}
+	}
+
+	for _, id := range []int{5, 6} {
+		user := repo.users[id]
+		if user == nil || !user.Active || (id == 6 && user.Role != models.RoleOperator) {
+			t.Errorf("user %d of another account was changed: %+v", id, user)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/users", nil))
+	var listed []models.User
+	if err := json.Unmarshal(w.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("decode users: %v", err)
+	}
+	if len(listed) != 2 {
+		t.Errorf("listed %d users, want the 2 of the account: %+v", len(listed), listed)
+	}
+	for _, user := range listed {
+		if user.AccountID != 1 {
+			t.Errorf("listed user %d of account %d", user.ID, user.AccountID)
+		}
+	}
+}
+
+func TestOnlyTheAdminAccountCreatesNewAccounts(t *testing.T) {
+	repo := &accountUserRepo{users: map[int]*models.User{
+		1: {ID: 1, Username: "admin", Role: models.RoleAdmin, Active: true, AccountID: 1},
+		5: {ID: 5, Username: "joao", Role: models.RoleAdmin, Active: true, AccountID: 5},
+	}}
+	body := `{"username": "carla", "password": "secret123", "newAccount": true}`
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/users", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	newAccountUserRouter(repo, 5).ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("another account: status %d, want 403: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/api/users", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	newAccountUserRouter(repo, 1).ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("admin account: status %d, want 201: %s", w.Code, w.Body.String())
+	}
+	var created models.User
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode user: %v", err)
+	}
+	if created.AccountID != created.ID || created.Role != models.RoleAdmin {
+		t.Errorf("created user %d in account %d with role %q, want its own account and role admin", created.ID, created.AccountID, created.Role)
+	}
+}
@@ -13,8 +13,9 @@ import (
 // DB is the global database connection
 var DB *sql.DB
 
-// InitDB initializes the database connection using the provided configuration
-func InitDB(cfg *config.Config) error {
+// Connect opens and pings a database connection without creating tables or data.
+// Used by command-line tools that must not alter the database on startup.
+func Connect(cfg *config.Config) (*sql.DB, error) {
     // Create connection string
     connStr := fmt.Sprintf(
         "host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
@@ -23,15 +24,26 @@ func InitDB(cfg *config.Config) error {
     )
 
     // Connect to the database
-    var err error
-    DB, err = sql.Open("postgres", connStr)
+    db, err := sql.Open("postgres", connStr)
     if err != nil {
-        return fmt.Errorf("error connecting to database: %w", err)
+        return nil, fmt.Errorf("error connecting to database: %w", err)
     }
 
     // Test the connection
-    if err = DB.Ping(); err != nil {
-        return fmt.Errorf("error pinging database: %w", err)
+    if err = db.Ping(); err != nil {
+        db.Close()
+        return nil, fmt.Errorf("error pinging database: %w", err)
+    }
+
+    return db, nil
+}
+
+// InitDB initializes the database connection using the provided configuration
+func InitDB(cfg *config.Config) error {
+    var err error
+    DB, err = Connect(cfg)
+    if err != nil {
+        return err
     }
 
     log.Println("Database connection established successfully")
@@ -94,7 +106,7 @@ func initTables() error {
 	_, err = DB.Exec(`
         CREATE TABLE IF NOT EXISTS history (
             id VARCHAR(100) PRIMARY KEY,
-            date VARCHAR(100) NOT NULL, -- Ideally TIMESTAMP WITH TIME ZONE
+            date TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
             entity_type VARCHAR(50),    -- Added
             entity_id VARCHAR(100),   -- Added
             changes JSONB NOT NULL
@@ -154,17 +166,28 @@ func createAdminUser(username, password string) error {
         }
 
         // Insert admin user
-        _, err = DB.Exec("INSERT INTO users (username, password) VALUES ($1, $2)", username, string(hashedPassword))
+        _, err = DB.Exec("INSERT INTO users (username, password, role) VALUES ($1, $2, 'admin')", username, string(hashedPassword))
         if err != nil {
             return err
         }
         log.Printf("Admin user created successfully: %s\n", username)
+        return nil
+    }
+
+    // The admin account always keeps the admin role (existing users became managers in migration 019)
+    result, err := DB.Exec("UPDATE users SET role = 'admin' WHERE username = $1 AND role <> 'admin'", username)
+    if err != nil {
+        return err
+    }
+    if promoted, _ := result.RowsAffected(); promoted > 0 {
+        log.Printf("Admin user promoted to the admin role: %s\n", username)
     }
 
     return nil
 }
 
-// createTestUser cria um usuário com credenciais "teste"/"teste" caso não exista
+// createTestUser cria um usuário com credenciais "teste"/"teste" caso não exista. O perfil
+// manager é explícito: o padrão da coluna difere entre bancos novos e migrados.
 func createTestUser(username, password string) error {
     var count int
     err := DB.QueryRow("SELECT COUNT(*) FROM users WHERE username = $1", username).Scan(&count)
@@ -177,7 +200,7 @@ func createTestUser(username, password string) error {
             return err
         }
         if _, err := DB.Exec(
-            "INSERT INTO users (username, password) VALUES ($1, $2)",
+            "INSERT INTO users (username, password, role) VALUES ($1, $2, 'manager')",
             username, string(hashedPassword),
         ); err != nil {
             return err
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"database/sql"
 	"fmt"
 	"net/http"
 	"os"
@@ -8,6 +9,7 @@ import (
 	"time"
 
 	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/config"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/database"
 	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -15,18 +17,22 @@ import (
 
 // Claims represents the JWT claims structure
 type Claims struct {
-    UserID   int    `json:"userID"`
-    Username string `json:"username"`
+    UserID       int    `json:"userID"`
+    Username     string `json:"username"`
+    Role         string `json:"role"`
+    TokenVersion int    `json:"tokenVersion"` // users.token_version when issued, bumped by password changes
     jwt.RegisteredClaims
 }
 
 // GenerateToken generates a new JWT token for a user
-func GenerateToken(userID int, username string, cfg *config.Config) (string, error) {
+func GenerateToken(userID int, username, role string, tokenVersion int, cfg *config.Config) (string, error) {
     expirationTime := time.Now().Add(cfg.JWT.Expiration)
 
     claims := &Claims{
-        UserID:   userID,
-        Username: username,
+        UserID:       userID,
+        Username:     username,
+        Role:         role,
+        TokenVersion: tokenVersion,
         RegisteredClaims: jwt.RegisteredClaims{
             ExpiresAt: jwt.NewNumericDate(expirationTime),
             IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -39,7 +45,32 @@ func GenerateToken(userID int, username string, cfg *config.Config) (string, err
     return tokenString, err
 }
 
-// AuthMiddleware is a middleware function for authentication
+// authUser is what AuthMiddleware checks of the user behind a token
+type authUser struct {
+    Active       bool
+    Role         string
+    TokenVersion int
+    AccountID    int // Owner of the account the user works on; the user's own ID for owners
+}
+
+// lookupAuthUser loads the user behind a token, or nil if there is no such user. It is a variable
+// so tests can stand in for the database.
+var lookupAuthUser = func(userID int) (*authUser, error) {
+    var user authUser
+    err := database.DB.QueryRow("SELECT active, role, token_version, COALESCE(account_id, id) FROM users WHERE id = $1", userID).
+        Scan(&user.Active, &user.Role, &user.TokenVersion, &user.AccountID)
+    if err == sql.ErrNoRows {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    return &user, nil
+}
+
+// AuthMiddleware is a middleware function for authentication. It sets "userID" to the owner of the
+// account the user works on, which scopes every read and write, and "actorUserID", "username" and
+// "role" to the signed-in user.
 func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
     return func(c *gin.Context) {
         authHeader := c.GetHeader("Authorization")
@@ -81,9 +112,35 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
             return
         }
 
-        // Set username and userID in context for later use
-        c.Set("userID", claims.UserID)
+        // Disabled and deleted users lose access even with an unexpired token, and so do tokens
+        // issued before a role or password change
+        user, err := lookupAuthUser(claims.UserID)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user: " + err.Error()})
+            c.Abort()
+            return
+        }
+        if user == nil || !user.Active {
+            c.JSON(http.StatusUnauthorized, gin.H{"error": "User account is disabled or no longer exists"})
+            c.Abort()
+            return
+        }
+        if user.Role != claims.Role {
+            c.JSON(http.StatusUnauthorized, gin.H{"error": "User role changed, please sign in again"})
+            c.Abort()
+            return
+        }
+        if user.TokenVersion != claims.TokenVersion {
+            c.JSON(http.StatusUnauthorized, gin.H{"error": "User password changed, please sign in again"})
+            c.Abort()
+            return
+        }
+
+        // Set the account, the user and the role in context for later use
+        c.Set("userID", user.AccountID)
+        c.Set("actorUserID", claims.UserID)
         c.Set("username", claims.Username)
+        c.Set("role", claims.Role)
         c.Next()
     }
 }
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// Permission allows the writes of a group of routes. Reads only need authentication.
+type Permission string
+
+const (
+	PermProductsWrite Permission = "products:write" // Products, their parameters, imports, NF-e, suppliers and purchase orders
+	PermLotesWrite    Permission = "lotes:write"    // Lotes
+	PermHistoryWrite  Permission = "history:write"  // History entries and batches
+	PermStockManage   Permission = "stock:manage"   // Closings, snapshots, consistency fixes and email reports
+	PermBackups       Permission = "backups"        // Account export and restore, history archives
+	PermUsers         Permission = "users"          // User management
+	PermAudit         Permission = "audit"          // History audit trail with the hash chain links
+)
+
+// rolePermissions lists what each role may do besides reading.
+var rolePermissions = map[string][]Permission{
+	models.RoleAdmin:    {PermProductsWrite, PermLotesWrite, PermHistoryWrite, PermStockManage, PermBackups, PermUsers, PermAudit},
+	models.RoleManager:  {PermProductsWrite, PermLotesWrite, PermHistoryWrite, PermStockManage},
+	models.RoleOperator: {PermLotesWrite, PermHistoryWrite},
+	models.RoleViewer:   {},
+}
+
+// HasPermission reports whether the role grants the permission. Unknown roles grant nothing.
+func HasPermission(role string, permission Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// RequirePermission answers 403 unless the role of the authenticated user, set by AuthMiddleware,
+// grants the permission. It must run after AuthMiddleware.
+func RequirePermission(permission Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		if !HasPermission(role, permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Role " + role + " is not allowed to perform this action", "permission": permission})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
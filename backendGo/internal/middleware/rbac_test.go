@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/config"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+var allPermissions = []Permission{PermProductsWrite, PermLotesWrite, PermHistoryWrite, PermStockManage, PermBackups, PermUsers, PermAudit}
+
+// rbacCases lists, for every role, the permissions it must grant; every other one must be denied.
+var rbacCases = []struct {
+	role    string
+	granted []Permission
+}{
+	{models.RoleAdmin, allPermissions},
+	{models.RoleManager, []Permission{PermProductsWrite, PermLotesWrite, PermHistoryWrite, PermStockManage}},
+	{models.RoleOperator, []Permission{PermLotesWrite, PermHistoryWrite}},
+	{models.RoleViewer, nil},
+	{"unknown", nil},
+}
+
+func granted(perms []Permission, perm Permission) bool {
+	for _, p := range perms {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+func TestHasPermission(t *testing.T) {
+	for _, tc := range rbacCases {
+		for _, perm := range allPermissions {
+			want := granted(tc.granted, perm)
+			if got := HasPermission(tc.role, perm); got != want {
+				t.Errorf("HasPermission(%q, %q) = %v, want %v", tc.role, perm, got, want)
+			}
+		}
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, tc := range rbacCases {
+		for _, perm := range allPermissions {
+			called := false
+			router := gin.New()
+			router.GET("/",
+				func(c *gin.Context) { c.Set("role", tc.role) },
+				RequirePermission(perm),
+				func(c *gin.Context) {
+					called = true
+					c.Status(http.StatusOK)
+				})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			wantStatus := http.StatusForbidden
+			if granted(tc.granted, perm) {
+				wantStatus = http.StatusOK
+			}
+			if w.Code != wantStatus {
+				t.Errorf("role %q, permission %q: status %d, want %d", tc.role, perm, w.Code, wantStatus)
+			}
+			if called != (wantStatus == http.StatusOK) {
+				t.Errorf("role %q, permission %q: next handler called = %v", tc.role, perm, called)
+			}
+		}
+	}
+}
+
+func TestAuthMiddlewareChecksTokenAgainstUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{JWT: config.JWTConfig{Secret: "test-secret", Expiration: time.Hour}}
+	token, err := GenerateToken(7, "maria", models.RoleManager, 2, cfg)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+
+	cases := []struct {
+		name       string
+		user       *authUser
+		wantStatus int
+	}{
+		{"current user", &authUser{Active: true, Role: models.RoleManager, TokenVersion: 2, AccountID: 1}, http.StatusOK},
+		{"role changed", &authUser{Active: true, Role: models.RoleViewer, TokenVersion: 2, AccountID: 1}, http.StatusUnauthorized},
+		{"password changed", &authUser{Active: true, Role: models.RoleManager, TokenVersion: 3, AccountID: 1}, http.StatusUnauthorized},
+		{"disabled", &authUser{Active: false, Role: models.RoleManager, TokenVersion: 2, AccountID: 1}, http.StatusUnauthorized},
+		{"deleted", nil, http.StatusUnauthorized},
+	}
+
+	defer func(lookup func(int) (*authUser, error)) { lookupAuthUser = lookup }(lookupAuthUser)
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			lookupAuthUser = func(userID int) (*authUser, error) {
+				if userID != 7 {
+					t.Errorf("looked up user %d, want 7", userID)
+				}
+				return tc.user, nil
+			}
+
+			var accountID, actorUserID int
+			called := false
+			router := gin.New()
+			router.GET("/", AuthMiddleware(cfg), func(c *gin.Context) {
+				called = true
+				accountID, actorUserID = c.GetInt("userID"), c.GetInt("actorUserID")
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("status %d, want %d: %s", w.Code, tc.wantStatus, w.Body.String())
+			}
+			if called != (tc.wantStatus == http.StatusOK) {
+				t.Fatalf("next handler called = %v", called)
+			}
+			if called && (accountID != 1 || actorUserID != 7) {
+				t.Errorf("context userID %d and actorUserID %d, want 1 and 7", accountID, actorUserID)
+			}
+		})
+	}
+}
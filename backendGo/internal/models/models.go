@@ -7,12 +7,26 @@ import (
 
 // User represents a user in the system
 type User struct {
-    ID        int       `json:"id"`
-    Username  string    `json:"username"`
-    Password  string    `json:"-"` // Password is not serialized to JSON
-    CreatedAt time.Time `json:"created_at"`
+    ID        int        `json:"id"`
+    Username  string     `json:"username"`
+    Password  string     `json:"-"` // Password is not serialized to JSON
+    CreatedAt time.Time  `json:"created_at"`
+    Email     *string    `json:"email"`
+    FullName  *string    `json:"fullName"`
+    Active    bool       `json:"active"` // Disabled users cannot sign in
+    LastLogin *time.Time `json:"lastLogin"`
+    Role      string     `json:"role"` // One of the Role constants
+    AccountID int        `json:"accountId"` // Owner of the account whose data the user works on; its own ID for owners
 }
 
+// User roles, from the most to the least privileged
+const (
+    RoleAdmin    = "admin"    // Everything, including backups and user management
+    RoleManager  = "manager"  // Products, lotes, history and stock settings
+    RoleOperator = "operator" // Lotes and history
+    RoleViewer   = "viewer"   // Read only
+)
+
 // Product matches the Product interface from the Node.js backend
 type Product struct {
     ID       string  `json:"id"`
@@ -21,6 +35,7 @@ type Product struct {
     Quantity float64 `json:"quantity"`
     UserID   int     `json:"-" db:"user_id"` // Hidden from JSON response
     Lotes    []Lote  `json:"lotes,omitempty"` // Added: Lotes associated with the product
+    DaysOfCover *float64 `json:"daysOfCover,omitempty"` // Days the quantity lasts at the recent consumption rate; set on the product list
 }
 
 // Lote represents a batch of a product
@@ -38,17 +53,140 @@ type Lote struct {
 // It corresponds to the ProductHistory interface in Node.js
 type History struct {
     ID         string          `json:"id" db:"id"`
-    Date       string          `json:"date" db:"date"` // Consider using time.Time and handling format in marshalling/unmarshalling
+    Date       time.Time       `json:"date" db:"date"` // TIMESTAMPTZ; serialized as RFC3339
     EntityType string          `json:"entityType" db:"entity_type"`
     EntityID   string          `json:"entityId" db:"entity_id"`
     UserID     int             `json:"-" db:"user_id"` // Hidden from JSON response, FK to User.ID
     Changes    json.RawMessage `json:"changes" db:"changes"` // Storing as raw JSON
     BatchID    string          `json:"batchId" db:"batch_id"` // New field for grouping history entries
+    // Hash chain fields, set when the entry is stored (see repository.HistoryEntryHash).
+    // Hidden from JSON responses; see HistoryAuditEntry
+    Seq      int64  `json:"-" db:"seq"`
+    PrevHash string `json:"-" db:"prev_hash"`
+    Hash     string `json:"-" db:"hash"`
+    // Who made the change and from where, recorded by HistoryService.RecordChange; empty on older entries
+    ActorUserID   *int   `json:"actorUserId,omitempty" db:"actor_user_id"`
+    ActorUsername string `json:"actorUsername,omitempty" db:"actor_username"`
+    ClientIP      string `json:"clientIp,omitempty" db:"client_ip"`
+    UserAgent     string `json:"userAgent,omitempty" db:"user_agent"`
+    RequestID     string `json:"requestId,omitempty" db:"request_id"`
     // New fields for context - these are populated by the service, not directly from history table
     ProductNameContext          string   `json:"productNameContext,omitempty"`
     ProductCurrentTotalQuantity *float64 `json:"productCurrentTotalQuantity,omitempty"`
 }
 
+// HistoryAuditEntry is a history entry with its hash chain links, which are kept out of the
+// history responses. It is the form of the entries in the history audit, the archive files and
+// the account exports.
+type HistoryAuditEntry struct {
+	History
+	Seq      int64  `json:"seq,omitempty"`
+	PrevHash string `json:"prevHash,omitempty"`
+	Hash     string `json:"hash,omitempty"`
+}
+
+// NewHistoryAuditEntry returns the audit form of a history entry.
+func NewHistoryAuditEntry(entry History) HistoryAuditEntry {
+	return HistoryAuditEntry{
+		History:  entry,
+		Seq:      entry.Seq,
+		PrevHash: entry.PrevHash,
+		Hash:     entry.Hash,
+	}
+}
+
+// Entry returns the history entry with its hash chain links set.
+func (a HistoryAuditEntry) Entry() History {
+	entry := a.History
+	entry.Seq, entry.PrevHash, entry.Hash = a.Seq, a.PrevHash, a.Hash
+	return entry
+}
+
+// HistoryArchive describes a compressed JSONL file holding history entries moved out of the history table.
+type HistoryArchive struct {
+	ID             int64      `json:"id"`
+	UserID         int        `json:"-"`
+	FileName       string     `json:"fileName"`
+	ArchivedBefore time.Time  `json:"archivedBefore"` // Entries dated before this instant were archived
+	FirstEntryDate time.Time  `json:"firstEntryDate"`
+	LastEntryDate  time.Time  `json:"lastEntryDate"`
+	EntryCount     int        `json:"entryCount"`
+	SizeBytes      int64      `json:"sizeBytes"`
+	SHA256         string     `json:"sha256"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	RestoredAt     *time.Time `json:"restoredAt,omitempty"`
+}
+
+// HistoryArchiveRun summarizes one archival run for a user.
+type HistoryArchiveRun struct {
+	UserID              int              `json:"userId"`
+	ArchivedBefore      time.Time        `json:"archivedBefore"`
+	Archives            []HistoryArchive `json:"archives"`
+	EntriesArchived     int              `json:"entriesArchived"`
+	SnapshotsCreated    int              `json:"snapshotsCreated"`    // Month-end snapshots reconstructed before archiving
+	SnapshotRowsRemoved int64            `json:"snapshotRowsRemoved"` // Rows of older snapshots compacted to one snapshot per month
+}
+
+// HistoryChainLink is the chain link of an archived history entry.
+type HistoryChainLink struct {
+	Seq      int64
+	PrevHash string
+	Hash     string
+}
+
+// ProductTimelineEvent is a history entry of a product, one of its lotes or a batch context,
+// with the product's running quantity after it.
+type ProductTimelineEvent struct {
+	HistoryID      string          `json:"historyId"`
+	Date           time.Time       `json:"date"`
+	BatchID        string          `json:"batchId"`
+	EntityType     string          `json:"entityType"`
+	EntityID       string          `json:"entityId"`
+	Action         string          `json:"action,omitempty"` // Empty for batch contexts
+	QuantityChange float64         `json:"quantityChange"`   // Effect of the event on the product quantity
+	QuantityAfter  float64         `json:"quantityAfter"`    // Running product quantity after the event
+	ActorUsername  string          `json:"actorUsername,omitempty"`
+	Changes        json.RawMessage `json:"changes"`
+}
+
+// ProductTimeline merges every event of a product in chronological order.
+type ProductTimeline struct {
+	ProductID       string                 `json:"productId"`
+	ProductName     string                 `json:"productName"`
+	CurrentQuantity *float64               `json:"currentQuantity,omitempty"` // Nil once the product is deleted
+	Events          []ProductTimelineEvent `json:"events"`
+}
+
+// HistoryEntryInput is a history entry submitted by a client to POST /api/history or /api/history/batch.
+// The ID, owner, batch, actor and date of the stored entry are always set by the server.
+type HistoryEntryInput struct {
+	EntityType string          `json:"entityType" binding:"required"`
+	EntityID   string          `json:"entityId" binding:"required"`
+	Changes    json.RawMessage `json:"changes" binding:"required"` // Decoded with the schema of EntityType
+	Date       *time.Time      `json:"date,omitempty"`             // Optional; rejected unless close to the server clock
+}
+
+// Actor identifies the authenticated user behind a request, as recorded on history entries.
+type Actor struct {
+	UserID      int // Owner of the account, whose stock the request reads and changes
+	ActorUserID int // The signed-in user, the owner or a member of the account
+	Username    string
+	ClientIP    string
+	UserAgent   string
+	RequestID   string
+}
+
+// HistoryFilter narrows history queries. Zero values mean "no restriction".
+type HistoryFilter struct {
+	From        *time.Time // Inclusive lower bound on the entry date
+	To          *time.Time // Inclusive upper bound on the entry date
+	EntityType  string     // "product", "lote" or "product_batch_context"
+	Action      string     // "created", "updated" or "deleted"; other values match the stored action exactly
+	ProductID   string     // Entries of the product itself, of its batch context and of its lotes
+	Search      string     // Case-insensitive match on the product name
+	ActorUserID *int       // Entries recorded for changes made by this user
+}
+
 // ProductBatchSummary holds aggregated quantity information for a product within a specific batch.
 type ProductBatchSummary struct {
 	ProductID                string  `json:"productId"`
@@ -61,7 +199,7 @@ type ProductBatchSummary struct {
 // HistoryBatchGroup represents a collection of history records for a single batch operation.
 type HistoryBatchGroup struct {
 	BatchID          string                         `json:"batchId"`
-	CreatedAt        string                         `json:"createdAt"` // Timestamp of the first entry in the batch, for ordering
+	CreatedAt        time.Time                      `json:"createdAt"` // Timestamp of the first entry in the batch, for ordering
 	Records          []History                      `json:"records"`
 	RecordCount      int                            `json:"recordCount"`
 	ProductSummaries map[string]ProductBatchSummary `json:"productSummaries,omitempty"` // Key: ProductID
@@ -101,6 +239,7 @@ type ChangedField struct {
 type ProductChange struct {
 	ProductID        string         `json:"productId,omitempty"` // ID of the product affected
 	ProductName      string         `json:"productName,omitempty"` // Name of the product for context
+	Unit             string         `json:"unit,omitempty"`        // Unit of the product; set on creation and removal
 	Action           string         `json:"action"`                // e.g., "created", "deleted", "quantity_updated", "details_updated"
 	QuantityChanged  *float64       `json:"quantityChanged,omitempty"`
 	QuantityBefore   *float64       `json:"quantityBefore,omitempty"`
@@ -122,6 +261,10 @@ type LoteChangeDetail struct {
 	DataValidade    *string   `json:"dataValidade,omitempty"`    // Current value after change
 	DataValidadeOld *string   `json:"dataValidadeOld,omitempty"` // Previous value if updated
 	DataValidadeNew *string   `json:"dataValidadeNew,omitempty"` // New value if updated
+	// Set on lotes received from an NF-e invoice
+	InvoiceKey     string  `json:"invoiceKey,omitempty"`     // 44-digit access key of the invoice
+	LotNumber      string  `json:"lotNumber,omitempty"`      // Supplier lot number (rastro/nLote)
+	DataFabricacao *string `json:"dataFabricacao,omitempty"` // Manufacturing date, YYYY-MM-DD
 }
 
 // ProductBatchContextChangeDetail stores snapshot data for a product's state
@@ -132,4 +275,742 @@ type ProductBatchContextChangeDetail struct {
 	ProductNameSnapshot string  `json:"productNameSnapshot"`
 	QuantityBeforeBatch float64 `json:"quantityBeforeBatch"`
 	QuantityAfterBatch  float64 `json:"quantityAfterBatch"`
-}
\ No newline at end of file
+}
+
+// StockSnapshot is a frozen copy of a product's (LoteID nil) or a lote's quantity
+// taken by the periodic snapshot job.
+type StockSnapshot struct {
+	ID           int64     `json:"id"`
+	UserID       int       `json:"-"`
+	TakenAt      time.Time `json:"takenAt"`
+	ProductID    string    `json:"productId"`
+	ProductName  string    `json:"productName"`
+	Unit         string    `json:"unit"`
+	LoteID       *string   `json:"loteId,omitempty"`
+	Quantity     float64   `json:"quantity"`
+	DataValidade *string   `json:"dataValidade,omitempty"` // YYYY-MM-DD, only for lote rows
+}
+
+// LoteStockPosition is the quantity of a lote at a given instant.
+type LoteStockPosition struct {
+	LoteID       string  `json:"loteId"`
+	ProductID    string  `json:"productId"`
+	Quantity     float64 `json:"quantity"`
+	DataValidade string  `json:"dataValidade,omitempty"`
+}
+
+// ProductStockPosition is the quantity of a product, and of each of its lotes, at a given instant.
+type ProductStockPosition struct {
+	ProductID   string              `json:"productId"`
+	ProductName string              `json:"productName"`
+	Unit        string              `json:"unit,omitempty"`
+	Quantity    float64             `json:"quantity"`
+	Lotes       []LoteStockPosition `json:"lotes"`
+}
+
+// StockAtReport is the reconstructed stock of a user at a given instant.
+type StockAtReport struct {
+	Date           time.Time              `json:"date"`
+	Source         string                 `json:"source"` // "snapshot" (replayed forward) or "current" (replayed backward)
+	BaseSnapshotAt *time.Time             `json:"baseSnapshotAt,omitempty"`
+	EventsReplayed int                    `json:"eventsReplayed"`
+	Products       []ProductStockPosition `json:"products"`
+}
+
+// LoteStockDiff compares the quantity of a lote between two instants.
+type LoteStockDiff struct {
+	LoteID       string  `json:"loteId"`
+	ProductID    string  `json:"productId"`
+	DataValidade string  `json:"dataValidade,omitempty"`
+	QuantityFrom float64 `json:"quantityFrom"`
+	QuantityTo   float64 `json:"quantityTo"`
+	Delta        float64 `json:"delta"`
+}
+
+// ProductStockDiff compares the quantity of a product, and of its lotes, between two instants.
+type ProductStockDiff struct {
+	ProductID    string          `json:"productId"`
+	ProductName  string          `json:"productName"`
+	Unit         string          `json:"unit,omitempty"`
+	QuantityFrom float64         `json:"quantityFrom"`
+	QuantityTo   float64         `json:"quantityTo"`
+	Delta        float64         `json:"delta"`
+	Lotes        []LoteStockDiff `json:"lotes"`
+}
+
+// StockDiffReport lists every product whose stock differs between two instants.
+type StockDiffReport struct {
+	From     time.Time          `json:"from"`
+	To       time.Time          `json:"to"`
+	Products []ProductStockDiff `json:"products"`
+}
+
+// ProductQuantityTotals holds a product's stored quantity next to the sum of its lotes.
+type ProductQuantityTotals struct {
+	ProductID      string
+	ProductName    string
+	StoredQuantity float64
+	LotesQuantity  float64
+	LoteCount      int
+}
+
+// ConsistencyDiscrepancy describes a product whose stored quantity disagrees with its lotes or its history.
+type ConsistencyDiscrepancy struct {
+	ProductID       string   `json:"productId"`
+	ProductName     string   `json:"productName"`
+	StoredQuantity  *float64 `json:"storedQuantity"`  // nil if the product no longer exists
+	LotesQuantity   float64  `json:"lotesQuantity"`
+	LoteCount       int      `json:"loteCount"`
+	HistoryQuantity *float64 `json:"historyQuantity"` // nil if replaying the history does not know the product
+	Issues          []string `json:"issues"`          // see service.Issue* constants
+}
+
+// ConsistencyReport is the result of comparing products, lotes and history for a user.
+type ConsistencyReport struct {
+	CheckedAt         time.Time                `json:"checkedAt"`
+	ProductsChecked   int                      `json:"productsChecked"`
+	Discrepancies     []ConsistencyDiscrepancy `json:"discrepancies"`
+	Fixed             bool                     `json:"fixed"`
+	AdjustmentBatchID string                   `json:"adjustmentBatchId,omitempty"`
+}
+
+// HistoryChainBreak locates the first link of a history hash chain that does not verify.
+type HistoryChainBreak struct {
+	Seq       int64  `json:"seq"`
+	HistoryID string `json:"historyId,omitempty"`
+	Reason    string `json:"reason"`
+}
+
+// HistoryChainVerification is the result of walking a user's history hash chain.
+type HistoryChainVerification struct {
+	UserID           int                `json:"userId"`
+	Valid            bool               `json:"valid"`
+	CheckedEntries   int                `json:"checkedEntries"`
+	ArchivedLinks    int                `json:"archivedLinks"`    // Links of archived entries followed
+	UnchainedEntries int                `json:"unchainedEntries"` // Entries written before the chain existed
+	HeadSeq          int64              `json:"headSeq"`
+	FirstBrokenLink  *HistoryChainBreak `json:"firstBrokenLink,omitempty"`
+	VerifiedAt       time.Time          `json:"verifiedAt"`
+}
+
+// ProductExportRow is a row of the product spreadsheet, in the layout of the frontend's
+// exportProductsToExcel: a "Produto" row followed by one "Lote" row per lote.
+type ProductExportRow struct {
+	Tipo            string   `json:"Tipo"` // "Produto" or "Lote"
+	NomeProduto     string   `json:"Nome Produto"`
+	UnidadeProduto  string   `json:"Unidade Produto,omitempty"`
+	QtdTotalProduto *float64 `json:"Qtd Total Produto,omitempty"`
+	QtdLote         *float64 `json:"Qtd Lote,omitempty"`
+	ValidadeLote    string   `json:"Validade Lote,omitempty"` // DD/MM/YYYY
+}
+
+// ImportRowError is a validation error of a spreadsheet row being imported.
+type ImportRowError struct {
+	Row     int    `json:"row"`              // Spreadsheet row number, the header being row 1
+	Column  string `json:"column,omitempty"` // Heading of the offending column
+	Message string `json:"message"`
+}
+
+// ImportResult is the outcome of a product/lote spreadsheet import. In a dry run, or when
+// any row is invalid, nothing is created and Products lists what would be.
+type ImportResult struct {
+	DryRun        bool             `json:"dryRun"`
+	Applied       bool             `json:"applied"`
+	ProductsCount int              `json:"productsCount"`
+	LotesCount    int              `json:"lotesCount"`
+	BatchID       string           `json:"batchId,omitempty"`
+	Errors        []ImportRowError `json:"errors"`
+	Products      []Product        `json:"products"`
+}
+
+// NFeLot is a lot of an NF-e item (the rastro group).
+type NFeLot struct {
+	Number         string  `json:"number"`
+	Quantity       float64 `json:"quantity"` // In the commercial unit of the item
+	DataFabricacao string  `json:"dataFabricacao,omitempty"`
+	DataValidade   string  `json:"dataValidade"`
+}
+
+// NFeItem is a product line (det) of an NF-e invoice.
+type NFeItem struct {
+	Item         int      `json:"item"`
+	SupplierCode string   `json:"supplierCode"` // cProd
+	EAN          string   `json:"ean,omitempty"`
+	Description  string   `json:"description"`
+	Unit         string   `json:"unit"` // Commercial unit (uCom)
+	Quantity     float64  `json:"quantity"`
+	TaxUnit      string   `json:"taxUnit,omitempty"` // Tributable unit (uTrib)
+	TaxQuantity  float64  `json:"taxQuantity,omitempty"`
+	Lots         []NFeLot `json:"lots"`
+}
+
+// NFeInvoice is the part of an NF-e invoice used to receive stock.
+type NFeInvoice struct {
+	AccessKey        string    `json:"accessKey"`
+	Number           string    `json:"number"`
+	Series           string    `json:"series"`
+	IssuedAt         time.Time `json:"issuedAt"`
+	SupplierDocument string    `json:"supplierDocument"` // CNPJ or CPF of the issuer
+	SupplierName     string    `json:"supplierName"`
+	Items            []NFeItem `json:"items"`
+}
+
+// SupplierProductMapping remembers which product a supplier's product code is received as.
+// A nil UnitFactor means quantities are converted by unit; otherwise one commercial unit
+// of the supplier is UnitFactor units of the product.
+type SupplierProductMapping struct {
+	SupplierDocument string    `json:"supplierDocument"`
+	SupplierCode     string    `json:"supplierCode"`
+	ProductID        string    `json:"productId"`
+	UnitFactor       *float64  `json:"unitFactor,omitempty"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+// NFeMappingInput maps a supplier code of the invoice being imported to a product.
+type NFeMappingInput struct {
+	SupplierCode string   `json:"supplierCode" binding:"required"`
+	ProductID    string   `json:"productId" binding:"required"`
+	UnitFactor   *float64 `json:"unitFactor,omitempty" binding:"omitempty,gt=0"`
+}
+
+// NFeImportItem is how an invoice item is received: the product it maps to and the lotes to create.
+type NFeImportItem struct {
+	Item          int      `json:"item"`
+	SupplierCode  string   `json:"supplierCode"`
+	Description   string   `json:"description"`
+	Unit          string   `json:"unit"`
+	Quantity      float64  `json:"quantity"`
+	ProductID     string   `json:"productId,omitempty"`
+	ProductName   string   `json:"productName,omitempty"`
+	MappingSource string   `json:"mappingSource,omitempty"` // "request", "saved" or "name"
+	UnitFactor    float64  `json:"unitFactor,omitempty"`    // Product units per commercial unit
+	Lotes         []Lote   `json:"lotes"`
+	Skipped       bool     `json:"skipped,omitempty"`
+	Errors        []string `json:"errors,omitempty"`
+	Warnings      []string `json:"warnings,omitempty"`
+}
+
+// NFeImportResult is the outcome of receiving an NF-e invoice. In a dry run, or when any item
+// is invalid, nothing is created.
+type NFeImportResult struct {
+	Invoice NFeInvoice      `json:"invoice"`
+	DryRun  bool            `json:"dryRun"`
+	Applied bool            `json:"applied"`
+	Valid   bool            `json:"valid"`
+	BatchID string          `json:"batchId,omitempty"`
+	Items   []NFeImportItem `json:"items"`
+}
+
+// NFeImport records an invoice whose stock was received.
+type NFeImport struct {
+	ID               int64     `json:"id"`
+	UserID           int       `json:"-"`
+	AccessKey        string    `json:"accessKey"`
+	Number           string    `json:"number"`
+	Series           string    `json:"series"`
+	SupplierDocument string    `json:"supplierDocument"`
+	SupplierName     string    `json:"supplierName"`
+	IssuedAt         time.Time `json:"issuedAt"`
+	BatchID          string    `json:"batchId"`
+	LoteCount        int       `json:"loteCount"`
+	ImportedAt       time.Time `json:"importedAt"`
+}
+
+// AccountDataCounts counts the records of an account export or import.
+type AccountDataCounts struct {
+	Products          int `json:"products"`
+	Lotes             int `json:"lotes"`
+	History           int `json:"history"`
+	ProductParameters int `json:"productParameters"`
+	Suppliers         int `json:"suppliers"`
+	SupplierMappings  int `json:"supplierMappings"`
+	NFeImports        int `json:"nfeImports"`
+	PurchaseOrders    int `json:"purchaseOrders"`
+}
+
+// AccountExportManifest describes an account export archive.
+type AccountExportManifest struct {
+	Format     string            `json:"format"`
+	Version    int               `json:"version"`
+	ExportedAt time.Time         `json:"exportedAt"`
+	Username   string            `json:"username"`
+	Counts     AccountDataCounts `json:"counts"`
+}
+
+// AccountSettings are the per-account records that are not stock or history.
+type AccountSettings struct {
+	ProductParameters []ProductParameters      `json:"productParameters"`
+	Suppliers         []Supplier               `json:"suppliers"`
+	SupplierMappings  []SupplierProductMapping `json:"supplierMappings"`
+	NFeImports        []NFeImport              `json:"nfeImports"`
+	PurchaseOrders    []PurchaseOrder          `json:"purchaseOrders"`
+}
+
+// AccountImportResult is the outcome of restoring an account export into an empty account.
+type AccountImportResult struct {
+	SourceUsername string            `json:"sourceUsername"`
+	ExportedAt     time.Time         `json:"exportedAt"`
+	Counts         AccountDataCounts `json:"counts"`
+}
+
+// ConsumptionWindow is a product's consumption over the last Days days.
+type ConsumptionWindow struct {
+	Days          int     `json:"days"`
+	Total         float64 `json:"total"`
+	DailyAverage  float64 `json:"dailyAverage"`
+	WeeklyAverage float64 `json:"weeklyAverage"`
+}
+
+// MonthlySeasonality is a product's consumption in one calendar month over the seasonality period.
+type MonthlySeasonality struct {
+	Month          int      `json:"month"` // 1 (January) to 12
+	Total          float64  `json:"total"`
+	MonthlyAverage float64  `json:"monthlyAverage"`  // Total divided by the occurrences of the month in the period
+	Index          *float64 `json:"index,omitempty"` // MonthlyAverage relative to the product's average month; nil without consumption
+}
+
+// ProductConsumption holds the consumption statistics and stock-out forecast of a product.
+type ProductConsumption struct {
+	ProductID       string               `json:"productId"`
+	ProductName     string               `json:"productName"`
+	Unit            string               `json:"unit"`
+	CurrentQuantity float64              `json:"currentQuantity"`
+	Windows         []ConsumptionWindow  `json:"windows"`
+	Seasonality     []MonthlySeasonality `json:"seasonality"`
+	DailyRate       float64              `json:"dailyRate"`              // Average daily consumption over the forecast window
+	DaysOfCover     *float64             `json:"daysOfCover,omitempty"`  // nil when nothing was consumed in the forecast window
+	StockOutDate    *string              `json:"stockOutDate,omitempty"` // YYYY-MM-DD
+}
+
+// ConsumptionReport is the consumption analysis of all of a user's products.
+type ConsumptionReport struct {
+	GeneratedAt        time.Time            `json:"generatedAt"`
+	ForecastWindowDays int                  `json:"forecastWindowDays"`
+	SeasonalityFrom    string               `json:"seasonalityFrom"` // YYYY-MM-DD, first day of the seasonality period
+	Products           []ProductConsumption `json:"products"`
+}
+
+// ProductParameters are the per-product settings kept apart from the stock.
+type ProductParameters struct {
+	ProductID                 string    `json:"productId"`
+	UnitCost                  *float64  `json:"unitCost"`    // Cost of one unit (L or kg); nil when unknown
+	MinQuantity               *float64  `json:"minQuantity"` // Safety stock the reorder point is built on
+	MaxQuantity               *float64  `json:"maxQuantity"` // Level a reorder fills the stock up to
+	PreferredSupplierDocument *string   `json:"preferredSupplierDocument"`
+	Location                  *string   `json:"location"` // Where the product is stored, e.g. warehouse and shelf
+	Code                      *string   `json:"code"`     // Item code of the SPED Fiscal register (COD_ITEM)
+	UpdatedAt                 time.Time `json:"updatedAt"`
+}
+
+// ProductParametersInput replaces the parameters of a product; omitted fields are cleared.
+type ProductParametersInput struct {
+	UnitCost                  *float64 `json:"unitCost" binding:"omitempty,gte=0"`
+	MinQuantity               *float64 `json:"minQuantity" binding:"omitempty,gte=0"`
+	MaxQuantity               *float64 `json:"maxQuantity" binding:"omitempty,gte=0"`
+	PreferredSupplierDocument *string  `json:"preferredSupplierDocument" binding:"omitempty,numeric,min=11,max=14"`
+	Location                  *string  `json:"location" binding:"omitempty,max=100"`
+	Code                      *string  `json:"code" binding:"omitempty,max=60,excludes=0x7C"`
+}
+
+// LoteExpiryProjection is how much of a lote is expected to be used, under FEFO, before it expires.
+type LoteExpiryProjection struct {
+	LoteID         string   `json:"loteId"`
+	DataValidade   string   `json:"dataValidade"` // YYYY-MM-DD
+	Quantity       float64  `json:"quantity"`
+	DaysToExpiry   int      `json:"daysToExpiry"` // Negative when already expired
+	Expired        bool     `json:"expired"`
+	ProjectedUse   float64  `json:"projectedUse"`
+	ProjectedWaste float64  `json:"projectedWaste"`
+	UsedUpOn       *string  `json:"usedUpOn,omitempty"`      // YYYY-MM-DD on which the lote is expected to run out, if before it expires
+	EstimatedLoss  *float64 `json:"estimatedLoss,omitempty"` // ProjectedWaste valued at the product's unit cost
+}
+
+// ProductExpiryProjection sums the expiry projections of a product's lotes.
+type ProductExpiryProjection struct {
+	ProductID      string                 `json:"productId"`
+	ProductName    string                 `json:"productName"`
+	Unit           string                 `json:"unit"`
+	Quantity       float64                `json:"quantity"`
+	DailyRate      float64                `json:"dailyRate"`
+	UnitCost       *float64               `json:"unitCost,omitempty"`
+	ProjectedWaste float64                `json:"projectedWaste"`
+	EstimatedLoss  *float64               `json:"estimatedLoss,omitempty"`
+	Lotes          []LoteExpiryProjection `json:"lotes"`
+}
+
+// ExpiryWasteReport projects the quantity of each product that will expire before it can be used.
+type ExpiryWasteReport struct {
+	GeneratedAt        time.Time                 `json:"generatedAt"`
+	ForecastWindowDays int                       `json:"forecastWindowDays"`
+	TotalEstimatedLoss float64                   `json:"totalEstimatedLoss"` // Sum over the products with a unit cost
+	UnvaluedProducts   int                       `json:"unvaluedProducts"`   // Products with projected waste but no unit cost
+	Products           []ProductExpiryProjection `json:"products"`
+}
+
+// Supplier is a supplier of the user, identified by its CNPJ or CPF.
+type Supplier struct {
+	Document     string    `json:"document"`
+	Name         string    `json:"name"`
+	LeadTimeDays int       `json:"leadTimeDays"` // Days from order to receipt
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// SupplierInput creates or replaces a supplier.
+type SupplierInput struct {
+	Name         string `json:"name" binding:"required,max=255"`
+	LeadTimeDays int    `json:"leadTimeDays" binding:"gte=0,lte=365"`
+}
+
+// ReorderSuggestion is a product that should be ordered soon, and how much of it.
+type ReorderSuggestion struct {
+	ProductID         string    `json:"productId"`
+	ProductName       string    `json:"productName"`
+	Unit              string    `json:"unit"`
+	CurrentQuantity   float64   `json:"currentQuantity"`
+	DailyRate         float64   `json:"dailyRate"`
+	MinQuantity       *float64  `json:"minQuantity,omitempty"`
+	MaxQuantity       *float64  `json:"maxQuantity,omitempty"`
+	LeadTimeDays      int       `json:"leadTimeDays"`
+	ReorderPoint      float64   `json:"reorderPoint"`      // Quantity at which to order: minimum plus lead-time consumption
+	SuggestedQuantity float64   `json:"suggestedQuantity"` // Brings the stock up to the target level on receipt
+	SuggestedOrderOn  string    `json:"suggestedOrderOn"`  // YYYY-MM-DD, when the reorder point is reached
+	ExpectedOn        string    `json:"expectedOn"`        // YYYY-MM-DD, receipt if ordered on the suggested date
+	Supplier          *Supplier `json:"supplier,omitempty"`
+	UnitCost          *float64  `json:"unitCost,omitempty"`
+	EstimatedCost     *float64  `json:"estimatedCost,omitempty"`
+}
+
+// ReorderSuggestionReport lists the products to order within the horizon.
+type ReorderSuggestionReport struct {
+	GeneratedAt        time.Time           `json:"generatedAt"`
+	ForecastWindowDays int                 `json:"forecastWindowDays"`
+	HorizonDays        int                 `json:"horizonDays"`
+	Suggestions        []ReorderSuggestion `json:"suggestions"`
+}
+
+// PurchaseOrderItem is a product line of a purchase order.
+type PurchaseOrderItem struct {
+	ID          int64    `json:"id"`
+	ProductID   *string  `json:"productId"` // nil once the product is removed
+	ProductName string   `json:"productName"`
+	Unit        string   `json:"unit"`
+	Quantity    float64  `json:"quantity"`
+	UnitCost    *float64 `json:"unitCost,omitempty"`
+}
+
+// PurchaseOrder is an order to a supplier; orders created from reorder suggestions are drafts.
+type PurchaseOrder struct {
+	ID               int64               `json:"id"`
+	UserID           int                 `json:"-"`
+	Status           string              `json:"status"`
+	SupplierDocument *string             `json:"supplierDocument,omitempty"`
+	SupplierName     *string             `json:"supplierName,omitempty"`
+	ExpectedOn       *string             `json:"expectedOn,omitempty"` // YYYY-MM-DD
+	CreatedAt        time.Time           `json:"createdAt"`
+	Items            []PurchaseOrderItem `json:"items"`
+}
+
+// DraftOrderItemInput selects a reorder suggestion, optionally ordering another quantity.
+type DraftOrderItemInput struct {
+	ProductID string   `json:"productId" binding:"required"`
+	Quantity  *float64 `json:"quantity,omitempty" binding:"omitempty,gt=0"`
+}
+
+// DraftOrdersInput selects the reorder suggestions to turn into draft purchase orders.
+type DraftOrdersInput struct {
+	Items              []DraftOrderItemInput `json:"items" binding:"required,min=1,max=500,dive"`
+	ForecastWindowDays int                   `json:"forecastWindowDays,omitempty" binding:"omitempty,gte=1,lte=3650"`
+}
+
+// BackupStatus is the outcome of the scheduled database backups.
+type BackupStatus struct {
+	Status        string     `json:"status"` // ok, failed (the last attempt failed), stale (no recent backup) or never
+	LastAttemptAt *time.Time `json:"lastAttemptAt,omitempty"`
+	LastSuccessAt *time.Time `json:"lastSuccessAt,omitempty"`
+	LastFile      string     `json:"lastFile,omitempty"`
+	SizeBytes     int64      `json:"sizeBytes,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+}
+
+// DashboardExpiryWindow counts the lotes, not yet expired, that expire within the given days.
+type DashboardExpiryWindow struct {
+	Days     int     `json:"days"`
+	Lotes    int     `json:"lotes"`
+	Quantity float64 `json:"quantity"`
+}
+
+// DashboardUnitTotals sums the stock of the products measured in one unit.
+type DashboardUnitTotals struct {
+	Unit            string                  `json:"unit"`
+	Products        int                     `json:"products"`
+	Lotes           int                     `json:"lotes"`
+	Quantity        float64                 `json:"quantity"`
+	ExpiredLotes    int                     `json:"expiredLotes"`
+	ExpiredQuantity float64                 `json:"expiredQuantity"`
+	Expiring        []DashboardExpiryWindow `json:"expiring"`
+}
+
+// DashboardLowStockProduct is a product at or below its minimum quantity (or out of stock, without one).
+type DashboardLowStockProduct struct {
+	ProductID   string   `json:"productId"`
+	ProductName string   `json:"productName"`
+	Unit        string   `json:"unit"`
+	Quantity    float64  `json:"quantity"`
+	MinQuantity *float64 `json:"minQuantity,omitempty"`
+}
+
+// DashboardBatch summarizes a recent history batch.
+type DashboardBatch struct {
+	BatchID       string    `json:"batchId"`
+	Date          time.Time `json:"date"` // Timestamp of the latest entry in the batch
+	Entries       int       `json:"entries"`
+	LoteChanges   int       `json:"loteChanges"`
+	ActorUsername string    `json:"actorUsername,omitempty"`
+	ProductNames  []string  `json:"productNames"`
+}
+
+// Dashboard summarizes the user's stock for the home screen.
+type Dashboard struct {
+	GeneratedAt   time.Time                  `json:"generatedAt"`
+	Products      int                        `json:"products"`
+	Lotes         int                        `json:"lotes"`
+	ExpiredLotes  int                        `json:"expiredLotes"`
+	ExpiringDays  []int                      `json:"expiringDays"`
+	Units         []DashboardUnitTotals      `json:"units"`
+	LowStock      []DashboardLowStockProduct `json:"lowStock"`
+	RecentBatches []DashboardBatch           `json:"recentBatches"`
+	Backup        BackupStatus               `json:"backup"`
+}
+
+// ProductABC is a product's consumption value over a period and its class in the ABC analysis.
+type ProductABC struct {
+	ProductID        string   `json:"productId"`
+	ProductName      string   `json:"productName"`
+	Unit             string   `json:"unit"`
+	Consumed         float64  `json:"consumed"`
+	UnitCost         *float64 `json:"unitCost,omitempty"`
+	ConsumptionValue *float64 `json:"consumptionValue,omitempty"` // Consumed valued at the unit cost
+	Share            float64  `json:"share"`                      // Percentage of the total consumption value
+	CumulativeShare  float64  `json:"cumulativeShare"`            // Share of this product and of every more valuable one
+	Class            string   `json:"class,omitempty"`            // A, B or C; empty for products without a unit cost
+}
+
+// ABCClassSummary sums the products of one ABC class.
+type ABCClassSummary struct {
+	Class    string  `json:"class"`
+	Products int     `json:"products"`
+	Value    float64 `json:"value"`
+	Share    float64 `json:"share"`
+}
+
+// ABCReport classifies the products by their consumption value over a period.
+type ABCReport struct {
+	GeneratedAt      time.Time         `json:"generatedAt"`
+	From             time.Time         `json:"from"`
+	To               time.Time         `json:"to"`
+	ClassALimit      float64           `json:"classALimit"` // Cumulative share, in percent, covered by class A
+	ClassBLimit      float64           `json:"classBLimit"` // Cumulative share, in percent, covered by classes A and B
+	TotalValue       float64           `json:"totalValue"`
+	UnvaluedProducts int               `json:"unvaluedProducts"` // Products consumed in the period but without a unit cost
+	Classes          []ABCClassSummary `json:"classes"`
+	Products         []ProductABC      `json:"products"`
+}
+
+// StockAgeBucket sums the lotes whose age, or days to expiry, falls within a range of days.
+type StockAgeBucket struct {
+	Label    string  `json:"label"`
+	FromDays *int    `json:"fromDays,omitempty"` // Inclusive; nil when the range is open below
+	ToDays   *int    `json:"toDays,omitempty"`   // Inclusive; nil when the range is open above
+	Lotes    int     `json:"lotes"`
+	Quantity float64 `json:"quantity"`
+}
+
+// ProductAging buckets a product's lotes by time in stock and by time to expiry.
+type ProductAging struct {
+	ProductID      string           `json:"productId"`
+	ProductName    string           `json:"productName"`
+	Unit           string           `json:"unit"`
+	Quantity       float64          `json:"quantity"`
+	AverageAgeDays float64          `json:"averageAgeDays"` // Weighted by lote quantity
+	OldestAgeDays  int              `json:"oldestAgeDays"`
+	DailyRate      float64          `json:"dailyRate"`
+	DaysOfCover    *float64         `json:"daysOfCover,omitempty"`
+	SlowMover      bool             `json:"slowMover"`
+	ByAge          []StockAgeBucket `json:"byAge"`
+	ByExpiry       []StockAgeBucket `json:"byExpiry"`
+}
+
+// UnitAging sums the aging buckets of the products measured in one unit.
+type UnitAging struct {
+	Unit     string           `json:"unit"`
+	Quantity float64          `json:"quantity"`
+	ByAge    []StockAgeBucket `json:"byAge"`
+	ByExpiry []StockAgeBucket `json:"byExpiry"`
+}
+
+// StockAgingReport buckets the lotes in stock by how long they have been in stock and by how long
+// they have until they expire.
+type StockAgingReport struct {
+	GeneratedAt        time.Time      `json:"generatedAt"`
+	ForecastWindowDays int            `json:"forecastWindowDays"`
+	SlowMovers         int            `json:"slowMovers"`
+	Units              []UnitAging    `json:"units"`
+	Products           []ProductAging `json:"products"`
+}
+
+// EmailReport is a report emailed on a schedule: daily, weekly on a weekday or monthly on a day,
+// at an hour of the server's time.
+type EmailReport struct {
+	ID         int64      `json:"id"`
+	UserID     int        `json:"-"`
+	ReportType string     `json:"reportType"` // stock_summary, expiring_lotes or low_stock
+	Recipients []string   `json:"recipients"`
+	Frequency  string     `json:"frequency"`  // daily, weekly or monthly
+	Weekday    int        `json:"weekday"`    // 0 (Sunday) to 6, for weekly reports
+	DayOfMonth int        `json:"dayOfMonth"` // 1 to 28, for monthly reports
+	Hour       int        `json:"hour"`
+	DaysAhead  int        `json:"daysAhead"` // Expiry window of the expiring lotes report
+	SkipEmpty  bool       `json:"skipEmpty"` // Send nothing when the report has no rows
+	Enabled    bool       `json:"enabled"`
+	LastRunAt  *time.Time `json:"lastRunAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
+
+// EmailReportInput creates or replaces an email report; omitted schedule fields take their defaults.
+type EmailReportInput struct {
+	ReportType string   `json:"reportType" binding:"required,oneof=stock_summary expiring_lotes low_stock"`
+	Recipients []string `json:"recipients" binding:"required,min=1,max=20,dive,email"`
+	Frequency  string   `json:"frequency" binding:"required,oneof=daily weekly monthly"`
+	Weekday    *int     `json:"weekday" binding:"omitempty,gte=0,lte=6"`
+	DayOfMonth *int     `json:"dayOfMonth" binding:"omitempty,gte=1,lte=28"`
+	Hour       *int     `json:"hour" binding:"omitempty,gte=0,lte=23"`
+	DaysAhead  *int     `json:"daysAhead" binding:"omitempty,gte=1,lte=365"`
+	SkipEmpty  bool     `json:"skipEmpty"`
+	Enabled    *bool    `json:"enabled"`
+}
+
+// EmailReportDelivery records one attempt to send an email report.
+type EmailReportDelivery struct {
+	ID          int64     `json:"id"`
+	ReportID    *int64    `json:"reportId"` // nil once the report is removed
+	ReportType  string    `json:"reportType"`
+	Recipients  []string  `json:"recipients"`
+	TriggeredBy string    `json:"triggeredBy"` // scheduled or manual
+	Status      string    `json:"status"`      // sent, failed or skipped
+	Error       string    `json:"error,omitempty"`
+	RowCount    int       `json:"rowCount"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// StockClosingLote is the frozen quantity and value of a lote in a closing.
+type StockClosingLote struct {
+	LoteID       string   `json:"loteId"`
+	DataValidade string   `json:"dataValidade,omitempty"`
+	Quantity     float64  `json:"quantity"`
+	Value        *float64 `json:"value,omitempty"`
+}
+
+// StockClosingProduct is the frozen quantity and value of a product in a closing, valued at the
+// unit cost it had when the period was closed.
+type StockClosingProduct struct {
+	ProductID   string             `json:"productId"`
+	ProductName string             `json:"productName"`
+	Unit        string             `json:"unit"`
+	Quantity    float64            `json:"quantity"`
+	UnitCost    *float64           `json:"unitCost,omitempty"`
+	Value       *float64           `json:"value,omitempty"`
+	Lotes       []StockClosingLote `json:"lotes"`
+}
+
+// StockClosing is the immutable record of a user's stock at the end of a month. While a closed
+// period lasts, products and lotes cannot change.
+type StockClosing struct {
+	ID           int64                 `json:"id"`
+	Period       string                `json:"period"`    // YYYY-MM
+	PeriodEnd    time.Time             `json:"periodEnd"` // Start of the next month
+	StockAt      time.Time             `json:"stockAt"`   // Instant of the recorded stock
+	TriggeredBy  string                `json:"triggeredBy"`
+	ClosedBy     string                `json:"closedBy,omitempty"`
+	ProductCount int                   `json:"productCount"`
+	LoteCount    int                   `json:"loteCount"`
+	TotalValue   *float64              `json:"totalValue,omitempty"` // Products with a unit cost only
+	CreatedAt    time.Time             `json:"createdAt"`
+	Products     []StockClosingProduct `json:"products,omitempty"` // Only when a single closing is retrieved
+}
+
+// StockClosingInput closes a month.
+type StockClosingInput struct {
+	Period string `json:"period" binding:"required"` // YYYY-MM
+}
+
+// StockClosingProductDiff compares a product between two closings; a product missing from one
+// of them counts as zero.
+type StockClosingProductDiff struct {
+	ProductID      string   `json:"productId"`
+	ProductName    string   `json:"productName"`
+	Unit           string   `json:"unit"`
+	QuantityFrom   float64  `json:"quantityFrom"`
+	QuantityTo     float64  `json:"quantityTo"`
+	QuantityChange float64  `json:"quantityChange"`
+	ValueFrom      *float64 `json:"valueFrom,omitempty"`
+	ValueTo        *float64 `json:"valueTo,omitempty"`
+	ValueChange    *float64 `json:"valueChange,omitempty"`
+}
+
+// StockClosingComparison compares two monthly closings, product by product.
+type StockClosingComparison struct {
+	From             StockClosing              `json:"from"`
+	To               StockClosing              `json:"to"`
+	TotalValueChange *float64                  `json:"totalValueChange,omitempty"`
+	Products         []StockClosingProductDiff `json:"products"`
+}
+
+// SpedBlocoHOptions selects the stock of a SPED Fiscal inventory register (Bloco H): a monthly
+// closing, or the stock at the end of a day.
+type SpedBlocoHOptions struct {
+	Closing string // YYYY-MM; the inventory date is the last day of the month
+	Date    string // YYYY-MM-DD, when no closing is given
+	Account string // Accounting account of the items (COD_CTA); optional
+}
+
+// SpedIssue is a mandatory field of the Bloco H missing or invalid for a product.
+type SpedIssue struct {
+	ProductID   string `json:"productId"`
+	ProductName string `json:"productName"`
+	Field       string `json:"field"` // SPED field, e.g. COD_ITEM or VL_UNIT
+	Message     string `json:"message"`
+}
+
+// SpedBlocoH is a generated Bloco H file.
+type SpedBlocoH struct {
+	InventoryDate string  // YYYY-MM-DD
+	Items         int     // H010 records
+	TotalValue    float64 // VL_INV
+	Content       []byte  // ISO-8859-1 text, CRLF line endings
+}
+
+// UserCreateInput is the payload to create a user.
+type UserCreateInput struct {
+	Username   string  `json:"username" binding:"required,min=3,max=100"`
+	Password   string  `json:"password" binding:"required,min=6,max=72"`
+	Email      *string `json:"email" binding:"omitempty,email,max=255"`
+	FullName   *string `json:"fullName" binding:"omitempty,max=200"`
+	Role       string  `json:"role" binding:"omitempty,oneof=admin manager operator viewer"` // Defaults to operator, or admin for a new account
+	NewAccount bool    `json:"newAccount"`                                                   // Owner of a new, independent account; only the ADMIN_USERNAME account may ask
+}
+
+// UserUpdateInput replaces a user's profile; omitted fields are cleared, except the password,
+// which is only reset when given, and the role, which is kept.
+type UserUpdateInput struct {
+	Email    *string `json:"email" binding:"omitempty,email,max=255"`
+	FullName *string `json:"fullName" binding:"omitempty,max=200"`
+	Password *string `json:"password" binding:"omitempty,min=6,max=72"`
+	Role     *string `json:"role" binding:"omitempty,oneof=admin manager operator viewer"` // Kept when omitted
+}
+
+// PasswordChangeInput is the payload for users changing their own password.
+type PasswordChangeInput struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+	NewPassword     string `json:"newPassword" binding:"required,min=6,max=72"`
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/lib/pq"
+)
+
+// DashboardRepository computes the aggregates shown on the dashboard
+type DashboardRepository interface {
+	GetUnitTotals(today string, expiringDays []int, userID int) ([]models.DashboardUnitTotals, error)
+	GetLowStock(userID int) ([]models.DashboardLowStockProduct, error)
+	GetRecentBatches(since time.Time, limit int, userID int) ([]models.DashboardBatch, error)
+}
+
+type dashboardRepository struct {
+	db *sql.DB
+}
+
+// NewDashboardRepository creates a new DashboardRepository
+func NewDashboardRepository(db *sql.DB) DashboardRepository {
+	return &dashboardRepository{db: db}
+}
+
+// GetUnitTotals sums products and lotes by unit in one pass over each table. A lote is expired when
+// its expiry date is before today (YYYY-MM-DD), and expires within N days when its expiry date is
+// between today and today plus N days.
+func (r *dashboardRepository) GetUnitTotals(today string, expiringDays []int, userID int) ([]models.DashboardUnitTotals, error) {
+	args := []interface{}{userID, today}
+	var windowColumns, windowSelect string
+	for i, days := range expiringDays {
+		args = append(args, days)
+		expiresWithin := fmt.Sprintf("l.data_validade BETWEEN $2::date AND $2::date + $%d::int", len(args))
+		windowColumns += fmt.Sprintf(`,
+                         COUNT(*) FILTER (WHERE %s) AS window_lotes_%d,
+                         COALESCE(SUM(l.quantity) FILTER (WHERE %s), 0) AS window_quantity_%d`, expiresWithin, i, expiresWithin, i)
+		windowSelect += fmt.Sprintf(", COALESCE(lt.window_lotes_%d, 0), COALESCE(lt.window_quantity_%d, 0)", i, i)
+	}
+
+	query := `WITH product_totals AS (
+                  SELECT unit, COUNT(*) AS products, COALESCE(SUM(quantity), 0) AS quantity
+                  FROM products WHERE user_id = $1 GROUP BY unit
+              ), lote_totals AS (
+                  SELECT p.unit, COUNT(*) AS lotes,
+                         COUNT(*) FILTER (WHERE l.data_validade < $2::date) AS expired_lotes,
+                         COALESCE(SUM(l.quantity) FILTER (WHERE l.data_validade < $2::date), 0) AS expired_quantity` + windowColumns + `
+                  FROM product_lots l JOIN products p ON p.id = l.product_id
+                  WHERE l.user_id = $1 GROUP BY p.unit
+              )
+              SELECT pt.unit, pt.products, pt.quantity, COALESCE(lt.lotes, 0), COALESCE(lt.expired_lotes, 0),
+                     COALESCE(lt.expired_quantity, 0)` + windowSelect + `
+              FROM product_totals pt LEFT JOIN lote_totals lt ON lt.unit = pt.unit
+              ORDER BY pt.unit`
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stock totals: %w", err)
+	}
+	defer rows.Close()
+
+	totals := []models.DashboardUnitTotals{}
+	for rows.Next() {
+		t := models.DashboardUnitTotals{Expiring: make([]models.DashboardExpiryWindow, len(expiringDays))}
+		dest := []interface{}{&t.Unit, &t.Products, &t.Quantity, &t.Lotes, &t.ExpiredLotes, &t.ExpiredQuantity}
+		for i, days := range expiringDays {
+			t.Expiring[i].Days = days
+			dest = append(dest, &t.Expiring[i].Lotes, &t.Expiring[i].Quantity)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan stock totals: %w", err)
+		}
+		totals = append(totals, t)
+	}
+	return totals, rows.Err()
+}
+
+// GetLowStock lists the products at or below their minimum quantity, and the products without a
+// minimum quantity that are out of stock, emptiest first.
+func (r *dashboardRepository) GetLowStock(userID int) ([]models.DashboardLowStockProduct, error) {
+	rows, err := r.db.Query(`SELECT p.id, p.name, p.unit, p.quantity, pp.min_quantity
+                             FROM products p LEFT JOIN product_parameters pp ON pp.product_id = p.id
+                             WHERE p.user_id = $1 AND p.quantity <= COALESCE(pp.min_quantity, 0)
+                             ORDER BY p.quantity - COALESCE(pp.min_quantity, 0), p.name`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query low stock products: %w", err)
+	}
+	defer rows.Close()
+
+	products := []models.DashboardLowStockProduct{}
+	for rows.Next() {
+		var p models.DashboardLowStockProduct
+		var minQuantity sql.NullFloat64
+		if err := rows.Scan(&p.ProductID, &p.ProductName, &p.Unit, &p.Quantity, &minQuantity); err != nil {
+			return nil, fmt.Errorf("failed to scan low stock product: %w", err)
+		}
+		p.MinQuantity = nullFloat(minQuantity)
+		products = append(products, p)
+	}
+	return products, rows.Err()
+}
+
+// GetRecentBatches summarizes the latest history batches with entries since the given instant,
+// newest first.
+func (r *dashboardRepository) GetRecentBatches(since time.Time, limit int, userID int) ([]models.DashboardBatch, error) {
+	rows, err := r.db.Query(`SELECT batch_id, MAX(date), COUNT(*),
+                                    COUNT(*) FILTER (WHERE entity_type = 'lote'),
+                                    MAX(actor_username),
+                                    COALESCE(ARRAY_AGG(DISTINCT changes->>'productName') FILTER (WHERE changes->>'productName' <> ''), '{}')
+                             FROM history
+                             WHERE user_id = $1 AND date >= $2 AND batch_id IS NOT NULL AND batch_id <> ''
+                             GROUP BY batch_id
+                             ORDER BY MAX(date) DESC
+                             LIMIT $3`, userID, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recent history batches: %w", err)
+	}
+	defer rows.Close()
+
+	batches := []models.DashboardBatch{}
+	for rows.Next() {
+		var b models.DashboardBatch
+		var productNames pq.StringArray
+		if err := rows.Scan(&b.BatchID, &b.Date, &b.Entries, &b.LoteChanges, &b.ActorUsername, &productNames); err != nil {
+			return nil, fmt.Errorf("failed to scan recent history batch: %w", err)
+		}
+		b.ProductNames = []string(productNames)
+		batches = append(batches, b)
+	}
+	return batches, rows.Err()
+}
@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/lib/pq"
+)
+
+// EmailReportRepository defines the interface for scheduled email report data operations
+type EmailReportRepository interface {
+	List(userID int) ([]models.EmailReport, error)
+	ListEnabled() ([]models.EmailReport, error)
+	GetByID(id int64, userID int) (*models.EmailReport, error)
+	Create(report *models.EmailReport) error
+	Update(report *models.EmailReport) (bool, error)
+	Delete(id int64, userID int) (bool, error)
+	MarkRun(id int64, at time.Time) error
+	CreateDelivery(delivery *models.EmailReportDelivery, userID int) error
+	ListDeliveries(userID int, limit int) ([]models.EmailReportDelivery, error)
+}
+
+type emailReportRepository struct {
+	db *sql.DB
+}
+
+// NewEmailReportRepository creates a new EmailReportRepository
+func NewEmailReportRepository(db *sql.DB) EmailReportRepository {
+	return &emailReportRepository{db: db}
+}
+
+const emailReportColumns = `id, user_id, report_type, recipients, frequency, weekday, day_of_month, hour, days_ahead,
+                            skip_empty, enabled, last_run_at, created_at, updated_at`
+
+func scanEmailReport(row interface{ Scan(...interface{}) error }, r *models.EmailReport) error {
+	var recipients pq.StringArray
+	var lastRunAt sql.NullTime
+	if err := row.Scan(&r.ID, &r.UserID, &r.ReportType, &recipients, &r.Frequency, &r.Weekday, &r.DayOfMonth, &r.Hour,
+		&r.DaysAhead, &r.SkipEmpty, &r.Enabled, &lastRunAt, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		return err
+	}
+	r.Recipients = []string(recipients)
+	if lastRunAt.Valid {
+		r.LastRunAt = &lastRunAt.Time
+	}
+	return nil
+}
+
+func (r *emailReportRepository) queryReports(query string, args ...interface{}) ([]models.EmailReport, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query email reports: %w", err)
+	}
+	defer rows.Close()
+
+	reports := []models.EmailReport{}
+	for rows.Next() {
+		var report models.EmailReport
+		if err := scanEmailReport(rows, &report); err != nil {
+			return nil, fmt.Errorf("failed to scan email report: %w", err)
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
+
+// List retrieves the user's email reports, oldest first.
+func (r *emailReportRepository) List(userID int) ([]models.EmailReport, error) {
+	return r.queryReports(`SELECT `+emailReportColumns+` FROM email_reports WHERE user_id = $1 ORDER BY id`, userID)
+}
+
+// ListEnabled retrieves the enabled email reports of all users, for the scheduler.
+func (r *emailReportRepository) ListEnabled() ([]models.EmailReport, error) {
+	return r.queryReports(`SELECT ` + emailReportColumns + ` FROM email_reports WHERE enabled ORDER BY user_id, id`)
+}
+
+// GetByID retrieves an email report, or nil if the user has no such report.
+func (r *emailReportRepository) GetByID(id int64, userID int) (*models.EmailReport, error) {
+	var report models.EmailReport
+	err := scanEmailReport(r.db.QueryRow(`SELECT `+emailReportColumns+` FROM email_reports WHERE id = $1 AND user_id = $2`, id, userID), &report)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get email report: %w", err)
+	}
+	return &report, nil
+}
+
+// Create inserts an email report and sets its ID and timestamps.
+func (r *emailReportRepository) Create(report *models.EmailReport) error {
+	err := r.db.QueryRow(`INSERT INTO email_reports (user_id, report_type, recipients, frequency, weekday, day_of_month, hour,
+                                                     days_ahead, skip_empty, enabled)
+                          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+                          RETURNING id, created_at, updated_at`,
+		report.UserID, report.ReportType, pq.Array(report.Recipients), report.Frequency, report.Weekday, report.DayOfMonth,
+		report.Hour, report.DaysAhead, report.SkipEmpty, report.Enabled).Scan(&report.ID, &report.CreatedAt, &report.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create email report: %w", err)
+	}
+	return nil
+}
+
+// Update replaces an email report's settings and reports whether the user has such a report.
+func (r *emailReportRepository) Update(report *models.EmailReport) (bool, error) {
+	err := r.db.QueryRow(`UPDATE email_reports
+                          SET report_type = $3, recipients = $4, frequency = $5, weekday = $6, day_of_month = $7, hour = $8,
+                              days_ahead = $9, skip_empty = $10, enabled = $11, updated_at = CURRENT_TIMESTAMP
+                          WHERE id = $1 AND user_id = $2
+                          RETURNING created_at, updated_at, last_run_at`,
+		report.ID, report.UserID, report.ReportType, pq.Array(report.Recipients), report.Frequency, report.Weekday,
+		report.DayOfMonth, report.Hour, report.DaysAhead, report.SkipEmpty, report.Enabled).Scan(&report.CreatedAt, &report.UpdatedAt, &report.LastRunAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to update email report: %w", err)
+	}
+	return true, nil
+}
+
+// Delete removes an email report and reports whether the user had such a report. Its deliveries are kept.
+func (r *emailReportRepository) Delete(id int64, userID int) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM email_reports WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete email report: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete email report: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// MarkRun records when the scheduler last ran a report.
+func (r *emailReportRepository) MarkRun(id int64, at time.Time) error {
+	if _, err := r.db.Exec(`UPDATE email_reports SET last_run_at = $2 WHERE id = $1`, id, at); err != nil {
+		return fmt.Errorf("failed to mark email report %d as run: %w", id, err)
+	}
+	return nil
+}
+
+// CreateDelivery logs an attempt to send a report and sets its ID and CreatedAt.
+func (r *emailReportRepository) CreateDelivery(delivery *models.EmailReportDelivery, userID int) error {
+	err := r.db.QueryRow(`INSERT INTO email_report_deliveries (user_id, report_id, report_type, recipients, triggered_by, status, error, row_count)
+                          VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+                          RETURNING id, created_at`,
+		userID, delivery.ReportID, delivery.ReportType, pq.Array(delivery.Recipients), delivery.TriggeredBy, delivery.Status,
+		delivery.Error, delivery.RowCount).Scan(&delivery.ID, &delivery.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to log email report delivery: %w", err)
+	}
+	return nil
+}
+
+// ListDeliveries retrieves the user's latest report deliveries, newest first.
+func (r *emailReportRepository) ListDeliveries(userID int, limit int) ([]models.EmailReportDelivery, error) {
+	rows, err := r.db.Query(`SELECT id, report_id, report_type, recipients, triggered_by, status, error, row_count, created_at
+                             FROM email_report_deliveries
+                             WHERE user_id = $1
+                             ORDER BY created_at DESC, id DESC
+                             LIMIT $2`, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query email report deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.EmailReportDelivery{}
+	for rows.Next() {
+		var d models.EmailReportDelivery
+		var reportID sql.NullInt64
+		var recipients pq.StringArray
+		if err := rows.Scan(&d.ID, &reportID, &d.ReportType, &recipients, &d.TriggeredBy, &d.Status, &d.Error, &d.RowCount, &d.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan email report delivery: %w", err)
+		}
+		if reportID.Valid {
+			d.ReportID = &reportID.Int64
+		}
+		d.Recipients = []string(recipients)
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/lib/pq"
+)
+
+// HistoryArchiveRepository defines the interface for history archive data operations
+type HistoryArchiveRepository interface {
+	GetEarliestEntryDate(userID int) (time.Time, error)
+	GetEntriesToArchive(archivedBefore time.Time, userID int) ([]models.History, error)
+	CreateTx(tx *sql.Tx, archive *models.HistoryArchive, entries []models.History) error
+	RestoreTx(tx *sql.Tx, archive *models.HistoryArchive, entries []models.History) (int, error)
+	List(userID int) ([]models.HistoryArchive, error)
+	GetByID(id int64, userID int) (*models.HistoryArchive, error)
+	GetCutoff(userID int) (time.Time, error)
+	GetArchivedLinks(userID int) ([]models.HistoryChainLink, error)
+}
+
+type historyArchiveRepository struct {
+	db *sql.DB
+}
+
+// NewHistoryArchiveRepository creates a new HistoryArchiveRepository
+func NewHistoryArchiveRepository(db *sql.DB) HistoryArchiveRepository {
+	return &historyArchiveRepository{db: db}
+}
+
+const historyArchiveColumns = `id, user_id, file_name, archived_before, first_entry_date, last_entry_date,
+                               entry_count, size_bytes, sha256, created_at, restored_at`
+
+func scanHistoryArchive(row interface{ Scan(...interface{}) error }, a *models.HistoryArchive) error {
+	var restoredAt sql.NullTime
+	if err := row.Scan(&a.ID, &a.UserID, &a.FileName, &a.ArchivedBefore, &a.FirstEntryDate, &a.LastEntryDate,
+		&a.EntryCount, &a.SizeBytes, &a.SHA256, &a.CreatedAt, &restoredAt); err != nil {
+		return err
+	}
+	if restoredAt.Valid {
+		a.RestoredAt = &restoredAt.Time
+	}
+	return nil
+}
+
+// GetEarliestEntryDate returns the date of the user's oldest history entry, or a zero time if there is none.
+func (r *historyArchiveRepository) GetEarliestEntryDate(userID int) (time.Time, error) {
+	var earliest sql.NullTime
+	if err := r.db.QueryRow(`SELECT MIN(date) FROM history WHERE user_id = $1`, userID).Scan(&earliest); err != nil {
+		return time.Time{}, fmt.Errorf("failed to get earliest history date: %w", err)
+	}
+	return earliest.Time, nil
+}
+
+// GetEntriesToArchive retrieves the entries dated before archivedBefore, in the order they were recorded.
+// Chained entries are only taken below the first retained sequence number, so the archived part of
+// the chain is always a contiguous prefix and the retained part still links to it.
+func (r *historyArchiveRepository) GetEntriesToArchive(archivedBefore time.Time, userID int) ([]models.History, error) {
+	query := `SELECT ` + historyColumns + `, seq, prev_hash, hash
+              FROM history
+              WHERE user_id = $1 AND date < $2
+                AND (seq IS NULL OR seq < (
+                    SELECT COALESCE(MIN(seq), 9223372036854775807) FROM history
+                    WHERE user_id = $1 AND seq IS NOT NULL AND date >= $2
+                ))
+              ORDER BY date ASC, seq ASC NULLS FIRST, id ASC`
+	rows, err := r.db.Query(query, userID, archivedBefore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history entries to archive: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []models.History
+	for rows.Next() {
+		var entry models.History
+		var seq sql.NullInt64
+		var prevHash, hash sql.NullString
+		if err := scanHistory(rows, &entry, &seq, &prevHash, &hash); err != nil {
+			return nil, fmt.Errorf("failed to scan history entry to archive: %w", err)
+		}
+		entry.UserID = userID
+		entry.Seq, entry.PrevHash, entry.Hash = seq.Int64, prevHash.String, hash.String
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration for GetEntriesToArchive: %w", err)
+	}
+	return entries, nil
+}
+
+// CreateTx records an archive, keeps the chain links of its chained entries and removes the entries from history.
+func (r *historyArchiveRepository) CreateTx(tx *sql.Tx, archive *models.HistoryArchive, entries []models.History) error {
+	err := tx.QueryRow(`INSERT INTO history_archives (user_id, file_name, archived_before, first_entry_date, last_entry_date,
+                                                      entry_count, size_bytes, sha256)
+                        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+                        RETURNING id, created_at`,
+		archive.UserID, archive.FileName, archive.ArchivedBefore, archive.FirstEntryDate, archive.LastEntryDate,
+		archive.EntryCount, archive.SizeBytes, archive.SHA256).Scan(&archive.ID, &archive.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record history archive: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO history_archived_links (user_id, seq, prev_hash, hash, archive_id) VALUES ($1, $2, $3, $4, $5)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement for archived links: %w", err)
+	}
+	defer stmt.Close()
+
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+		if entry.Seq == 0 {
+			continue
+		}
+		if _, err := stmt.Exec(archive.UserID, entry.Seq, entry.PrevHash, entry.Hash, archive.ID); err != nil {
+			return fmt.Errorf("failed to keep chain link of archived entry %s: %w", entry.ID, err)
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM history WHERE user_id = $1 AND id = ANY($2)`, archive.UserID, pq.Array(ids)); err != nil {
+		return fmt.Errorf("failed to remove archived history entries: %w", err)
+	}
+	return nil
+}
+
+// RestoreTx inserts the entries of an archive back into history, with their original chain links,
+// and marks the archive as restored. Entries that are already present are skipped.
+// It returns the number of entries inserted.
+func (r *historyArchiveRepository) RestoreTx(tx *sql.Tx, archive *models.HistoryArchive, entries []models.History) (int, error) {
+	stmt, err := tx.Prepare(`INSERT INTO history (id, date, entity_type, entity_id, user_id, changes, batch_id, seq, prev_hash, hash,
+                                                  actor_user_id, actor_username, client_ip, user_agent, request_id)
+                             VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+                             ON CONFLICT (id) DO NOTHING`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare statement for history restore: %w", err)
+	}
+	defer stmt.Close()
+
+	// The restored entries carry their own links again.
+	if _, err := tx.Exec(`DELETE FROM history_archived_links WHERE archive_id = $1`, archive.ID); err != nil {
+		return 0, fmt.Errorf("failed to remove archived links: %w", err)
+	}
+
+	restored := 0
+	for _, entry := range entries {
+		var seq, prevHash, hash interface{}
+		if entry.Seq != 0 {
+			seq, prevHash, hash = entry.Seq, entry.PrevHash, entry.Hash
+		}
+		res, err := stmt.Exec(entry.ID, entry.Date, entry.EntityType, entry.EntityID, archive.UserID, []byte(entry.Changes), entry.BatchID,
+			seq, prevHash, hash, entry.ActorUserID, entry.ActorUsername, entry.ClientIP, entry.UserAgent, entry.RequestID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to restore history entry %s: %w", entry.ID, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			restored++
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE history_archives SET restored_at = CURRENT_TIMESTAMP WHERE id = $1`, archive.ID); err != nil {
+		return 0, fmt.Errorf("failed to mark archive as restored: %w", err)
+	}
+	return restored, nil
+}
+
+// List retrieves every archive of the user, newest first.
+func (r *historyArchiveRepository) List(userID int) ([]models.HistoryArchive, error) {
+	rows, err := r.db.Query(`SELECT `+historyArchiveColumns+` FROM history_archives WHERE user_id = $1 ORDER BY archived_before DESC, id DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history archives: %w", err)
+	}
+	defer rows.Close()
+
+	archives := []models.HistoryArchive{}
+	for rows.Next() {
+		var a models.HistoryArchive
+		if err := scanHistoryArchive(rows, &a); err != nil {
+			return nil, fmt.Errorf("failed to scan history archive: %w", err)
+		}
+		archives = append(archives, a)
+	}
+	return archives, rows.Err()
+}
+
+// GetByID retrieves an archive of the user, or nil if it does not exist.
+func (r *historyArchiveRepository) GetByID(id int64, userID int) (*models.HistoryArchive, error) {
+	var a models.HistoryArchive
+	err := scanHistoryArchive(r.db.QueryRow(`SELECT `+historyArchiveColumns+` FROM history_archives WHERE id = $1 AND user_id = $2`, id, userID), &a)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get history archive: %w", err)
+	}
+	return &a, nil
+}
+
+// GetCutoff returns the latest archivedBefore of the user's archives that were not restored,
+// i.e. the instant the retained history starts from, or a zero time if nothing is archived.
+func (r *historyArchiveRepository) GetCutoff(userID int) (time.Time, error) {
+	var cutoff sql.NullTime
+	err := r.db.QueryRow(`SELECT MAX(archived_before) FROM history_archives WHERE user_id = $1 AND restored_at IS NULL`, userID).Scan(&cutoff)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get history archive cutoff: %w", err)
+	}
+	return cutoff.Time, nil
+}
+
+// GetArchivedLinks retrieves the chain links of the user's archived entries in sequence order.
+func (r *historyArchiveRepository) GetArchivedLinks(userID int) ([]models.HistoryChainLink, error) {
+	rows, err := r.db.Query(`SELECT seq, prev_hash, hash FROM history_archived_links WHERE user_id = $1 ORDER BY seq`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query archived chain links: %w", err)
+	}
+	defer rows.Close()
+
+	var links []models.HistoryChainLink
+	for rows.Next() {
+		var link models.HistoryChainLink
+		if err := rows.Scan(&link.Seq, &link.PrevHash, &link.Hash); err != nil {
+			return nil, fmt.Errorf("failed to scan archived chain link: %w", err)
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
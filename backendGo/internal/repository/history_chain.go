@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+)
+
+// The history of every user forms a hash chain: each entry stores the hash of the
+// previous entry (prev_hash) and its own hash, computed over prev_hash and a
+// canonical JSON form of the entry. Editing, deleting or reordering any chained
+// row breaks every link after it. history_chain_heads holds the last link of each
+// chain, so truncating the tail is detected too, and its row lock serializes appends.
+
+// canonicalHistoryEntry fixes the field order and formats hashed for a history entry.
+type canonicalHistoryEntry struct {
+	ID         string      `json:"id"`
+	UserID     int         `json:"userId"`
+	Seq        int64       `json:"seq"`
+	Date       string      `json:"date"`
+	EntityType string      `json:"entityType"`
+	EntityID   string      `json:"entityId"`
+	BatchID    string      `json:"batchId"`
+	Changes    interface{} `json:"changes"`
+	// Actor fields are omitted when empty so entries written before they existed keep their hash.
+	ActorUserID   *int   `json:"actorUserId,omitempty"`
+	ActorUsername string `json:"actorUsername,omitempty"`
+	ClientIP      string `json:"clientIp,omitempty"`
+	UserAgent     string `json:"userAgent,omitempty"`
+	RequestID     string `json:"requestId,omitempty"`
+}
+
+// HistoryEntryHash computes the chain hash of an entry: SHA-256 of the previous hash
+// followed by the canonical JSON of the entry, hex encoded.
+// Changes are decoded and re-encoded so the key order and spacing of JSONB do not matter.
+func HistoryEntryHash(prevHash string, entry models.History) (string, error) {
+	var changes interface{}
+	if err := json.Unmarshal(entry.Changes, &changes); err != nil {
+		return "", fmt.Errorf("failed to decode changes of history entry %s: %w", entry.ID, err)
+	}
+
+	canonical, err := json.Marshal(canonicalHistoryEntry{
+		ID:            entry.ID,
+		UserID:        entry.UserID,
+		Seq:           entry.Seq,
+		Date:          entry.Date.UTC().Format(time.RFC3339Nano),
+		EntityType:    entry.EntityType,
+		EntityID:      entry.EntityID,
+		BatchID:       entry.BatchID,
+		Changes:       changes,
+		ActorUserID:   entry.ActorUserID,
+		ActorUsername: entry.ActorUsername,
+		ClientIP:      entry.ClientIP,
+		UserAgent:     entry.UserAgent,
+		RequestID:     entry.RequestID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode history entry %s: %w", entry.ID, err)
+	}
+
+	sum := sha256.Sum256(append([]byte(prevHash), canonical...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// historyChainHead is the last link of a user's chain.
+type historyChainHead struct {
+	seq  int64
+	hash string
+}
+
+// lockHistoryChainHeads locks the chain heads of every user present in entries, in user ID
+// order to avoid deadlocks between concurrent batches, creating missing heads on the way.
+func lockHistoryChainHeads(tx *sql.Tx, entries []models.History) (map[int]*historyChainHead, error) {
+	heads := make(map[int]*historyChainHead)
+	var userIDs []int
+	for _, entry := range entries {
+		if _, ok := heads[entry.UserID]; !ok {
+			heads[entry.UserID] = nil
+			userIDs = append(userIDs, entry.UserID)
+		}
+	}
+	sort.Ints(userIDs)
+
+	for _, userID := range userIDs {
+		if _, err := tx.Exec(`INSERT INTO history_chain_heads (user_id, last_seq, last_hash) VALUES ($1, 0, '')
+                              ON CONFLICT (user_id) DO NOTHING`, userID); err != nil {
+			return nil, fmt.Errorf("failed to initialize history chain of user %d: %w", userID, err)
+		}
+		head := &historyChainHead{}
+		if err := tx.QueryRow(`SELECT last_seq, last_hash FROM history_chain_heads WHERE user_id = $1 FOR UPDATE`, userID).
+			Scan(&head.seq, &head.hash); err != nil {
+			return nil, fmt.Errorf("failed to lock history chain of user %d: %w", userID, err)
+		}
+		heads[userID] = head
+	}
+	return heads, nil
+}
+
+// saveHistoryChainHeads stores the new last links after an append.
+func saveHistoryChainHeads(tx *sql.Tx, heads map[int]*historyChainHead) error {
+	for userID, head := range heads {
+		if _, err := tx.Exec(`UPDATE history_chain_heads SET last_seq = $1, last_hash = $2, updated_at = CURRENT_TIMESTAMP
+                              WHERE user_id = $3`, head.seq, head.hash, userID); err != nil {
+			return fmt.Errorf("failed to update history chain of user %d: %w", userID, err)
+		}
+	}
+	return nil
+}
+
+// GetChainHead returns the last sequence number and hash recorded for the user's chain.
+// Both are zero values if the user never had a chained entry.
+func (r *historyRepository) GetChainHead(userID int) (int64, string, error) {
+	var seq int64
+	var hash string
+	err := r.db.QueryRow(`SELECT last_seq, last_hash FROM history_chain_heads WHERE user_id = $1`, userID).Scan(&seq, &hash)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, "", fmt.Errorf("failed to get history chain head: %w", err)
+	}
+	return seq, hash, nil
+}
+
+// CountUnchained returns how many of the user's entries predate the hash chain.
+func (r *historyRepository) CountUnchained(userID int) (int, error) {
+	var count int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM history WHERE user_id = $1 AND seq IS NULL`, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count unchained history entries: %w", err)
+	}
+	return count, nil
+}
+
+// WalkChain calls fn for every chained entry of the user in sequence order, without
+// loading the whole chain in memory. Walking stops at the first error returned by fn.
+func (r *historyRepository) WalkChain(userID int, fn func(entry models.History) error) error {
+	rows, err := r.db.Query(`SELECT `+historyColumns+`, user_id, seq, prev_hash, hash
+                             FROM history
+                             WHERE user_id = $1 AND seq IS NOT NULL
+                             ORDER BY seq ASC`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to query history chain: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry models.History
+		if err := scanHistory(rows, &entry, &entry.UserID, &entry.Seq, &entry.PrevHash, &entry.Hash); err != nil {
+			return fmt.Errorf("failed to scan history chain entry: %w", err)
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetChainOwnerIDs returns the IDs of every user that has history entries.
+func (r *historyRepository) GetChainOwnerIDs() ([]int, error) {
+	rows, err := r.db.Query(`SELECT DISTINCT user_id FROM history ORDER BY user_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history owners: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan history owner: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
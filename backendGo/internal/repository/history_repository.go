@@ -6,20 +6,31 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"strings"
 	"time"
 
 	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 // HistoryRepository defines the interface for history data operations
 type HistoryRepository interface {
 	Create(history *models.History) error
 	CreateBatch(entries []models.History) error
+	CreateBatchTx(tx *sql.Tx, entries []models.History) error
+	AllowBackdatedTx(tx *sql.Tx) error
 	GetByBatchID(batchID string, userID int) ([]models.History, error)
-	GetHistory(limit, offset int, userID int) ([]models.History, error)
+	GetHistory(filter models.HistoryFilter, limit, offset int, userID int) ([]models.History, error)
 	GetHistoryByEntity(entityType, entityID string, userID int) ([]models.History, error)
-	GetGroupedHistoryBatches(page, pageSize int, userID int) (*models.PaginatedHistoryBatchGroups, error)
+	GetGroupedHistoryBatches(filter models.HistoryFilter, page, pageSize int, userID int) (*models.PaginatedHistoryBatchGroups, error)
+	GetHistoryInRange(after, until time.Time, userID int) ([]models.History, error)
+	GetProductHistory(productID string, userID int) ([]models.History, error)
+	WalkHistory(filter models.HistoryFilter, userID int, fn func(entry models.History) error) error
+	GetChainHead(userID int) (int64, string, error)
+	CountUnchained(userID int) (int, error)
+	WalkChain(userID int, fn func(entry models.History) error) error
+	GetChainOwnerIDs() ([]int, error)
 }
 
 type historyRepository struct {
@@ -31,41 +42,38 @@ func NewHistoryRepository(db *sql.DB) HistoryRepository {
 	return &historyRepository{db: db}
 }
 
-// Create adds a new history entry to the database
+// Create adds a new history entry to the database, appending it to the user's hash chain
 func (r *historyRepository) Create(history *models.History) error {
 	if history.ID == "" {
 		history.ID = uuid.NewString()
 	}
-	if history.Date == "" {
-		history.Date = time.Now().Format(time.RFC3339)
+	if history.Date.IsZero() {
+		history.Date = time.Now()
 	}
 	// If BatchID is not set, default it to the entry's own ID
 	if history.BatchID == "" {
 		history.BatchID = history.ID
 	}
 
-	// Ensure changes is valid JSON
-	var js json.RawMessage = history.Changes
-	if !json.Valid(js) {
-		// Attempt to marshal if it's not already raw JSON string
-		b, err := json.Marshal(history.Changes)
-		if err != nil {
-			return fmt.Errorf("history changes is not valid JSON and failed to marshal: %w", err)
-		}
-		js = json.RawMessage(b)
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback() // Rollback if not committed
 
-	query := `INSERT INTO history (id, date, entity_type, entity_id, user_id, changes, batch_id) 
-              VALUES ($1, $2, $3, $4, $5, $6, $7)`
-	_, err := r.db.Exec(query, history.ID, history.Date, history.EntityType, history.EntityID, history.UserID, js, history.BatchID)
-	if err != nil {
+	entries := []models.History{*history}
+	if err := r.CreateBatchTx(tx, entries); err != nil {
 		return fmt.Errorf("failed to create history entry: %w", err)
 	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit history entry: %w", err)
+	}
+	*history = entries[0]
 	return nil
 }
 
 // CreateBatch inserts multiple history entries into the database, typically within a transaction.
-func (r *historyRepository) CreateBatch(entries []models.History) error {
+func (r *historyRepository) CreateBatch(entries []models.History) (err error) {
 	if len(entries) == 0 {
 		return nil // No entries to insert
 	}
@@ -85,20 +93,37 @@ func (r *historyRepository) CreateBatch(entries []models.History) error {
 		}
 	}()
 
-	stmt, err := tx.Prepare(`INSERT INTO history (id, date, entity_type, entity_id, user_id, changes, batch_id)
-                             VALUES ($1, $2, $3, $4, $5, $6, $7)`)
-	if err != nil {
-		return fmt.Errorf("failed to prepare statement for batch insert: %w", err)
+	err = r.CreateBatchTx(tx, entries)
+	return err
+}
+
+// AllowBackdatedTx lets the caller's transaction write history dated inside closed stock periods.
+// Only restored archives and account imports use it; the setting ends with the transaction.
+func (r *historyRepository) AllowBackdatedTx(tx *sql.Tx) error {
+	if _, err := tx.Exec(`SELECT set_config('stock.trusted_history', 'on', true)`); err != nil {
+		return fmt.Errorf("failed to allow backdated history: %w", err)
+	}
+	return nil
+}
+
+// CreateBatchTx inserts multiple history entries using the caller's transaction,
+// so the history is committed or rolled back together with the change it describes.
+// Each entry is appended to its user's hash chain; Seq, PrevHash and Hash are set on the entries.
+func (r *historyRepository) CreateBatchTx(tx *sql.Tx, entries []models.History) error {
+	if len(entries) == 0 {
+		return nil
 	}
-	defer stmt.Close()
 
-	for _, entry := range entries {
+	for i := range entries {
+		entry := &entries[i]
 		if entry.ID == "" {
 			entry.ID = uuid.NewString()
 		}
-		if entry.Date == "" {
-			entry.Date = time.Now().Format(time.RFC3339)
+		if entry.Date.IsZero() {
+			entry.Date = time.Now()
 		}
+		// TIMESTAMPTZ keeps microseconds; truncate so the hashed date is the stored one.
+		entry.Date = entry.Date.Truncate(time.Microsecond)
 		// BatchID should be pre-set by the service for all entries in a batch.
 		// If not, this indicates a logic error upstream or a different use case.
 		if entry.BatchID == "" {
@@ -110,27 +135,70 @@ func (r *historyRepository) CreateBatch(entries []models.History) error {
 		}
 
 		// Ensure changes is valid JSON
-		var js json.RawMessage = entry.Changes
-		if !json.Valid(js) {
+		if !json.Valid(entry.Changes) {
 			b, err := json.Marshal(entry.Changes)
 			if err != nil {
 				return fmt.Errorf("history changes is not valid JSON and failed to marshal: %w", err)
 			}
-			js = json.RawMessage(b)
+			entry.Changes = json.RawMessage(b)
 		}
+	}
+
+	heads, err := lockHistoryChainHeads(tx, entries)
+	if err != nil {
+		return err
+	}
 
-		if _, err = stmt.Exec(entry.ID, entry.Date, entry.EntityType, entry.EntityID, entry.UserID, js, entry.BatchID); err != nil {
+	stmt, err := tx.Prepare(`INSERT INTO history (id, date, entity_type, entity_id, user_id, changes, batch_id, seq, prev_hash, hash,
+                                                  actor_user_id, actor_username, client_ip, user_agent, request_id)
+                             VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement for batch insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for i := range entries {
+		entry := &entries[i]
+		head := heads[entry.UserID]
+		entry.Seq = head.seq + 1
+		entry.PrevHash = head.hash
+		if entry.Hash, err = HistoryEntryHash(entry.PrevHash, *entry); err != nil {
+			return err
+		}
+
+		if _, err = stmt.Exec(entry.ID, entry.Date, entry.EntityType, entry.EntityID, entry.UserID, []byte(entry.Changes), entry.BatchID, entry.Seq, entry.PrevHash, entry.Hash,
+			entry.ActorUserID, entry.ActorUsername, entry.ClientIP, entry.UserAgent, entry.RequestID); err != nil {
 			return fmt.Errorf("failed to execute statement for entry %s in batch insert: %w", entry.ID, err)
 		}
+		head.seq, head.hash = entry.Seq, entry.Hash
 	}
 
+	return saveHistoryChainHeads(tx, heads)
+}
+
+// historyColumns lists the columns read into models.History by scanHistory, in scan order.
+const historyColumns = `id, date, entity_type, entity_id, changes, batch_id,
+                     actor_user_id, actor_username, client_ip, user_agent, request_id`
+
+// scanHistory scans a row selected with historyColumns, followed by any extra columns into extra.
+func scanHistory(rows *sql.Rows, entry *models.History, extra ...interface{}) error {
+	var actorUserID sql.NullInt64
+	dest := append([]interface{}{&entry.ID, &entry.Date, &entry.EntityType, &entry.EntityID, &entry.Changes, &entry.BatchID,
+		&actorUserID, &entry.ActorUsername, &entry.ClientIP, &entry.UserAgent, &entry.RequestID}, extra...)
+	if err := rows.Scan(dest...); err != nil {
+		return err
+	}
+	if actorUserID.Valid {
+		id := int(actorUserID.Int64)
+		entry.ActorUserID = &id
+	}
 	return nil
 }
 
 // GetByBatchID retrieves all history entries for a specific batch ID, ordered by date.
 func (r *historyRepository) GetByBatchID(batchID string, userID int) ([]models.History, error) {
 	var entries []models.History
-	query := `SELECT id, date, entity_type, entity_id, changes, batch_id
+	query := `SELECT ` + historyColumns + `
               FROM history
               WHERE batch_id = $1 AND user_id = $2
               ORDER BY date ASC` // Order by date to maintain sequence within a batch
@@ -145,7 +213,7 @@ func (r *historyRepository) GetByBatchID(batchID string, userID int) ([]models.H
 
 	for rows.Next() {
 		var entry models.History
-		if err := rows.Scan(&entry.ID, &entry.Date, &entry.EntityType, &entry.EntityID, &entry.Changes, &entry.BatchID); err != nil {
+		if err := scanHistory(rows, &entry); err != nil {
 			return nil, fmt.Errorf("failed to scan history entry: %w", err)
 		}
 		entries = append(entries, entry)
@@ -157,15 +225,16 @@ func (r *historyRepository) GetByBatchID(batchID string, userID int) ([]models.H
 	return entries, nil
 }
 
-// GetHistory retrieves a paginated list of all history entries, ordered by date descending.
-func (r *historyRepository) GetHistory(limit, offset int, userID int) ([]models.History, error) {
+// GetHistory retrieves a paginated list of the history entries matching the filter, ordered by date descending.
+func (r *historyRepository) GetHistory(filter models.HistoryFilter, limit, offset int, userID int) ([]models.History, error) {
 	var entries []models.History
-	query := `SELECT id, date, entity_type, entity_id, changes, batch_id
+	filterClause, filterArgs := historyFilterClause(filter, 3)
+	query := `SELECT ` + historyColumns + `
               FROM history
-              WHERE user_id = $3
+              WHERE user_id = $3` + filterClause + `
               ORDER BY date DESC
               LIMIT $1 OFFSET $2`
-	rows, err := r.db.Query(query, limit, offset, userID)
+	rows, err := r.db.Query(query, append([]interface{}{limit, offset, userID}, filterArgs...)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query history entries: %w", err)
 	}
@@ -173,7 +242,7 @@ func (r *historyRepository) GetHistory(limit, offset int, userID int) ([]models.
 
 	for rows.Next() {
 		var entry models.History
-		if err := rows.Scan(&entry.ID, &entry.Date, &entry.EntityType, &entry.EntityID, &entry.Changes, &entry.BatchID); err != nil {
+		if err := scanHistory(rows, &entry); err != nil {
 			return nil, fmt.Errorf("failed to scan history entry: %w", err)
 		}
 		entries = append(entries, entry)
@@ -187,7 +256,7 @@ func (r *historyRepository) GetHistory(limit, offset int, userID int) ([]models.
 // GetHistoryByEntity retrieves all history entries for a specific entity, ordered by date descending.
 func (r *historyRepository) GetHistoryByEntity(entityType, entityID string, userID int) ([]models.History, error) {
 	var entries []models.History
-	query := `SELECT id, date, entity_type, entity_id, changes, batch_id
+	query := `SELECT ` + historyColumns + `
               FROM history
               WHERE entity_type = $1 AND entity_id = $2 AND user_id = $3
               ORDER BY date DESC`
@@ -199,7 +268,7 @@ func (r *historyRepository) GetHistoryByEntity(entityType, entityID string, user
 
 	for rows.Next() {
 		var entry models.History
-		if err := rows.Scan(&entry.ID, &entry.Date, &entry.EntityType, &entry.EntityID, &entry.Changes, &entry.BatchID); err != nil {
+		if err := scanHistory(rows, &entry); err != nil {
 			return nil, fmt.Errorf("failed to scan history entry for entity %s/%s: %w", entityType, entityID, err)
 		}
 		entries = append(entries, entry)
@@ -210,10 +279,155 @@ func (r *historyRepository) GetHistoryByEntity(entityType, entityID string, user
 	return entries, nil
 }
 
+// historyActionAliases maps the generic actions accepted by HistoryFilter.Action to the
+// actions actually stored in the changes JSON of products and lotes.
+var historyActionAliases = map[string][]string{
+	"created": {"created"},
+	"updated": {"updated", "quantity_updated", "details_updated", "product_details_updated", "quantity_adjusted"},
+	"deleted": {"deleted"},
+}
+
+// likeEscaper escapes the ILIKE wildcards in user-supplied search text.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// historyFilterClause builds the SQL conditions (each prefixed with " AND ") for a HistoryFilter.
+// Placeholders are numbered from argOffset+1 so the clause can follow the caller's own arguments.
+func historyFilterClause(filter models.HistoryFilter, argOffset int) (string, []interface{}) {
+	var clause strings.Builder
+	var args []interface{}
+	next := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", argOffset+len(args))
+	}
+
+	if filter.From != nil {
+		clause.WriteString(" AND date >= " + next(*filter.From))
+	}
+	if filter.To != nil {
+		clause.WriteString(" AND date <= " + next(*filter.To))
+	}
+	if filter.EntityType != "" {
+		clause.WriteString(" AND entity_type = " + next(filter.EntityType))
+	}
+	if filter.Action != "" {
+		actions, ok := historyActionAliases[filter.Action]
+		if !ok {
+			actions = []string{filter.Action}
+		}
+		clause.WriteString(" AND changes->>'action' = ANY(" + next(pq.Array(actions)) + ")")
+	}
+	if filter.ProductID != "" {
+		p := next(filter.ProductID)
+		clause.WriteString(" AND ((entity_type IN ('product', 'product_batch_context') AND entity_id = " + p +
+			") OR (entity_type = 'lote' AND changes->>'productId' = " + p + "))")
+	}
+	if filter.ActorUserID != nil {
+		clause.WriteString(" AND actor_user_id = " + next(*filter.ActorUserID))
+	}
+	if filter.Search != "" {
+		p := next("%" + likeEscaper.Replace(filter.Search) + "%")
+		clause.WriteString(" AND (changes->>'productName' ILIKE " + p +
+			" OR changes->>'productNameSnapshot' ILIKE " + p +
+			" OR EXISTS (SELECT 1 FROM products p WHERE p.user_id = history.user_id" +
+			" AND p.id = COALESCE(history.changes->>'productId', history.entity_id) AND p.name ILIKE " + p + "))")
+	}
+	return clause.String(), args
+}
+
+// GetHistoryInRange retrieves the history entries with after < date <= until, in chronological order.
+// A zero until means there is no upper bound.
+func (r *historyRepository) GetHistoryInRange(after, until time.Time, userID int) ([]models.History, error) {
+	var untilParam interface{}
+	if !until.IsZero() {
+		untilParam = until
+	}
+
+	var entries []models.History
+	query := `SELECT ` + historyColumns + `
+              FROM history
+              WHERE user_id = $1
+                AND date > $2
+                AND ($3::timestamptz IS NULL OR date <= $3)
+              ORDER BY date ASC, id ASC`
+	rows, err := r.db.Query(query, userID, after, untilParam)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history entries in range: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry models.History
+		if err := scanHistory(rows, &entry); err != nil {
+			return nil, fmt.Errorf("failed to scan history entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration for GetHistoryInRange: %w", err)
+	}
+	return entries, nil
+}
+
+// GetProductHistory retrieves the entries of a product, of its batch contexts and of all its lotes,
+// in the order they were recorded.
+func (r *historyRepository) GetProductHistory(productID string, userID int) ([]models.History, error) {
+	var entries []models.History
+	filterClause, filterArgs := historyFilterClause(models.HistoryFilter{ProductID: productID}, 1)
+	query := `SELECT ` + historyColumns + `
+              FROM history
+              WHERE user_id = $1` + filterClause + `
+              ORDER BY date ASC, seq ASC NULLS FIRST, id ASC`
+	rows, err := r.db.Query(query, append([]interface{}{userID}, filterArgs...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history of product %s: %w", productID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry models.History
+		if err := scanHistory(rows, &entry); err != nil {
+			return nil, fmt.Errorf("failed to scan history entry of product %s: %w", productID, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration for GetProductHistory %s: %w", productID, err)
+	}
+	return entries, nil
+}
+
+// WalkHistory calls fn for every entry matching the filter in the order they were recorded,
+// without loading them all in memory. Walking stops at the first error returned by fn.
+func (r *historyRepository) WalkHistory(filter models.HistoryFilter, userID int, fn func(entry models.History) error) error {
+	filterClause, filterArgs := historyFilterClause(filter, 1)
+	query := `SELECT ` + historyColumns + `
+              FROM history
+              WHERE user_id = $1` + filterClause + `
+              ORDER BY date ASC, seq ASC NULLS FIRST, id ASC`
+	rows, err := r.db.Query(query, append([]interface{}{userID}, filterArgs...)...)
+	if err != nil {
+		return fmt.Errorf("failed to query history entries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry models.History
+		if err := scanHistory(rows, &entry); err != nil {
+			return fmt.Errorf("failed to scan history entry: %w", err)
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
 // GetGroupedHistoryBatches retrieves history entries grouped by batch ID, with pagination for batches.
-func (r *historyRepository) GetGroupedHistoryBatches(page, pageSize int, userID int) (*models.PaginatedHistoryBatchGroups, error) {
+// A batch is included when at least one of its entries matches the filter; its records are always complete.
+func (r *historyRepository) GetGroupedHistoryBatches(filter models.HistoryFilter, page, pageSize int, userID int) (*models.PaginatedHistoryBatchGroups, error) {
+	countClause, countArgs := historyFilterClause(filter, 1)
 	var totalBatches int
-	err := r.db.QueryRow("SELECT COUNT(DISTINCT batch_id) FROM history WHERE user_id = $1", userID).Scan(&totalBatches)
+	err := r.db.QueryRow("SELECT COUNT(DISTINCT batch_id) FROM history WHERE user_id = $1"+countClause, append([]interface{}{userID}, countArgs...)...).Scan(&totalBatches)
 	if err != nil {
 		if err == sql.ErrNoRows { // If no history entries at all
 			totalBatches = 0
@@ -236,23 +450,28 @@ func (r *historyRepository) GetGroupedHistoryBatches(page, pageSize int, userID
 
 	// Step 1: Get paginated batch_ids and their first entry timestamp
 	// We order by the earliest date within each batch to ensure consistent batch ordering.
-	// The batch_id itself is used as a tie-breaker if timestamps are identical (though unlikely with RFC3339).
+	// The batch_id itself is used as a tie-breaker if timestamps are identical.
+	filterClause, filterArgs := historyFilterClause(filter, 3)
+	batchFilter := ""
+	if filterClause != "" {
+		batchFilter = " AND batch_id IN (SELECT batch_id FROM history WHERE user_id = $3" + filterClause + ")"
+	}
 	batchQuery := `
         SELECT batch_id, MIN(date) as first_entry_date
         FROM history
-        WHERE user_id = $3
+        WHERE user_id = $3` + batchFilter + `
         GROUP BY batch_id
         ORDER BY first_entry_date DESC, batch_id DESC
         LIMIT $1 OFFSET $2
     `
 	type BatchInfo struct {
-		BatchID        string `db:"batch_id"`
-		FirstEntryDate string `db:"first_entry_date"`
+		BatchID        string    `db:"batch_id"`
+		FirstEntryDate time.Time `db:"first_entry_date"`
 	}
 	var batchInfos []BatchInfo
 
 	// Changed from r.db.Select to manual iteration
-	rowsBatchInfo, err := r.db.Query(batchQuery, pageSize, offset, userID)
+	rowsBatchInfo, err := r.db.Query(batchQuery, append([]interface{}{pageSize, offset, userID}, filterArgs...)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query paginated batch IDs: %w", err)
 	}
@@ -283,7 +502,7 @@ func (r *historyRepository) GetGroupedHistoryBatches(page, pageSize int, userID
 
 	// Step 2: For each batch_id, get all its records
 	recordsQuery := `
-        SELECT id, date, entity_type, entity_id, changes, batch_id
+        SELECT ` + historyColumns + `
         FROM history
         WHERE batch_id = $1 AND user_id = $2
         ORDER BY date ASC
@@ -300,7 +519,7 @@ func (r *historyRepository) GetGroupedHistoryBatches(page, pageSize int, userID
 
 		for rowsRecords.Next() {
 			var record models.History
-			if errScan := rowsRecords.Scan(&record.ID, &record.Date, &record.EntityType, &record.EntityID, &record.Changes, &record.BatchID); errScan != nil {
+			if errScan := scanHistory(rowsRecords, &record); errScan != nil {
 				log.Printf("Error scanning record for batch_id %s: %v. Skipping this record.", batchInfo.BatchID, errScan)
 				// Decide if a single scan error should fail the whole batch or just skip the record
 				continue
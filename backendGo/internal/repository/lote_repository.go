@@ -11,6 +11,7 @@ import (
 
 type LoteRepository interface {
 	Create(tx *sql.Tx, lote *models.Lote) error
+	CreateWithTimestampsTx(tx *sql.Tx, lote *models.Lote) error
 	GetByID(id string, userID int) (*models.Lote, error)
 	GetByProductID(productID string, userID int) ([]models.Lote, error)
 	Update(tx *sql.Tx, lote *models.Lote) error
@@ -47,6 +48,18 @@ func (r *loteRepository) Create(tx *sql.Tx, lote *models.Lote) error {
 	return nil
 }
 
+// CreateWithTimestampsTx inserts a lote with the ID and timestamps already set on it,
+// as when an account export is restored.
+func (r *loteRepository) CreateWithTimestampsTx(tx *sql.Tx, lote *models.Lote) error {
+	_, err := tx.Exec(`INSERT INTO product_lots (id, product_id, user_id, quantity, data_validade, created_at, updated_at)
+                       VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		lote.ID, lote.ProductID, lote.UserID, lote.Quantity, lote.DataValidade, lote.CreatedAt, lote.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create lote: %w", err)
+	}
+	return nil
+}
+
 func (r *loteRepository) GetByID(id string, userID int) (*models.Lote, error) {
 	lote := &models.Lote{}
 	query := `SELECT id, product_id, user_id, quantity, data_validade, created_at, updated_at 
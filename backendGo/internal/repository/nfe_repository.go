@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+)
+
+// NFeRepository defines the interface for NF-e import data operations
+type NFeRepository interface {
+	GetMappings(supplierDocument string, userID int) (map[string]models.SupplierProductMapping, error)
+	ListMappings(userID int) ([]models.SupplierProductMapping, error)
+	SaveMappingTx(tx *sql.Tx, mapping models.SupplierProductMapping, userID int) error
+	GetImport(accessKey string, userID int) (*models.NFeImport, error)
+	CreateImportTx(tx *sql.Tx, imp *models.NFeImport) error
+	ListImports(userID int) ([]models.NFeImport, error)
+}
+
+type nfeRepository struct {
+	db *sql.DB
+}
+
+// NewNFeRepository creates a new NFeRepository
+func NewNFeRepository(db *sql.DB) NFeRepository {
+	return &nfeRepository{db: db}
+}
+
+const nfeImportColumns = `id, user_id, access_key, number, series, supplier_document, supplier_name,
+                          issued_at, batch_id, lote_count, imported_at`
+
+func scanNFeImport(row interface{ Scan(...interface{}) error }, imp *models.NFeImport) error {
+	return row.Scan(&imp.ID, &imp.UserID, &imp.AccessKey, &imp.Number, &imp.Series, &imp.SupplierDocument, &imp.SupplierName,
+		&imp.IssuedAt, &imp.BatchID, &imp.LoteCount, &imp.ImportedAt)
+}
+
+func scanSupplierMapping(rows *sql.Rows, m *models.SupplierProductMapping) error {
+	var factor sql.NullFloat64
+	if err := rows.Scan(&m.SupplierDocument, &m.SupplierCode, &m.ProductID, &factor, &m.UpdatedAt); err != nil {
+		return err
+	}
+	if factor.Valid {
+		m.UnitFactor = &factor.Float64
+	}
+	return nil
+}
+
+// GetMappings retrieves the saved mappings of a supplier, keyed by supplier code.
+func (r *nfeRepository) GetMappings(supplierDocument string, userID int) (map[string]models.SupplierProductMapping, error) {
+	rows, err := r.db.Query(`SELECT supplier_document, supplier_code, product_id, unit_factor, updated_at
+                             FROM supplier_product_mappings
+                             WHERE user_id = $1 AND supplier_document = $2`, userID, supplierDocument)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query supplier mappings: %w", err)
+	}
+	defer rows.Close()
+
+	mappings := map[string]models.SupplierProductMapping{}
+	for rows.Next() {
+		var m models.SupplierProductMapping
+		if err := scanSupplierMapping(rows, &m); err != nil {
+			return nil, fmt.Errorf("failed to scan supplier mapping: %w", err)
+		}
+		mappings[m.SupplierCode] = m
+	}
+	return mappings, rows.Err()
+}
+
+// ListMappings retrieves every saved mapping of the user, by supplier and code.
+func (r *nfeRepository) ListMappings(userID int) ([]models.SupplierProductMapping, error) {
+	rows, err := r.db.Query(`SELECT supplier_document, supplier_code, product_id, unit_factor, updated_at
+                             FROM supplier_product_mappings
+                             WHERE user_id = $1
+                             ORDER BY supplier_document, supplier_code`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query supplier mappings: %w", err)
+	}
+	defer rows.Close()
+
+	mappings := []models.SupplierProductMapping{}
+	for rows.Next() {
+		var m models.SupplierProductMapping
+		if err := scanSupplierMapping(rows, &m); err != nil {
+			return nil, fmt.Errorf("failed to scan supplier mapping: %w", err)
+		}
+		mappings = append(mappings, m)
+	}
+	return mappings, rows.Err()
+}
+
+// SaveMappingTx creates or replaces the mapping of a supplier code.
+func (r *nfeRepository) SaveMappingTx(tx *sql.Tx, mapping models.SupplierProductMapping, userID int) error {
+	_, err := tx.Exec(`INSERT INTO supplier_product_mappings (user_id, supplier_document, supplier_code, product_id, unit_factor)
+                       VALUES ($1, $2, $3, $4, $5)
+                       ON CONFLICT (user_id, supplier_document, supplier_code)
+                       DO UPDATE SET product_id = EXCLUDED.product_id, unit_factor = EXCLUDED.unit_factor, updated_at = CURRENT_TIMESTAMP`,
+		userID, mapping.SupplierDocument, mapping.SupplierCode, mapping.ProductID, mapping.UnitFactor)
+	if err != nil {
+		return fmt.Errorf("failed to save mapping of supplier code %s: %w", mapping.SupplierCode, err)
+	}
+	return nil
+}
+
+// GetImport retrieves the import of an invoice, or nil if it was not imported.
+func (r *nfeRepository) GetImport(accessKey string, userID int) (*models.NFeImport, error) {
+	var imp models.NFeImport
+	err := scanNFeImport(r.db.QueryRow(`SELECT `+nfeImportColumns+` FROM nfe_imports WHERE access_key = $1 AND user_id = $2`, accessKey, userID), &imp)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get NF-e import: %w", err)
+	}
+	return &imp, nil
+}
+
+// CreateImportTx records the import of an invoice.
+func (r *nfeRepository) CreateImportTx(tx *sql.Tx, imp *models.NFeImport) error {
+	err := tx.QueryRow(`INSERT INTO nfe_imports (user_id, access_key, number, series, supplier_document, supplier_name,
+                                                 issued_at, batch_id, lote_count)
+                        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+                        RETURNING id, imported_at`,
+		imp.UserID, imp.AccessKey, imp.Number, imp.Series, imp.SupplierDocument, imp.SupplierName,
+		imp.IssuedAt, imp.BatchID, imp.LoteCount).Scan(&imp.ID, &imp.ImportedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record NF-e import: %w", err)
+	}
+	return nil
+}
+
+// ListImports retrieves the user's imported invoices, newest first.
+func (r *nfeRepository) ListImports(userID int) ([]models.NFeImport, error) {
+	rows, err := r.db.Query(`SELECT `+nfeImportColumns+` FROM nfe_imports WHERE user_id = $1 ORDER BY imported_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query NF-e imports: %w", err)
+	}
+	defer rows.Close()
+
+	imports := []models.NFeImport{}
+	for rows.Next() {
+		var imp models.NFeImport
+		if err := scanNFeImport(rows, &imp); err != nil {
+			return nil, fmt.Errorf("failed to scan NF-e import: %w", err)
+		}
+		imports = append(imports, imp)
+	}
+	return imports, rows.Err()
+}
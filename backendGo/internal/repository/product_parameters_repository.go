@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+)
+
+// ProductParametersRepository defines the interface for per-product parameter data operations
+type ProductParametersRepository interface {
+	Get(productID string, userID int) (*models.ProductParameters, error)
+	List(userID int) ([]models.ProductParameters, error)
+	Save(params *models.ProductParameters, userID int) error
+	SaveTx(tx *sql.Tx, params *models.ProductParameters, userID int) error
+}
+
+type productParametersRepository struct {
+	db *sql.DB
+}
+
+// NewProductParametersRepository creates a new ProductParametersRepository
+func NewProductParametersRepository(db *sql.DB) ProductParametersRepository {
+	return &productParametersRepository{db: db}
+}
+
+const productParametersColumns = `product_id, unit_cost, min_quantity, max_quantity, preferred_supplier_document, location, code, updated_at`
+
+func scanProductParameters(row interface{ Scan(...interface{}) error }, p *models.ProductParameters) error {
+	var unitCost, minQuantity, maxQuantity sql.NullFloat64
+	var supplier, location, code sql.NullString
+	if err := row.Scan(&p.ProductID, &unitCost, &minQuantity, &maxQuantity, &supplier, &location, &code, &p.UpdatedAt); err != nil {
+		return err
+	}
+	p.UnitCost = nullFloat(unitCost)
+	p.MinQuantity = nullFloat(minQuantity)
+	p.MaxQuantity = nullFloat(maxQuantity)
+	if supplier.Valid {
+		p.PreferredSupplierDocument = &supplier.String
+	}
+	if location.Valid {
+		p.Location = &location.String
+	}
+	if code.Valid {
+		p.Code = &code.String
+	}
+	return nil
+}
+
+func nullFloat(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
+
+// Get retrieves the parameters of a product, or nil if none were saved.
+func (r *productParametersRepository) Get(productID string, userID int) (*models.ProductParameters, error) {
+	var p models.ProductParameters
+	err := scanProductParameters(r.db.QueryRow(`SELECT `+productParametersColumns+` FROM product_parameters
+                                                WHERE product_id = $1 AND user_id = $2`, productID, userID), &p)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get product parameters: %w", err)
+	}
+	return &p, nil
+}
+
+// List retrieves the saved parameters of all of the user's products.
+func (r *productParametersRepository) List(userID int) ([]models.ProductParameters, error) {
+	rows, err := r.db.Query(`SELECT `+productParametersColumns+` FROM product_parameters
+                             WHERE user_id = $1 ORDER BY product_id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query product parameters: %w", err)
+	}
+	defer rows.Close()
+
+	list := []models.ProductParameters{}
+	for rows.Next() {
+		var p models.ProductParameters
+		if err := scanProductParameters(rows, &p); err != nil {
+			return nil, fmt.Errorf("failed to scan product parameters: %w", err)
+		}
+		list = append(list, p)
+	}
+	return list, rows.Err()
+}
+
+// Save creates or replaces the parameters of a product and sets their UpdatedAt.
+func (r *productParametersRepository) Save(params *models.ProductParameters, userID int) error {
+	return saveProductParameters(r.db, params, userID)
+}
+
+// SaveTx is Save within a transaction.
+func (r *productParametersRepository) SaveTx(tx *sql.Tx, params *models.ProductParameters, userID int) error {
+	return saveProductParameters(tx, params, userID)
+}
+
+func saveProductParameters(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, params *models.ProductParameters, userID int) error {
+	err := q.QueryRow(`INSERT INTO product_parameters (product_id, user_id, unit_cost, min_quantity, max_quantity, preferred_supplier_document, location, code)
+                       VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+                       ON CONFLICT (product_id)
+                       DO UPDATE SET unit_cost = EXCLUDED.unit_cost, min_quantity = EXCLUDED.min_quantity,
+                                     max_quantity = EXCLUDED.max_quantity,
+                                     preferred_supplier_document = EXCLUDED.preferred_supplier_document,
+                                     location = EXCLUDED.location, code = EXCLUDED.code,
+                                     updated_at = CURRENT_TIMESTAMP
+                       RETURNING updated_at`,
+		params.ProductID, userID, params.UnitCost, params.MinQuantity, params.MaxQuantity, params.PreferredSupplierDocument, params.Location, params.Code).Scan(&params.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save parameters of product %s: %w", params.ProductID, err)
+	}
+	return nil
+}
@@ -11,8 +11,12 @@ type ProductRepository interface {
 	GetAll(userID int) ([]models.Product, error)
 	GetByID(id string, userID int) (*models.Product, error)
 	Create(product *models.Product) error
+	CreateTx(tx *sql.Tx, product *models.Product) error
 	Update(product *models.Product) error
 	Delete(id string, userID int) error
+	GetQuantityTotals(userID int) ([]models.ProductQuantityTotals, error)
+	SyncQuantityWithLotes(tx *sql.Tx, id string, userID int) error
+	GetOwnerIDs() ([]int, error)
 }
 
 type productRepository struct {
@@ -86,6 +90,16 @@ func (r *productRepository) Create(product *models.Product) error {
 	return nil
 }
 
+// CreateTx inserts a product within a transaction.
+func (r *productRepository) CreateTx(tx *sql.Tx, product *models.Product) error {
+	_, err := tx.Exec("INSERT INTO products (id, name, unit, quantity, user_id) VALUES ($1, $2, $3, $4, $5)",
+		product.ID, product.Name, product.Unit, product.Quantity, product.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to create product: %w", err)
+	}
+	return nil
+}
+
 func (r *productRepository) Update(product *models.Product) error {
 	// Note: Product.Quantity will be updated by trigger if lotes are managed.
 	// Updating product details other than quantity directly.
@@ -127,3 +141,68 @@ func (r *productRepository) Delete(id string, userID int) error {
 	}
 	return nil
 }
+
+
+// GetQuantityTotals returns, for every product of the user, its stored quantity next to the sum of its lotes.
+func (r *productRepository) GetQuantityTotals(userID int) ([]models.ProductQuantityTotals, error) {
+	rows, err := r.db.Query(`
+        SELECT p.id, p.name, p.quantity, COALESCE(SUM(pl.quantity), 0), COUNT(pl.id)
+        FROM products p
+        LEFT JOIN product_lots pl ON pl.product_id = p.id AND pl.user_id = p.user_id
+        WHERE p.user_id = $1
+        GROUP BY p.id, p.name, p.quantity
+        ORDER BY p.name`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch product quantity totals: %w", err)
+	}
+	defer rows.Close()
+
+	var totals []models.ProductQuantityTotals
+	for rows.Next() {
+		var t models.ProductQuantityTotals
+		if err := rows.Scan(&t.ProductID, &t.ProductName, &t.StoredQuantity, &t.LotesQuantity, &t.LoteCount); err != nil {
+			return nil, fmt.Errorf("failed to scan product quantity totals: %w", err)
+		}
+		totals = append(totals, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration for product quantity totals: %w", err)
+	}
+	return totals, nil
+}
+
+// SyncQuantityWithLotes sets products.quantity to the sum of the product's lotes, as the trigger would.
+func (r *productRepository) SyncQuantityWithLotes(tx *sql.Tx, id string, userID int) error {
+	query := `UPDATE products
+              SET quantity = (SELECT COALESCE(SUM(quantity), 0) FROM product_lots WHERE product_id = $1 AND user_id = $2)
+              WHERE id = $1 AND user_id = $2`
+	var err error
+	if tx != nil {
+		_, err = tx.Exec(query, id, userID)
+	} else {
+		_, err = r.db.Exec(query, id, userID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to sync product quantity with lotes: %w", err)
+	}
+	return nil
+}
+
+// GetOwnerIDs returns the IDs of every user that owns at least one product.
+func (r *productRepository) GetOwnerIDs() ([]int, error) {
+	rows, err := r.db.Query("SELECT DISTINCT user_id FROM products ORDER BY user_id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch product owners: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan product owner: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
\ No newline at end of file
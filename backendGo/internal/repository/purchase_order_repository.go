@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+)
+
+// PurchaseOrderRepository defines the interface for purchase order data operations
+type PurchaseOrderRepository interface {
+	CreateTx(tx *sql.Tx, order *models.PurchaseOrder) error
+	List(userID int) ([]models.PurchaseOrder, error)
+	GetByID(id int64, userID int) (*models.PurchaseOrder, error)
+}
+
+type purchaseOrderRepository struct {
+	db *sql.DB
+}
+
+// NewPurchaseOrderRepository creates a new PurchaseOrderRepository
+func NewPurchaseOrderRepository(db *sql.DB) PurchaseOrderRepository {
+	return &purchaseOrderRepository{db: db}
+}
+
+const purchaseOrderColumns = `id, user_id, status, supplier_document, supplier_name, to_char(expected_on, 'YYYY-MM-DD'), created_at`
+
+func scanPurchaseOrder(row interface{ Scan(...interface{}) error }, order *models.PurchaseOrder) error {
+	var document, name, expectedOn sql.NullString
+	if err := row.Scan(&order.ID, &order.UserID, &order.Status, &document, &name, &expectedOn, &order.CreatedAt); err != nil {
+		return err
+	}
+	if document.Valid {
+		order.SupplierDocument = &document.String
+	}
+	if name.Valid {
+		order.SupplierName = &name.String
+	}
+	if expectedOn.Valid {
+		order.ExpectedOn = &expectedOn.String
+	}
+	return nil
+}
+
+// CreateTx inserts an order with its items, setting their IDs and the order's creation time.
+func (r *purchaseOrderRepository) CreateTx(tx *sql.Tx, order *models.PurchaseOrder) error {
+	err := tx.QueryRow(`INSERT INTO purchase_orders (user_id, status, supplier_document, supplier_name, expected_on)
+                        VALUES ($1, $2, $3, $4, $5)
+                        RETURNING id, created_at`,
+		order.UserID, order.Status, order.SupplierDocument, order.SupplierName, order.ExpectedOn).Scan(&order.ID, &order.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create purchase order: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO purchase_order_items (order_id, product_id, product_name, unit, quantity, unit_cost)
+                             VALUES ($1, $2, $3, $4, $5, $6)
+                             RETURNING id`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare purchase order item insert: %w", err)
+	}
+	defer stmt.Close()
+	for i := range order.Items {
+		item := &order.Items[i]
+		if err := stmt.QueryRow(order.ID, item.ProductID, item.ProductName, item.Unit, item.Quantity, item.UnitCost).Scan(&item.ID); err != nil {
+			return fmt.Errorf("failed to create purchase order item for %s: %w", item.ProductName, err)
+		}
+	}
+	return nil
+}
+
+// List retrieves the user's purchase orders with their items, newest first.
+func (r *purchaseOrderRepository) List(userID int) ([]models.PurchaseOrder, error) {
+	rows, err := r.db.Query(`SELECT `+purchaseOrderColumns+` FROM purchase_orders
+                             WHERE user_id = $1 ORDER BY created_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query purchase orders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := []models.PurchaseOrder{}
+	for rows.Next() {
+		var order models.PurchaseOrder
+		if err := scanPurchaseOrder(rows, &order); err != nil {
+			return nil, fmt.Errorf("failed to scan purchase order: %w", err)
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range orders {
+		if orders[i].Items, err = r.getItems(orders[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return orders, nil
+}
+
+// GetByID retrieves a purchase order with its items, or nil if the user has no such order.
+func (r *purchaseOrderRepository) GetByID(id int64, userID int) (*models.PurchaseOrder, error) {
+	var order models.PurchaseOrder
+	err := scanPurchaseOrder(r.db.QueryRow(`SELECT `+purchaseOrderColumns+` FROM purchase_orders
+                                            WHERE id = $1 AND user_id = $2`, id, userID), &order)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get purchase order: %w", err)
+	}
+	if order.Items, err = r.getItems(order.ID); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (r *purchaseOrderRepository) getItems(orderID int64) ([]models.PurchaseOrderItem, error) {
+	rows, err := r.db.Query(`SELECT id, product_id, product_name, unit, quantity, unit_cost
+                             FROM purchase_order_items WHERE order_id = $1 ORDER BY id`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query purchase order items: %w", err)
+	}
+	defer rows.Close()
+
+	items := []models.PurchaseOrderItem{}
+	for rows.Next() {
+		var item models.PurchaseOrderItem
+		var productID sql.NullString
+		var unitCost sql.NullFloat64
+		if err := rows.Scan(&item.ID, &productID, &item.ProductName, &item.Unit, &item.Quantity, &unitCost); err != nil {
+			return nil, fmt.Errorf("failed to scan purchase order item: %w", err)
+		}
+		if productID.Valid {
+			item.ProductID = &productID.String
+		}
+		item.UnitCost = nullFloat(unitCost)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
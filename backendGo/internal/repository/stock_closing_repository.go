@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/lib/pq"
+)
+
+// periodClosedCode is the SQLSTATE raised by the database when a change to products, lotes or
+// history is dated inside a closed month (migrations 016 and 020).
+const periodClosedCode = "SC001"
+
+// IsPeriodClosed reports whether err was caused by a change refused because it is dated inside a
+// closed month.
+func IsPeriodClosed(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == periodClosedCode
+}
+
+// StockClosingRepository defines the interface for monthly closing data operations
+type StockClosingRepository interface {
+	Create(closing *models.StockClosing, userID int) error
+	Exists(period string, userID int) (bool, error)
+	List(userID int) ([]models.StockClosing, error)
+	GetByPeriod(period string, userID int) (*models.StockClosing, error)
+}
+
+type stockClosingRepository struct {
+	db *sql.DB
+}
+
+// NewStockClosingRepository creates a new StockClosingRepository
+func NewStockClosingRepository(db *sql.DB) StockClosingRepository {
+	return &stockClosingRepository{db: db}
+}
+
+const stockClosingColumns = `id, period, period_end, stock_at, triggered_by, closed_by, product_count, lote_count,
+                             total_value, created_at`
+
+func scanStockClosing(row interface{ Scan(...interface{}) error }, c *models.StockClosing) error {
+	var totalValue sql.NullFloat64
+	if err := row.Scan(&c.ID, &c.Period, &c.PeriodEnd, &c.StockAt, &c.TriggeredBy, &c.ClosedBy, &c.ProductCount,
+		&c.LoteCount, &totalValue, &c.CreatedAt); err != nil {
+		return err
+	}
+	c.TotalValue = nullFloat(totalValue)
+	return nil
+}
+
+// Create stores a closing with its products and lotes in a single transaction, and sets its ID
+// and CreatedAt.
+func (r *stockClosingRepository) Create(closing *models.StockClosing, userID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`INSERT INTO stock_closings (user_id, period, period_end, stock_at, triggered_by, closed_by,
+                                                   product_count, lote_count, total_value)
+                       VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+                       RETURNING id, created_at`,
+		userID, closing.Period, closing.PeriodEnd, closing.StockAt, closing.TriggeredBy, closing.ClosedBy,
+		closing.ProductCount, closing.LoteCount, closing.TotalValue).Scan(&closing.ID, &closing.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create stock closing: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO stock_closing_items (closing_id, product_id, product_name, unit, lote_id,
+                                                              data_validade, quantity, unit_cost, value)
+                             VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement for stock closing items: %w", err)
+	}
+	defer stmt.Close()
+	for _, p := range closing.Products {
+		if _, err := stmt.Exec(closing.ID, p.ProductID, p.ProductName, p.Unit, nil, nil, p.Quantity, p.UnitCost, p.Value); err != nil {
+			return fmt.Errorf("failed to store stock closing product %s: %w", p.ProductID, err)
+		}
+		for _, l := range p.Lotes {
+			var dataValidade interface{}
+			if l.DataValidade != "" {
+				dataValidade = l.DataValidade
+			}
+			if _, err := stmt.Exec(closing.ID, p.ProductID, p.ProductName, p.Unit, l.LoteID, dataValidade, l.Quantity, p.UnitCost, l.Value); err != nil {
+				return fmt.Errorf("failed to store stock closing lote %s: %w", l.LoteID, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit stock closing transaction: %w", err)
+	}
+	return nil
+}
+
+// Exists reports whether the user closed the given period.
+func (r *stockClosingRepository) Exists(period string, userID int) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM stock_closings WHERE user_id = $1 AND period = $2)`, userID, period).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check stock closing: %w", err)
+	}
+	return exists, nil
+}
+
+// List retrieves the user's closings without their products, newest period first.
+func (r *stockClosingRepository) List(userID int) ([]models.StockClosing, error) {
+	rows, err := r.db.Query(`SELECT `+stockClosingColumns+` FROM stock_closings WHERE user_id = $1 ORDER BY period DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stock closings: %w", err)
+	}
+	defer rows.Close()
+
+	closings := []models.StockClosing{}
+	for rows.Next() {
+		var closing models.StockClosing
+		if err := scanStockClosing(rows, &closing); err != nil {
+			return nil, fmt.Errorf("failed to scan stock closing: %w", err)
+		}
+		closings = append(closings, closing)
+	}
+	return closings, rows.Err()
+}
+
+// GetByPeriod retrieves a closing with its products, by name, and their lotes, by expiry date;
+// nil if the user did not close the period.
+func (r *stockClosingRepository) GetByPeriod(period string, userID int) (*models.StockClosing, error) {
+	var closing models.StockClosing
+	err := scanStockClosing(r.db.QueryRow(`SELECT `+stockClosingColumns+` FROM stock_closings WHERE user_id = $1 AND period = $2`, userID, period), &closing)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get stock closing: %w", err)
+	}
+
+	rows, err := r.db.Query(`SELECT product_id, product_name, unit, lote_id, to_char(data_validade, 'YYYY-MM-DD'), quantity,
+                                    unit_cost, value
+                             FROM stock_closing_items
+                             WHERE closing_id = $1
+                             ORDER BY product_name, product_id, lote_id IS NOT NULL, data_validade, lote_id`, closing.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stock closing items: %w", err)
+	}
+	defer rows.Close()
+
+	closing.Products = []models.StockClosingProduct{}
+	for rows.Next() {
+		var p models.StockClosingProduct
+		var loteID, dataValidade sql.NullString
+		var unitCost, value sql.NullFloat64
+		if err := rows.Scan(&p.ProductID, &p.ProductName, &p.Unit, &loteID, &dataValidade, &p.Quantity, &unitCost, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan stock closing item: %w", err)
+		}
+		if !loteID.Valid {
+			p.UnitCost = nullFloat(unitCost)
+			p.Value = nullFloat(value)
+			p.Lotes = []models.StockClosingLote{}
+			closing.Products = append(closing.Products, p)
+			continue
+		}
+		// Lote rows follow the row of their product
+		if n := len(closing.Products); n > 0 && closing.Products[n-1].ProductID == p.ProductID {
+			closing.Products[n-1].Lotes = append(closing.Products[n-1].Lotes, models.StockClosingLote{
+				LoteID:       loteID.String,
+				DataValidade: dataValidade.String,
+				Quantity:     p.Quantity,
+				Value:        nullFloat(value),
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration for stock closing items: %w", err)
+	}
+	return &closing, nil
+}
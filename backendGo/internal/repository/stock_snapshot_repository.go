@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+)
+
+// StockSnapshotRepository defines the interface for stock snapshot data operations
+type StockSnapshotRepository interface {
+	Create(takenAt time.Time, userID int) error
+	CreateForAllUsers(takenAt time.Time) error
+	GetLatestAtOrBefore(at time.Time, userID int) ([]models.StockSnapshot, error)
+	ExistsAt(takenAt time.Time, userID int) (bool, error)
+	CreateFromPositions(takenAt time.Time, userID int, positions []models.ProductStockPosition) error
+	CompactBefore(before time.Time, userID int) (int64, error)
+}
+
+type stockSnapshotRepository struct {
+	db *sql.DB
+}
+
+// NewStockSnapshotRepository creates a new StockSnapshotRepository
+func NewStockSnapshotRepository(db *sql.DB) StockSnapshotRepository {
+	return &stockSnapshotRepository{db: db}
+}
+
+const insertProductSnapshotsQuery = `
+        INSERT INTO stock_snapshots (user_id, taken_at, product_id, product_name, unit, lote_id, quantity, data_validade)
+        SELECT p.user_id, $1, p.id, p.name, p.unit, NULL, p.quantity, NULL
+        FROM products p
+        WHERE ($2::INTEGER IS NULL OR p.user_id = $2)`
+
+const insertLoteSnapshotsQuery = `
+        INSERT INTO stock_snapshots (user_id, taken_at, product_id, product_name, unit, lote_id, quantity, data_validade)
+        SELECT pl.user_id, $1, p.id, p.name, p.unit, pl.id, pl.quantity, pl.data_validade
+        FROM product_lots pl
+        JOIN products p ON p.id = pl.product_id AND p.user_id = pl.user_id
+        WHERE ($2::INTEGER IS NULL OR pl.user_id = $2)`
+
+// Create stores a snapshot of the current products and lotes of a single user.
+func (r *stockSnapshotRepository) Create(takenAt time.Time, userID int) error {
+	return r.insert(takenAt, userID)
+}
+
+// CreateForAllUsers stores a snapshot of the current products and lotes of every user.
+func (r *stockSnapshotRepository) CreateForAllUsers(takenAt time.Time) error {
+	return r.insert(takenAt, nil)
+}
+
+// insert copies products and lotes into stock_snapshots in a single transaction,
+// so product and lote rows of the same snapshot are always consistent with each other.
+func (r *stockSnapshotRepository) insert(takenAt time.Time, userID interface{}) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(insertProductSnapshotsQuery, takenAt, userID); err != nil {
+		return fmt.Errorf("failed to snapshot products: %w", err)
+	}
+	if _, err := tx.Exec(insertLoteSnapshotsQuery, takenAt, userID); err != nil {
+		return fmt.Errorf("failed to snapshot lotes: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit snapshot transaction: %w", err)
+	}
+	return nil
+}
+
+// GetLatestAtOrBefore retrieves every row of the most recent snapshot taken at or before the given instant.
+// It returns an empty slice if no such snapshot exists.
+func (r *stockSnapshotRepository) GetLatestAtOrBefore(at time.Time, userID int) ([]models.StockSnapshot, error) {
+	query := `SELECT id, user_id, taken_at, product_id, product_name, unit, lote_id, quantity,
+                     to_char(data_validade, 'YYYY-MM-DD')
+              FROM stock_snapshots
+              WHERE user_id = $1 AND taken_at = (
+                  SELECT MAX(taken_at) FROM stock_snapshots WHERE user_id = $1 AND taken_at <= $2
+              )
+              ORDER BY product_id, lote_id NULLS FIRST`
+	rows, err := r.db.Query(query, userID, at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stock snapshot: %w", err)
+	}
+	defer rows.Close()
+
+	snapshots := []models.StockSnapshot{}
+	for rows.Next() {
+		var s models.StockSnapshot
+		var loteID, dataValidade sql.NullString
+		if err := rows.Scan(&s.ID, &s.UserID, &s.TakenAt, &s.ProductID, &s.ProductName, &s.Unit, &loteID, &s.Quantity, &dataValidade); err != nil {
+			return nil, fmt.Errorf("failed to scan stock snapshot: %w", err)
+		}
+		if loteID.Valid {
+			s.LoteID = &loteID.String
+		}
+		if dataValidade.Valid {
+			s.DataValidade = &dataValidade.String
+		}
+		snapshots = append(snapshots, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration for stock snapshot: %w", err)
+	}
+	return snapshots, nil
+}
+
+// ExistsAt reports whether the user has a snapshot taken exactly at the given instant.
+func (r *stockSnapshotRepository) ExistsAt(takenAt time.Time, userID int) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM stock_snapshots WHERE user_id = $1 AND taken_at = $2)`, userID, takenAt).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check stock snapshot: %w", err)
+	}
+	return exists, nil
+}
+
+// CreateFromPositions stores reconstructed positions as a snapshot taken at the given instant.
+func (r *stockSnapshotRepository) CreateFromPositions(takenAt time.Time, userID int, positions []models.ProductStockPosition) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO stock_snapshots (user_id, taken_at, product_id, product_name, unit, lote_id, quantity, data_validade)
+                             VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement for snapshot insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, p := range positions {
+		if _, err := stmt.Exec(userID, takenAt, p.ProductID, p.ProductName, p.Unit, nil, p.Quantity, nil); err != nil {
+			return fmt.Errorf("failed to snapshot product %s: %w", p.ProductID, err)
+		}
+		for _, l := range p.Lotes {
+			var dataValidade interface{}
+			if l.DataValidade != "" {
+				dataValidade = l.DataValidade
+			}
+			if _, err := stmt.Exec(userID, takenAt, p.ProductID, p.ProductName, p.Unit, l.LoteID, l.Quantity, dataValidade); err != nil {
+				return fmt.Errorf("failed to snapshot lote %s: %w", l.LoteID, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit snapshot transaction: %w", err)
+	}
+	return nil
+}
+
+// CompactBefore removes the user's snapshots taken before the given instant, except the last one
+// of each month and those marking the cutoff of a history archive. It returns the number of rows removed.
+func (r *stockSnapshotRepository) CompactBefore(before time.Time, userID int) (int64, error) {
+	res, err := r.db.Exec(`DELETE FROM stock_snapshots s
+                           WHERE s.user_id = $1 AND s.taken_at < $2
+                             AND s.taken_at <> (
+                                 SELECT MAX(m.taken_at) FROM stock_snapshots m
+                                 WHERE m.user_id = $1 AND date_trunc('month', m.taken_at) = date_trunc('month', s.taken_at)
+                             )
+                             AND NOT EXISTS (
+                                 SELECT 1 FROM history_archives a WHERE a.user_id = $1 AND a.archived_before = s.taken_at
+                             )`, userID, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to compact stock snapshots: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+)
+
+// SupplierRepository defines the interface for supplier data operations
+type SupplierRepository interface {
+	List(userID int) ([]models.Supplier, error)
+	Save(supplier *models.Supplier, userID int) error
+	SaveTx(tx *sql.Tx, supplier *models.Supplier, userID int) error
+}
+
+type supplierRepository struct {
+	db *sql.DB
+}
+
+// NewSupplierRepository creates a new SupplierRepository
+func NewSupplierRepository(db *sql.DB) SupplierRepository {
+	return &supplierRepository{db: db}
+}
+
+// List retrieves the user's suppliers, by name.
+func (r *supplierRepository) List(userID int) ([]models.Supplier, error) {
+	rows, err := r.db.Query(`SELECT document, name, lead_time_days, updated_at FROM suppliers
+                             WHERE user_id = $1 ORDER BY name, document`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query suppliers: %w", err)
+	}
+	defer rows.Close()
+
+	suppliers := []models.Supplier{}
+	for rows.Next() {
+		var s models.Supplier
+		if err := rows.Scan(&s.Document, &s.Name, &s.LeadTimeDays, &s.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan supplier: %w", err)
+		}
+		suppliers = append(suppliers, s)
+	}
+	return suppliers, rows.Err()
+}
+
+// Save creates or replaces a supplier and sets its UpdatedAt.
+func (r *supplierRepository) Save(supplier *models.Supplier, userID int) error {
+	return saveSupplier(r.db, supplier, userID)
+}
+
+// SaveTx is Save within a transaction.
+func (r *supplierRepository) SaveTx(tx *sql.Tx, supplier *models.Supplier, userID int) error {
+	return saveSupplier(tx, supplier, userID)
+}
+
+func saveSupplier(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, supplier *models.Supplier, userID int) error {
+	err := q.QueryRow(`INSERT INTO suppliers (user_id, document, name, lead_time_days)
+                       VALUES ($1, $2, $3, $4)
+                       ON CONFLICT (user_id, document)
+                       DO UPDATE SET name = EXCLUDED.name, lead_time_days = EXCLUDED.lead_time_days, updated_at = CURRENT_TIMESTAMP
+                       RETURNING updated_at`,
+		userID, supplier.Document, supplier.Name, supplier.LeadTimeDays).Scan(&supplier.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save supplier %s: %w", supplier.Document, err)
+	}
+	return nil
+}
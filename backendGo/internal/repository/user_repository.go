@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/lib/pq"
+)
+
+// Unique constraints of the users table (migration 018 and the table created by InitDB)
+const (
+	usersUsernameKey = "users_username_key"
+	usersEmailIndex  = "idx_users_email_lower"
+)
+
+// IsDuplicateUsername reports whether err was caused by a username already in use.
+func IsDuplicateUsername(err error) bool {
+	return isUniqueViolation(err, usersUsernameKey)
+}
+
+// IsDuplicateEmail reports whether err was caused by an email already in use, whatever its case.
+func IsDuplicateEmail(err error) bool {
+	return isUniqueViolation(err, usersEmailIndex)
+}
+
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
+
+// UserRepository defines the interface for user data operations
+type UserRepository interface {
+	List(accountID int) ([]models.User, error)
+	GetByID(id, accountID int) (*models.User, error)
+	Create(user *models.User, accountID int) error
+	Update(user *models.User, passwordHash string, accountID int) (bool, error)
+	SetActive(id int, active bool, accountID int) (bool, error)
+	SetPassword(id int, passwordHash string, accountID int) (bool, error)
+	Delete(id, accountID int) (bool, error)
+}
+
+type userRepository struct {
+	db *sql.DB
+}
+
+// NewUserRepository creates a new UserRepository
+func NewUserRepository(db *sql.DB) UserRepository {
+	return &userRepository{db: db}
+}
+
+const userColumns = `id, username, password, created_at, email, full_name, active, last_login, role, COALESCE(account_id, id)`
+
+// Every query but Create is limited to the users of one account: its owner, whose ID is the
+// account's, and its members. Users of other accounts are reported as missing.
+const userInAccount = `COALESCE(account_id, id)`
+
+func scanUser(row interface{ Scan(...interface{}) error }, u *models.User) error {
+	var email, fullName sql.NullString
+	var lastLogin sql.NullTime
+	if err := row.Scan(&u.ID, &u.Username, &u.Password, &u.CreatedAt, &email, &fullName, &u.Active, &lastLogin, &u.Role, &u.AccountID); err != nil {
+		return err
+	}
+	if email.Valid {
+		u.Email = &email.String
+	}
+	if fullName.Valid {
+		u.FullName = &fullName.String
+	}
+	if lastLogin.Valid {
+		u.LastLogin = &lastLogin.Time
+	}
+	return nil
+}
+
+// List retrieves the users of the account, by username.
+func (r *userRepository) List(accountID int) ([]models.User, error) {
+	rows, err := r.db.Query(`SELECT `+userColumns+` FROM users WHERE `+userInAccount+` = $1 ORDER BY username`, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var user models.User
+		if err := scanUser(rows, &user); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// GetByID retrieves a user of the account with its password hash, or nil if there is no such user.
+func (r *userRepository) GetByID(id, accountID int) (*models.User, error) {
+	var user models.User
+	err := scanUser(r.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = $1 AND `+userInAccount+` = $2`, id, accountID), &user)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return &user, nil
+}
+
+// Create stores a new active user, whose Password holds the hash, as a member of the account
+// owned by accountID, or as the owner of a new account when accountID is 0, and sets its ID,
+// CreatedAt and AccountID.
+func (r *userRepository) Create(user *models.User, accountID int) error {
+	user.Active = true
+	err := r.db.QueryRow(`INSERT INTO users (username, password, email, full_name, active, role, account_id)
+                          VALUES ($1, $2, $3, $4, TRUE, $5, NULLIF($6, 0))
+                          RETURNING id, created_at, COALESCE(account_id, id)`,
+		user.Username, user.Password, user.Email, user.FullName, user.Role, accountID).Scan(&user.ID, &user.CreatedAt, &user.AccountID)
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	return nil
+}
+
+// Update replaces a user's email and full name, the role unless it is empty and the password
+// hash unless it is empty, all at once, and reports whether the account has the user. A new
+// password revokes the user's tokens. The other fields of user are refreshed from the database.
+func (r *userRepository) Update(user *models.User, passwordHash string, accountID int) (bool, error) {
+	var lastLogin sql.NullTime
+	err := r.db.QueryRow(`UPDATE users SET email = $2, full_name = $3, role = COALESCE(NULLIF($4, ''), role),
+                              password = COALESCE(NULLIF($5, ''), password),
+                              token_version = token_version + CASE WHEN $5 = '' THEN 0 ELSE 1 END
+                          WHERE id = $1 AND `+userInAccount+` = $6
+                          RETURNING username, created_at, active, last_login, role, COALESCE(account_id, id)`,
+		user.ID, user.Email, user.FullName, user.Role, passwordHash, accountID).Scan(&user.Username, &user.CreatedAt, &user.Active, &lastLogin, &user.Role, &user.AccountID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to update user: %w", err)
+	}
+	if lastLogin.Valid {
+		user.LastLogin = &lastLogin.Time
+	}
+	return true, nil
+}
+
+func (r *userRepository) execOnUser(action, query string, args ...interface{}) (bool, error) {
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to %s: %w", action, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to %s: %w", action, err)
+	}
+	return affected > 0, nil
+}
+
+// SetActive enables or disables a user of the account and reports whether the user exists.
+func (r *userRepository) SetActive(id int, active bool, accountID int) (bool, error) {
+	return r.execOnUser("update user status", `UPDATE users SET active = $2 WHERE id = $1 AND `+userInAccount+` = $3`, id, active, accountID)
+}
+
+// SetPassword replaces the password hash of a user of the account, revoking the user's tokens,
+// and reports whether the user exists.
+func (r *userRepository) SetPassword(id int, passwordHash string, accountID int) (bool, error) {
+	return r.execOnUser("update user password", `UPDATE users SET password = $2, token_version = token_version + 1
+                                                 WHERE id = $1 AND `+userInAccount+` = $3`, id, passwordHash, accountID)
+}
+
+// Delete removes a user of the account and reports whether the user existed. Everything the user owns (products,
+// lotes, history, closings, settings...) and the members of the user's account are removed with it
+// by the foreign keys.
+func (r *userRepository) Delete(id, accountID int) (bool, error) {
+	return r.execOnUser("delete user", `DELETE FROM users WHERE id = $1 AND `+userInAccount+` = $2`, id, accountID)
+}
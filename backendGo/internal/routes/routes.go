@@ -7,6 +7,7 @@ import (
 	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/middleware"
 	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/repository" // Added
 	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/service"    // Added
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/utils"
 	"github.com/gin-gonic/gin"
 )
 
@@ -16,18 +17,56 @@ func SetupRoutes(router *gin.Engine, cfg *config.Config) {
 	loteRepository := repository.NewLoteRepository(database.DB)
 	productRepository := repository.NewProductRepository(database.DB, loteRepository) // LoteRepo is a dependency for ProductRepo
 	historyRepository := repository.NewHistoryRepository(database.DB)
+	stockSnapshotRepository := repository.NewStockSnapshotRepository(database.DB)
+	historyArchiveRepository := repository.NewHistoryArchiveRepository(database.DB)
+	nfeRepository := repository.NewNFeRepository(database.DB)
+	productParametersRepository := repository.NewProductParametersRepository(database.DB)
+	supplierRepository := repository.NewSupplierRepository(database.DB)
+	purchaseOrderRepository := repository.NewPurchaseOrderRepository(database.DB)
+	dashboardRepository := repository.NewDashboardRepository(database.DB)
+	emailReportRepository := repository.NewEmailReportRepository(database.DB)
+	stockClosingRepository := repository.NewStockClosingRepository(database.DB)
+	userRepository := repository.NewUserRepository(database.DB)
 
     // Initialize Services
-	historyService := service.NewHistoryService(historyRepository, productRepository) // Pass productRepository
+	historyService := service.NewHistoryService(historyRepository, productRepository, loteRepository, historyArchiveRepository, stockSnapshotRepository) // Pass productRepository
 	// Pass database.DB to LoteService for transaction management
 	loteService := service.NewLoteService(loteRepository, productRepository, historyService, database.DB)
+	stockReportService := service.NewStockReportService(historyRepository, productRepository, stockSnapshotRepository)
+	historyArchiveService := service.NewHistoryArchiveService(historyRepository, historyArchiveRepository, stockSnapshotRepository, stockReportService, database.DB, cfg.History)
+	productExportService := service.NewProductExportService(productRepository)
+	inventorySheetService := service.NewInventorySheetService(productRepository, productParametersRepository)
+	consumptionService := service.NewConsumptionService(historyRepository, productRepository, productParametersRepository)
+	importService := service.NewImportService(productRepository, loteRepository, historyRepository, database.DB)
+	nfeService := service.NewNFeService(nfeRepository, productRepository, loteRepository, historyRepository, database.DB)
+	reorderService := service.NewReorderService(productRepository, productParametersRepository, supplierRepository, nfeRepository, purchaseOrderRepository, consumptionService, database.DB)
+	accountService := service.NewAccountService(productRepository, loteRepository, historyRepository, historyArchiveRepository, nfeRepository, productParametersRepository, supplierRepository, purchaseOrderRepository, database.DB, cfg.History)
+	consistencyService := service.NewConsistencyService(productRepository, historyRepository, historyArchiveRepository, stockSnapshotRepository, database.DB)
+	dashboardService := service.NewDashboardService(dashboardRepository, utils.NewBackupManager(cfg))
+	closingService := service.NewClosingService(stockClosingRepository, productRepository, productParametersRepository, stockReportService)
+	emailReportService := service.NewEmailReportService(emailReportRepository, productRepository, dashboardRepository, utils.NewMailer(cfg.SMTP))
+	spedService := service.NewSpedService(stockClosingRepository, productParametersRepository, stockReportService)
+	userService := service.NewUserService(userRepository, historyArchiveRepository, cfg.History.ArchiveDir, cfg.Admin.Username)
 
 
     // Create controllers
 	authController := controllers.NewAuthController(cfg)
-	productController := controllers.NewProductController(productRepository, historyService) // Updated
+	productController := controllers.NewProductController(productRepository, historyService, productExportService, consumptionService) // Updated
 	historyController := controllers.NewHistoryController(historyService)                   // Updated
 	loteController := controllers.NewLoteController(loteService)                           // Added
+	productParametersController := controllers.NewProductParametersController(productParametersRepository, productRepository)
+	reportController := controllers.NewReportController(stockReportService, consumptionService, inventorySheetService, spedService)
+	consistencyController := controllers.NewConsistencyController(consistencyService)
+	importController := controllers.NewImportController(importService)
+	nfeController := controllers.NewNFeController(nfeService)
+	historyArchiveController := controllers.NewHistoryArchiveController(historyArchiveService, cfg.History.RetentionMonths)
+	accountController := controllers.NewAccountController(accountService)
+	supplierController := controllers.NewSupplierController(supplierRepository)
+	purchaseOrderController := controllers.NewPurchaseOrderController(reorderService)
+	dashboardController := controllers.NewDashboardController(dashboardService)
+	emailReportController := controllers.NewEmailReportController(emailReportService)
+	closingController := controllers.NewClosingController(closingService)
+	userController := controllers.NewUserController(userService)
 
     // API routes
 	api := router.Group("/api")
@@ -38,28 +77,81 @@ func SetupRoutes(router *gin.Engine, cfg *config.Config) {
 			auth.POST("/login", authController.Login)
 			auth.GET("/verify", middleware.AuthMiddleware(cfg), authController.Verify)
 			auth.GET("/health", authController.Health)
+			auth.PUT("/password", middleware.AuthMiddleware(cfg), userController.ChangePassword)
 		}
 
         // Product routes
 		products := api.Group("/products")
 		{
 			products.GET("", middleware.AuthMiddleware(cfg), productController.GetAll)
+			products.GET("/export", middleware.AuthMiddleware(cfg), productController.Export)
 			products.GET("/:product_id", middleware.AuthMiddleware(cfg), productController.GetByID) // Changed :id to :product_id
-			products.POST("", middleware.AuthMiddleware(cfg), productController.Create)
-			products.PUT("/:product_id", middleware.AuthMiddleware(cfg), productController.Update) // Changed :id to :product_id
-			products.DELETE("/:product_id", middleware.AuthMiddleware(cfg), productController.Delete) // Changed :id to :product_id
+			products.POST("", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermProductsWrite), productController.Create)
+			products.PUT("/:product_id", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermProductsWrite), productController.Update) // Changed :id to :product_id
+			products.DELETE("/:product_id", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermProductsWrite), productController.Delete) // Changed :id to :product_id
 
             // Lote routes (nested under products for creation and listing)
-			products.POST("/:product_id/lotes", middleware.AuthMiddleware(cfg), loteController.CreateLote)
+			products.POST("/:product_id/lotes", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermLotesWrite), loteController.CreateLote)
 			products.GET("/:product_id/lotes", middleware.AuthMiddleware(cfg), loteController.GetLotesForProduct)
+
+            // Per-product parameters used by the reports
+			products.GET("/:product_id/parameters", middleware.AuthMiddleware(cfg), productParametersController.Get)
+			products.PUT("/:product_id/parameters", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermProductsWrite), productParametersController.Update)
+		}
+
+        // Dashboard summary
+		api.GET("/dashboard", middleware.AuthMiddleware(cfg), dashboardController.Get)
+
+        // Bulk import of products and lotes
+		api.POST("/import", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermProductsWrite), importController.Import)
+
+        // NF-e invoice routes (receiving stock)
+		nfe := api.Group("/nfe")
+		{
+			nfe.POST("/import", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermProductsWrite), nfeController.Import)
+			nfe.GET("/imports", middleware.AuthMiddleware(cfg), nfeController.ListImports)
+			nfe.GET("/mappings", middleware.AuthMiddleware(cfg), nfeController.ListMappings)
+		}
+
+        // Supplier routes
+		suppliers := api.Group("/suppliers")
+		{
+			suppliers.GET("", middleware.AuthMiddleware(cfg), supplierController.List)
+			suppliers.PUT("/:document", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermProductsWrite), supplierController.Save)
+		}
+
+        // Purchase order routes
+		purchaseOrders := api.Group("/purchase-orders")
+		{
+			purchaseOrders.GET("", middleware.AuthMiddleware(cfg), purchaseOrderController.List)
+			purchaseOrders.GET("/:id", middleware.AuthMiddleware(cfg), purchaseOrderController.GetByID)
+			purchaseOrders.POST("/from-suggestions", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermProductsWrite), purchaseOrderController.CreateFromSuggestions)
+		}
+
+        // Scheduled email report routes
+		emailReports := api.Group("/email-reports")
+		{
+			emailReports.GET("", middleware.AuthMiddleware(cfg), emailReportController.List)
+			emailReports.POST("", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermStockManage), emailReportController.Create)
+			emailReports.GET("/deliveries", middleware.AuthMiddleware(cfg), emailReportController.ListDeliveries)
+			emailReports.PUT("/:id", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermStockManage), emailReportController.Update)
+			emailReports.DELETE("/:id", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermStockManage), emailReportController.Delete)
+			emailReports.POST("/:id/send", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermStockManage), emailReportController.Send)
+		}
+
+        // Account data export and restore
+		account := api.Group("/account")
+		{
+			account.GET("/export", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermBackups), accountController.Export)
+			account.POST("/import", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermBackups), accountController.Import)
 		}
 
         // Standalone Lote routes (for updating/deleting specific lotes by their own ID)
 		lotes := api.Group("/lotes")
 		{
 			// GET /lotes/:lote_id could be added if needed, but GetLotesForProduct might be sufficient
-			lotes.PUT("/:lote_id", middleware.AuthMiddleware(cfg), loteController.UpdateLote)
-			lotes.DELETE("/:lote_id", middleware.AuthMiddleware(cfg), loteController.DeleteLote)
+			lotes.PUT("/:lote_id", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermLotesWrite), loteController.UpdateLote)
+			lotes.DELETE("/:lote_id", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermLotesWrite), loteController.DeleteLote)
 		}
 
 
@@ -67,14 +159,64 @@ func SetupRoutes(router *gin.Engine, cfg *config.Config) {
 		history := api.Group("/history")
 		{
 			history.GET("", middleware.AuthMiddleware(cfg), historyController.GetAll) // Now supports ?batch_id=
-			history.POST("", middleware.AuthMiddleware(cfg), historyController.Create)
+			history.POST("", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermHistoryWrite), historyController.Create)
 			history.GET("/:entity_type/:entity_id", middleware.AuthMiddleware(cfg), historyController.GetHistoryForEntity)
 			
 			// New batch endpoints
-			history.POST("/batch", middleware.AuthMiddleware(cfg), historyController.CreateBatch)
+			history.POST("/batch", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermHistoryWrite), historyController.CreateBatch)
 			history.GET("/batch/:batch_id", middleware.AuthMiddleware(cfg), historyController.GetByBatch)
-			history.GET("/grouped", middleware.AuthMiddleware(cfg), historyController.GetGrouped) 
-			history.POST("/product-context", middleware.AuthMiddleware(cfg), historyController.CreateProductBatchContext) // New route
+			history.GET("/grouped", middleware.AuthMiddleware(cfg), historyController.GetGrouped)
+			history.GET("/export", middleware.AuthMiddleware(cfg), historyController.Export)
+			history.GET("/verify", middleware.AuthMiddleware(cfg), historyController.VerifyChain)
+			history.GET("/timeline/:product_id", middleware.AuthMiddleware(cfg), historyController.GetProductTimeline)
+			history.POST("/product-context", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermHistoryWrite), historyController.CreateProductBatchContext) // New route
+		}
+
+        // Report routes
+		reports := api.Group("/reports")
+		{
+			reports.GET("/stock-at", middleware.AuthMiddleware(cfg), reportController.GetStockAt)
+			reports.GET("/stock-diff", middleware.AuthMiddleware(cfg), reportController.GetStockDiff)
+			reports.GET("/consumption", middleware.AuthMiddleware(cfg), reportController.GetConsumption)
+			reports.GET("/expiry-waste", middleware.AuthMiddleware(cfg), reportController.GetExpiryWaste)
+			reports.GET("/reorder-suggestions", middleware.AuthMiddleware(cfg), purchaseOrderController.GetSuggestions)
+			reports.GET("/abc", middleware.AuthMiddleware(cfg), reportController.GetABC)
+			reports.GET("/aging", middleware.AuthMiddleware(cfg), reportController.GetAging)
+			reports.GET("/inventory.pdf", middleware.AuthMiddleware(cfg), reportController.GetInventoryPDF)
+			reports.GET("/sped-bloco-h", middleware.AuthMiddleware(cfg), reportController.GetSpedBlocoH)
+			reports.POST("/snapshots", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermStockManage), reportController.CreateSnapshot)
+		}
+
+        // Monthly closing routes
+		closings := api.Group("/closings")
+		{
+			closings.GET("", middleware.AuthMiddleware(cfg), closingController.List)
+			closings.POST("", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermStockManage), closingController.Create)
+			closings.GET("/compare", middleware.AuthMiddleware(cfg), closingController.Compare)
+			closings.GET("/:period", middleware.AuthMiddleware(cfg), closingController.Get)
+		}
+
+        // User management routes, admin only
+		users := api.Group("/users")
+		{
+			users.GET("", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermUsers), userController.List)
+			users.POST("", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermUsers), userController.Create)
+			users.GET("/:id", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermUsers), userController.Get)
+			users.PUT("/:id", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermUsers), userController.Update)
+			users.POST("/:id/disable", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermUsers), userController.Disable)
+			users.POST("/:id/enable", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermUsers), userController.Enable)
+			users.DELETE("/:id", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermUsers), userController.Delete)
+		}
+
+        // Admin routes
+		admin := api.Group("/admin")
+		{
+			admin.GET("/consistency", middleware.AuthMiddleware(cfg), consistencyController.Check)
+			admin.POST("/consistency/fix", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermStockManage), consistencyController.Fix)
+			admin.GET("/history/archives", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermBackups), historyArchiveController.List)
+			admin.POST("/history/archives", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermBackups), historyArchiveController.Archive)
+			admin.POST("/history/archives/:id/restore", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermBackups), historyArchiveController.Restore)
+			admin.GET("/history/audit", middleware.AuthMiddleware(cfg), middleware.RequirePermission(middleware.PermAudit), historyController.GetAuditTrail)
 		}
 	}
 }
\ No newline at end of file
@@ -0,0 +1,168 @@
+package routes
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/config"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/database"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/middleware"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// emptyDB is a database/sql driver standing in for Postgres: users 1 to 4 exist, with the roles
+// of testUsers, in account 1, and every other query finds nothing and changes nothing. Handlers
+// reached by a request therefore run against an empty account.
+type emptyDB struct{}
+
+type emptyConn struct{}
+
+type emptyStmt struct{ query string }
+
+type emptyRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+var testUsers = map[int64]string{1: models.RoleAdmin, 2: models.RoleManager, 3: models.RoleOperator, 4: models.RoleViewer}
+
+func (emptyDB) Open(string) (driver.Conn, error) { return emptyConn{}, nil }
+
+func (emptyConn) Prepare(query string) (driver.Stmt, error) { return emptyStmt{query: query}, nil }
+func (emptyConn) Close() error                              { return nil }
+func (emptyConn) Begin() (driver.Tx, error)                 { return emptyConn{}, nil }
+func (emptyConn) Commit() error                             { return nil }
+func (emptyConn) Rollback() error                           { return nil }
+
+func (emptyStmt) Close() error  { return nil }
+func (emptyStmt) NumInput() int { return -1 }
+
+func (emptyStmt) Exec(args []driver.Value) (driver.Result, error) { return driver.RowsAffected(0), nil }
+
+func (s emptyStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if strings.HasPrefix(s.query, "SELECT active, role, token_version") && len(args) == 1 {
+		if role, ok := testUsers[args[0].(int64)]; ok {
+			return &emptyRows{
+				columns: []string{"active", "role", "token_version", "account_id"},
+				values:  [][]driver.Value{{true, role, int64(0), int64(1)}},
+			}, nil
+		}
+	}
+	return &emptyRows{}, nil
+}
+
+func (r *emptyRows) Columns() []string { return r.columns }
+func (r *emptyRows) Close() error      { return nil }
+
+func (r *emptyRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func init() {
+	sql.Register("routes-test", emptyDB{})
+}
+
+// routePermissions lists routes with the roles allowed to use them; the other roles must get 403
+// before reaching the handler. okStatus is what allowed roles get on the empty account with an
+// empty JSON body, when the handler succeeds; routes that need a valid body or an existing entity
+// leave it 0, and allowed roles only have to get past the permission check.
+var routePermissions = []struct {
+	method, path string
+	roles        []string
+	okStatus     int
+}{
+	{http.MethodPost, "/api/products", []string{models.RoleAdmin, models.RoleManager}, http.StatusCreated},
+	{http.MethodPut, "/api/products/p1", []string{models.RoleAdmin, models.RoleManager}, 0},
+	{http.MethodDelete, "/api/products/p1", []string{models.RoleAdmin, models.RoleManager}, 0},
+	{http.MethodPut, "/api/products/p1/parameters", []string{models.RoleAdmin, models.RoleManager}, 0},
+	{http.MethodPost, "/api/import", []string{models.RoleAdmin, models.RoleManager}, 0},
+	{http.MethodPost, "/api/nfe/import", []string{models.RoleAdmin, models.RoleManager}, 0},
+	{http.MethodPut, "/api/suppliers/12345678000190", []string{models.RoleAdmin, models.RoleManager}, 0},
+	{http.MethodPost, "/api/purchase-orders/from-suggestions", []string{models.RoleAdmin, models.RoleManager}, 0},
+	{http.MethodPost, "/api/products/p1/lotes", []string{models.RoleAdmin, models.RoleManager, models.RoleOperator}, 0},
+	{http.MethodPut, "/api/lotes/l1", []string{models.RoleAdmin, models.RoleManager, models.RoleOperator}, 0},
+	{http.MethodDelete, "/api/lotes/l1", []string{models.RoleAdmin, models.RoleManager, models.RoleOperator}, 0},
+	{http.MethodPost, "/api/history", []string{models.RoleAdmin, models.RoleManager, models.RoleOperator}, 0},
+	{http.MethodPost, "/api/history/batch", []string{models.RoleAdmin, models.RoleManager, models.RoleOperator}, 0},
+	{http.MethodPost, "/api/history/product-context", []string{models.RoleAdmin, models.RoleManager, models.RoleOperator}, 0},
+	{http.MethodPost, "/api/closings", []string{models.RoleAdmin, models.RoleManager}, 0},
+	{http.MethodPost, "/api/reports/snapshots", []string{models.RoleAdmin, models.RoleManager}, http.StatusCreated},
+	{http.MethodPost, "/api/email-reports", []string{models.RoleAdmin, models.RoleManager}, 0},
+	{http.MethodPost, "/api/admin/consistency/fix", []string{models.RoleAdmin, models.RoleManager}, 0},
+	{http.MethodGet, "/api/account/export", []string{models.RoleAdmin}, http.StatusOK},
+	{http.MethodPost, "/api/account/import", []string{models.RoleAdmin}, 0},
+	{http.MethodGet, "/api/admin/history/archives", []string{models.RoleAdmin}, http.StatusOK},
+	{http.MethodPost, "/api/admin/history/archives/1/restore", []string{models.RoleAdmin}, 0},
+	{http.MethodGet, "/api/admin/history/audit", []string{models.RoleAdmin}, http.StatusOK},
+	{http.MethodGet, "/api/users", []string{models.RoleAdmin}, http.StatusOK},
+	{http.MethodPost, "/api/users", []string{models.RoleAdmin}, 0},
+	{http.MethodDelete, "/api/users/2", []string{models.RoleAdmin}, 0},
+	{http.MethodGet, "/api/products", []string{models.RoleAdmin, models.RoleManager, models.RoleOperator, models.RoleViewer}, http.StatusOK},
+	{http.MethodGet, "/api/history", []string{models.RoleAdmin, models.RoleManager, models.RoleOperator, models.RoleViewer}, http.StatusOK},
+}
+
+func TestRoutesEnforceRolePermissions(t *testing.T) {
+	db, err := sql.Open("routes-test", "")
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	defer func(previous *sql.DB) { database.DB = previous }(database.DB)
+	database.DB = db
+
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{
+		JWT:     config.JWTConfig{Secret: "test-secret", Expiration: time.Hour},
+		History: config.HistoryConfig{ArchiveDir: t.TempDir()},
+	}
+	router := gin.New()
+	router.Use(gin.Recovery())
+	SetupRoutes(router, cfg)
+
+	tokens := map[string]string{}
+	for id, role := range testUsers {
+		token, err := middleware.GenerateToken(int(id), role, role, 0, cfg)
+		if err != nil {
+			t.Fatalf("GenerateToken: %v", err)
+		}
+		tokens[role] = token
+	}
+
+	for _, route := range routePermissions {
+		for role, token := range tokens {
+			allowed := false
+			for _, r := range route.roles {
+				allowed = allowed || r == role
+			}
+
+			req := httptest.NewRequest(route.method, route.path, strings.NewReader("{}"))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			denied := w.Code == http.StatusForbidden && strings.Contains(w.Body.String(), `"permission"`)
+			switch {
+			case w.Code == http.StatusNotFound && strings.Contains(w.Body.String(), "404 page not found"):
+				t.Errorf("%s %s: route not registered", route.method, route.path)
+			case !allowed && !denied:
+				t.Errorf("%s %s as %s: status %d, want 403: %s", route.method, route.path, role, w.Code, w.Body.String())
+			case allowed && (denied || w.Code == http.StatusUnauthorized):
+				t.Errorf("%s %s as %s: status %d, want the handler to run: %s", route.method, route.path, role, w.Code, w.Body.String())
+			case allowed && route.okStatus != 0 && w.Code != route.okStatus:
+				t.Errorf("%s %s as %s: status %d, want %d: %s", route.method, route.path, role, w.Code, route.okStatus, w.Body.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+)
+
+const (
+	DefaultABCPeriodDays  = 90
+	DefaultABCClassALimit = 80.0
+	DefaultABCClassBLimit = 95.0
+)
+
+// GetABCReport classifies the products by the value of their consumption between from and to: the
+// consumed quantity valued at the product's unit cost. Ranked by value, the most valuable products
+// that make up the first classALimit percent of the total value are class A, those up to classBLimit
+// percent are class B and the rest, including products not consumed at all, are class C. A product
+// is in the class its cumulative share starts in, so one product holding most of the value is still A.
+// Products without a unit cost cannot be valued and are listed last, without a class.
+func (s *consumptionService) GetABCReport(from, to time.Time, classALimit, classBLimit float64, userID int) (*models.ABCReport, error) {
+	products, err := s.productRepo.GetAll(userID)
+	if err != nil {
+		return nil, err
+	}
+	events, err := s.loadConsumption(from, &to, userID)
+	if err != nil {
+		return nil, err
+	}
+	params, err := s.paramsRepo.List(userID)
+	if err != nil {
+		return nil, err
+	}
+	consumed := make(map[string]float64)
+	for _, e := range events {
+		consumed[e.productID] += e.quantity
+	}
+	unitCosts := make(map[string]*float64)
+	for _, p := range params {
+		unitCosts[p.ProductID] = p.UnitCost
+	}
+
+	report := &models.ABCReport{
+		GeneratedAt: time.Now(),
+		From:        from,
+		To:          to,
+		ClassALimit: classALimit,
+		ClassBLimit: classBLimit,
+		Products:    make([]models.ProductABC, 0, len(products)),
+	}
+	var valued, unvalued []models.ProductABC
+	for _, p := range products {
+		item := models.ProductABC{
+			ProductID:   p.ID,
+			ProductName: p.Name,
+			Unit:        p.Unit,
+			Consumed:    roundQuantity(consumed[p.ID]),
+			UnitCost:    unitCosts[p.ID],
+		}
+		if item.UnitCost == nil {
+			if item.Consumed > 0 {
+				report.UnvaluedProducts++
+			}
+			unvalued = append(unvalued, item)
+			continue
+		}
+		value := math.Round(*item.UnitCost*item.Consumed*100) / 100
+		item.ConsumptionValue = &value
+		report.TotalValue += value
+		valued = append(valued, item)
+	}
+	report.TotalValue = math.Round(report.TotalValue*100) / 100
+
+	sort.SliceStable(valued, func(i, j int) bool {
+		a, b := valued[i], valued[j]
+		if *a.ConsumptionValue != *b.ConsumptionValue {
+			return *a.ConsumptionValue > *b.ConsumptionValue
+		}
+		return a.ProductName < b.ProductName
+	})
+	summaries := map[string]*models.ABCClassSummary{
+		"A": {Class: "A"},
+		"B": {Class: "B"},
+		"C": {Class: "C"},
+	}
+	cumulative := 0.0
+	for i := range valued {
+		item := &valued[i]
+		before := cumulative
+		if report.TotalValue > 0 {
+			item.Share = *item.ConsumptionValue / report.TotalValue * 100
+			cumulative += item.Share
+		}
+		switch {
+		case *item.ConsumptionValue > 0 && before < classALimit:
+			item.Class = "A"
+		case *item.ConsumptionValue > 0 && before < classBLimit:
+			item.Class = "B"
+		default:
+			item.Class = "C"
+		}
+		item.Share = math.Round(item.Share*100) / 100
+		item.CumulativeShare = math.Round(cumulative*100) / 100
+
+		summary := summaries[item.Class]
+		summary.Products++
+		summary.Value += *item.ConsumptionValue
+		summary.Share += item.Share
+	}
+	for _, class := range []string{"A", "B", "C"} {
+		summary := summaries[class]
+		summary.Value = math.Round(summary.Value*100) / 100
+		summary.Share = math.Round(summary.Share*100) / 100
+		report.Classes = append(report.Classes, *summary)
+	}
+
+	sort.SliceStable(unvalued, func(i, j int) bool {
+		a, b := unvalued[i], unvalued[j]
+		if a.Consumed != b.Consumed {
+			return a.Consumed > b.Consumed
+		}
+		return a.ProductName < b.ProductName
+	})
+	report.Products = append(append(report.Products, valued...), unvalued...)
+	return report, nil
+}
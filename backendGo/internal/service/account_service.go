@@ -0,0 +1,614 @@
+package service
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/config"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	// AccountExportFormat identifies account export archives in their manifest.
+	AccountExportFormat = "gerenciador-estoque/account-export"
+	// AccountExportVersion is the archive layout written by Export; Import reads it and older ones.
+	AccountExportVersion = 1
+
+	AccountArchiveZIP  = "zip"
+	AccountArchiveJSON = "json"
+
+	// accountImportChunk is how many history entries are chained per insert on import.
+	accountImportChunk = 500
+	// maxAccountArchivePart is the largest uncompressed file accepted inside a ZIP archive.
+	maxAccountArchivePart = 512 << 20
+)
+
+var (
+	ErrInvalidAccountArchive = errors.New("invalid account archive")
+	ErrAccountNotEmpty       = errors.New("account is not empty")
+)
+
+// AccountService exports all of a user's data to one archive and restores such an archive into an empty account.
+type AccountService interface {
+	Export(format string, actor models.Actor, w io.Writer) error
+	Import(data []byte, actor models.Actor) (*models.AccountImportResult, error)
+}
+
+type accountService struct {
+	productRepo  repository.ProductRepository
+	loteRepo     repository.LoteRepository
+	historyRepo  repository.HistoryRepository
+	archiveRepo  repository.HistoryArchiveRepository
+	nfeRepo      repository.NFeRepository
+	paramsRepo   repository.ProductParametersRepository
+	supplierRepo repository.SupplierRepository
+	orderRepo    repository.PurchaseOrderRepository
+	db           *sql.DB // For transactions
+	cfg          config.HistoryConfig
+}
+
+// NewAccountService creates a new AccountService
+func NewAccountService(productRepo repository.ProductRepository, loteRepo repository.LoteRepository, historyRepo repository.HistoryRepository, archiveRepo repository.HistoryArchiveRepository, nfeRepo repository.NFeRepository, paramsRepo repository.ProductParametersRepository, supplierRepo repository.SupplierRepository, orderRepo repository.PurchaseOrderRepository, db *sql.DB, cfg config.HistoryConfig) AccountService {
+	return &accountService{
+		productRepo:  productRepo,
+		loteRepo:     loteRepo,
+		historyRepo:  historyRepo,
+		archiveRepo:  archiveRepo,
+		nfeRepo:      nfeRepo,
+		paramsRepo:   paramsRepo,
+		supplierRepo: supplierRepo,
+		orderRepo:    orderRepo,
+		db:           db,
+		cfg:          cfg,
+	}
+}
+
+// accountArchive is an account export as read back by Import. In the JSON layout it is the whole
+// document; in the ZIP layout each field is a file, the history as JSON lines in history.jsonl.
+type accountArchive struct {
+	Manifest *models.AccountExportManifest `json:"manifest"`
+	Products []models.Product              `json:"products"`
+	Settings models.AccountSettings        `json:"settings"`
+	History  []models.HistoryAuditEntry    `json:"history"`
+
+	historyJSONL []byte // ZIP layout only
+}
+
+// Export writes the user's products with their lotes, the product parameters, the suppliers, supplier mappings,
+// received invoices and purchase orders, and the whole history (archived months first, then the live table) to w. The manifest,
+// which carries the record counts, is written last: as the last file of the ZIP or the last key of the JSON document.
+func (s *accountService) Export(format string, actor models.Actor, w io.Writer) error {
+	products, err := s.productRepo.GetAll(actor.UserID)
+	if err != nil {
+		return err
+	}
+	params, err := s.paramsRepo.List(actor.UserID)
+	if err != nil {
+		return err
+	}
+	suppliers, err := s.supplierRepo.List(actor.UserID)
+	if err != nil {
+		return err
+	}
+	mappings, err := s.nfeRepo.ListMappings(actor.UserID)
+	if err != nil {
+		return err
+	}
+	imports, err := s.nfeRepo.ListImports(actor.UserID)
+	if err != nil {
+		return err
+	}
+	orders, err := s.orderRepo.List(actor.UserID)
+	if err != nil {
+		return err
+	}
+
+	if products == nil {
+		products = []models.Product{}
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].Name < products[j].Name })
+	if mappings == nil {
+		mappings = []models.SupplierProductMapping{}
+	}
+	settings := models.AccountSettings{
+		ProductParameters: params,
+		Suppliers:         suppliers,
+		SupplierMappings:  mappings,
+		NFeImports:        imports,
+		PurchaseOrders:    orders,
+	}
+
+	manifest := &models.AccountExportManifest{
+		Format:     AccountExportFormat,
+		Version:    AccountExportVersion,
+		ExportedAt: time.Now().UTC(),
+		Username:   actor.Username,
+		Counts: models.AccountDataCounts{
+			Products:          len(products),
+			ProductParameters: len(params),
+			Suppliers:         len(suppliers),
+			SupplierMappings:  len(mappings),
+			NFeImports:        len(imports),
+			PurchaseOrders:    len(orders),
+		},
+	}
+	for i := range products {
+		if products[i].Lotes == nil {
+			products[i].Lotes = []models.Lote{}
+		}
+		manifest.Counts.Lotes += len(products[i].Lotes)
+	}
+
+	if format == AccountArchiveJSON {
+		return s.exportJSON(w, actor.UserID, manifest, products, settings)
+	}
+	return s.exportZIP(w, actor.UserID, manifest, products, settings)
+}
+
+func (s *accountService) exportZIP(w io.Writer, userID int, manifest *models.AccountExportManifest, products []models.Product, settings models.AccountSettings) error {
+	zw := zip.NewWriter(w)
+	if err := writeZipJSON(zw, "products.json", products); err != nil {
+		return err
+	}
+	if err := writeZipJSON(zw, "settings.json", settings); err != nil {
+		return err
+	}
+
+	f, err := zw.Create("history.jsonl")
+	if err != nil {
+		return fmt.Errorf("failed to write history.jsonl: %w", err)
+	}
+	enc := json.NewEncoder(f)
+	err = s.walkAccountHistory(userID, func(entry models.History) error {
+		manifest.Counts.History++
+		return enc.Encode(models.NewHistoryAuditEntry(entry))
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := writeZipJSON(zw, "manifest.json", manifest); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to finish account archive: %w", err)
+	}
+	return nil
+}
+
+func (s *accountService) exportJSON(w io.Writer, userID int, manifest *models.AccountExportManifest, products []models.Product, settings models.AccountSettings) error {
+	bw := bufio.NewWriter(w)
+	writeKey := func(key string, v interface{}) error {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", key, err)
+		}
+		_, err = fmt.Fprintf(bw, "%q:%s", key, b)
+		return err
+	}
+
+	bw.WriteString("{")
+	if err := writeKey("products", products); err != nil {
+		return err
+	}
+	bw.WriteString(",")
+	if err := writeKey("settings", settings); err != nil {
+		return err
+	}
+	bw.WriteString(`,"history":[`)
+	err := s.walkAccountHistory(userID, func(entry models.History) error {
+		b, err := json.Marshal(models.NewHistoryAuditEntry(entry))
+		if err != nil {
+			return fmt.Errorf("failed to encode history entry %s: %w", entry.ID, err)
+		}
+		if manifest.Counts.History > 0 {
+			bw.WriteString(",")
+		}
+		manifest.Counts.History++
+		_, err = bw.Write(b)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	bw.WriteString("],")
+	if err := writeKey("manifest", manifest); err != nil {
+		return err
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+// walkAccountHistory calls fn with every history entry of the user, oldest first: the entries of
+// the archive files not restored yet, then the live table.
+func (s *accountService) walkAccountHistory(userID int, fn func(entry models.History) error) error {
+	archives, err := s.archiveRepo.List(userID)
+	if err != nil {
+		return err
+	}
+	for i := len(archives) - 1; i >= 0; i-- { // List is newest first
+		archive := archives[i]
+		if archive.RestoredAt != nil {
+			continue // Its entries are back in the live table
+		}
+		entries, err := readArchiveFile(filepath.Join(s.cfg.ArchiveDir, archive.FileName), archive.SHA256)
+		if err != nil {
+			return fmt.Errorf("failed to read history archive %s: %w", archive.FileName, err)
+		}
+		for _, entry := range entries {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+	}
+	return s.historyRepo.WalkHistory(models.HistoryFilter{}, userID, fn)
+}
+
+func writeZipJSON(zw *zip.Writer, name string, v interface{}) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := json.NewEncoder(f).Encode(v); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+	return nil
+}
+
+// Import restores an account export into the caller's account, which must have no products and no
+// history. Every product, lote, history entry and batch gets a new ID; references to the old IDs in
+// the history changes, the supplier mappings and the received invoices are rewritten. History keeps
+// its dates and actor details (but not the actor's user ID, which belongs to the source server) and
+// is chained anew, in archive order. Everything is restored in one transaction.
+func (s *accountService) Import(data []byte, actor models.Actor) (*models.AccountImportResult, error) {
+	archive, err := readAccountArchive(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateAccountArchive(archive); err != nil {
+		return nil, err
+	}
+
+	userID := actor.UserID
+	products, err := s.productRepo.GetAll(userID)
+	if err != nil {
+		return nil, err
+	}
+	earliest, err := s.archiveRepo.GetEarliestEntryDate(userID)
+	if err != nil {
+		return nil, err
+	}
+	archives, err := s.archiveRepo.List(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(products) > 0 || !earliest.IsZero() || len(archives) > 0 {
+		return nil, fmt.Errorf("%w: it already has products or history", ErrAccountNotEmpty)
+	}
+
+	result := &models.AccountImportResult{
+		SourceUsername: archive.Manifest.Username,
+		ExportedAt:     archive.Manifest.ExportedAt,
+	}
+	ids := map[string]string{}     // Old product, lote and entity IDs to new ones
+	batches := map[string]string{} // Old batch IDs to new ones
+	remap := func(m map[string]string, old string) string {
+		if old == "" {
+			return ""
+		}
+		if id, ok := m[old]; ok {
+			return id
+		}
+		m[old] = uuid.NewString()
+		return m[old]
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Rollback if not committed
+	// Imported history keeps its dates, which may lie inside closed months
+	if err := s.historyRepo.AllowBackdatedTx(tx); err != nil {
+		return nil, err
+	}
+
+	restored := map[string]bool{} // Old IDs of the restored products
+	for _, p := range archive.Products {
+		product := models.Product{ID: remap(ids, p.ID), Name: p.Name, Unit: p.Unit, Quantity: p.Quantity, UserID: userID}
+		if err := s.productRepo.CreateTx(tx, &product); err != nil {
+			return nil, fmt.Errorf("failed to restore product %q: %w", p.Name, err)
+		}
+		restored[p.ID] = true
+		result.Counts.Products++
+
+		for _, l := range p.Lotes {
+			lote := models.Lote{
+				ID:           remap(ids, l.ID),
+				ProductID:    product.ID,
+				UserID:       userID,
+				Quantity:     l.Quantity,
+				DataValidade: dateOnly(l.DataValidade),
+				CreatedAt:    l.CreatedAt,
+				UpdatedAt:    l.UpdatedAt,
+			}
+			if lote.CreatedAt.IsZero() {
+				lote.CreatedAt = time.Now()
+			}
+			if lote.UpdatedAt.IsZero() {
+				lote.UpdatedAt = lote.CreatedAt
+			}
+			if err := s.loteRepo.CreateWithTimestampsTx(tx, &lote); err != nil {
+				return nil, fmt.Errorf("failed to restore lote of product %q: %w", p.Name, err)
+			}
+			result.Counts.Lotes++
+		}
+	}
+
+	pending := make([]models.History, 0, accountImportChunk)
+	flush := func() error {
+		if err := s.historyRepo.CreateBatchTx(tx, pending); err != nil {
+			return err
+		}
+		result.Counts.History += len(pending)
+		pending = pending[:0]
+		return nil
+	}
+	err = archive.walkHistory(func(n int, entry models.History) error {
+		if entry.EntityType == "" || entry.Date.IsZero() || !json.Valid(entry.Changes) {
+			return fmt.Errorf("%w: history entry %d is incomplete", ErrInvalidAccountArchive, n)
+		}
+		changes, err := remapChangeIDs(entry.Changes, func(old string) string { return remap(ids, old) })
+		if err != nil {
+			return fmt.Errorf("%w: history entry %d: %v", ErrInvalidAccountArchive, n, err)
+		}
+		newEntry := models.History{
+			ID:            uuid.NewString(),
+			Date:          entry.Date,
+			EntityType:    entry.EntityType,
+			EntityID:      remap(ids, entry.EntityID),
+			UserID:        userID,
+			Changes:       changes,
+			BatchID:       remap(batches, entry.BatchID),
+			ActorUsername: entry.ActorUsername,
+			ClientIP:      entry.ClientIP,
+			UserAgent:     entry.UserAgent,
+			RequestID:     entry.RequestID,
+		}
+		if newEntry.BatchID == "" {
+			newEntry.BatchID = newEntry.ID
+		}
+		if pending = append(pending, newEntry); len(pending) == accountImportChunk {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	for _, p := range archive.Settings.ProductParameters {
+		if !restored[p.ProductID] {
+			continue // The product is not in the archive
+		}
+		p.ProductID = ids[p.ProductID]
+		if err := s.paramsRepo.SaveTx(tx, &p, userID); err != nil {
+			return nil, err
+		}
+		result.Counts.ProductParameters++
+	}
+	for _, sup := range archive.Settings.Suppliers {
+		if err := s.supplierRepo.SaveTx(tx, &sup, userID); err != nil {
+			return nil, err
+		}
+		result.Counts.Suppliers++
+	}
+	for _, m := range archive.Settings.SupplierMappings {
+		if !restored[m.ProductID] {
+			continue // The product is not in the archive
+		}
+		m.ProductID = ids[m.ProductID]
+		if err := s.nfeRepo.SaveMappingTx(tx, m, userID); err != nil {
+			return nil, err
+		}
+		result.Counts.SupplierMappings++
+	}
+	for _, imp := range archive.Settings.NFeImports {
+		imp.UserID = userID
+		imp.BatchID = remap(batches, imp.BatchID)
+		if err := s.nfeRepo.CreateImportTx(tx, &imp); err != nil {
+			return nil, err
+		}
+		result.Counts.NFeImports++
+	}
+	for _, order := range archive.Settings.PurchaseOrders {
+		order.UserID = userID
+		for i := range order.Items {
+			if id := order.Items[i].ProductID; id != nil {
+				if restored[*id] {
+					newID := ids[*id]
+					order.Items[i].ProductID = &newID
+				} else {
+					order.Items[i].ProductID = nil // The product was removed before the export
+				}
+			}
+		}
+		if err := s.orderRepo.CreateTx(tx, &order); err != nil {
+			return nil, err
+		}
+		result.Counts.PurchaseOrders++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return result, nil
+}
+
+// readAccountArchive reads an account export in the ZIP or the JSON layout.
+func readAccountArchive(data []byte) (*accountArchive, error) {
+	archive := &accountArchive{}
+	if !bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		if err := json.Unmarshal(data, archive); err != nil {
+			return nil, fmt.Errorf("%w: failed to read JSON: %v", ErrInvalidAccountArchive, err)
+		}
+		return archive, nil
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read ZIP: %v", ErrInvalidAccountArchive, err)
+	}
+	parts := map[string][]byte{}
+	for _, f := range zr.File {
+		if f.UncompressedSize64 > maxAccountArchivePart {
+			return nil, fmt.Errorf("%w: %s is too large", ErrInvalidAccountArchive, f.Name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to open %s: %v", ErrInvalidAccountArchive, f.Name, err)
+		}
+		b, err := io.ReadAll(io.LimitReader(rc, maxAccountArchivePart))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to read %s: %v", ErrInvalidAccountArchive, f.Name, err)
+		}
+		parts[f.Name] = b
+	}
+
+	for name, target := range map[string]interface{}{
+		"manifest.json": &archive.Manifest,
+		"products.json": &archive.Products,
+		"settings.json": &archive.Settings,
+	} {
+		b, ok := parts[name]
+		if !ok {
+			if name == "settings.json" {
+				continue
+			}
+			return nil, fmt.Errorf("%w: %s not found", ErrInvalidAccountArchive, name)
+		}
+		if err := json.Unmarshal(b, target); err != nil {
+			return nil, fmt.Errorf("%w: failed to read %s: %v", ErrInvalidAccountArchive, name, err)
+		}
+	}
+	archive.historyJSONL = parts["history.jsonl"]
+	return archive, nil
+}
+
+// validateAccountArchive checks the manifest and the products of an archive before anything is restored.
+func validateAccountArchive(archive *accountArchive) error {
+	manifest := archive.Manifest
+	if manifest == nil || manifest.Format != AccountExportFormat {
+		return fmt.Errorf("%w: not an account export", ErrInvalidAccountArchive)
+	}
+	if manifest.Version < 1 || manifest.Version > AccountExportVersion {
+		return fmt.Errorf("%w: unsupported version %d (this server reads up to %d)", ErrInvalidAccountArchive, manifest.Version, AccountExportVersion)
+	}
+
+	seen := map[string]bool{}
+	for i, p := range archive.Products {
+		name := strings.TrimSpace(p.Name)
+		switch {
+		case p.ID == "" || name == "":
+			return fmt.Errorf("%w: product %d has no ID or name", ErrInvalidAccountArchive, i+1)
+		case utf8.RuneCountInString(name) > 100:
+			return fmt.Errorf("%w: product %q has a name longer than 100 characters", ErrInvalidAccountArchive, name)
+		case p.Unit != "L" && p.Unit != "kg":
+			return fmt.Errorf("%w: product %q has an invalid unit %q", ErrInvalidAccountArchive, name, p.Unit)
+		case seen[p.ID]:
+			return fmt.Errorf("%w: product ID %s appears twice", ErrInvalidAccountArchive, p.ID)
+		}
+		seen[p.ID] = true
+		for _, l := range p.Lotes {
+			if l.ID == "" || seen[l.ID] {
+				return fmt.Errorf("%w: product %q has a lote without ID or with a repeated ID", ErrInvalidAccountArchive, name)
+			}
+			seen[l.ID] = true
+			if l.Quantity <= 0 {
+				return fmt.Errorf("%w: lote %s has a quantity that is not positive", ErrInvalidAccountArchive, l.ID)
+			}
+			if _, err := time.Parse("2006-01-02", dateOnly(l.DataValidade)); err != nil {
+				return fmt.Errorf("%w: lote %s has an invalid data_validade %q", ErrInvalidAccountArchive, l.ID, l.DataValidade)
+			}
+		}
+	}
+	return nil
+}
+
+// walkHistory calls fn with each history entry of the archive, in archive order, numbered from 1.
+func (a *accountArchive) walkHistory(fn func(n int, entry models.History) error) error {
+	if a.historyJSONL == nil {
+		for i, entry := range a.History {
+			if err := fn(i+1, entry.Entry()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(a.historyJSONL))
+	for n := 1; ; n++ {
+		var entry models.HistoryAuditEntry
+		if err := dec.Decode(&entry); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%w: failed to read history entry %d: %v", ErrInvalidAccountArchive, n, err)
+		}
+		if err := fn(n, entry.Entry()); err != nil {
+			return err
+		}
+	}
+}
+
+// remapChangeIDs rewrites every "productId" and "loteId" string in a history changes document,
+// at any depth (e.g. changedFields[].loteId), through remap. Numbers are kept as written.
+func remapChangeIDs(changes json.RawMessage, remap func(old string) string) (json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(changes))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for key, value := range v {
+				if id, ok := value.(string); ok && (key == "productId" || key == "loteId") {
+					v[key] = remap(id)
+					continue
+				}
+				walk(value)
+			}
+		case []interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	walk(doc)
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(b), nil
+}
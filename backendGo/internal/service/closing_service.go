@@ -0,0 +1,292 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/repository"
+)
+
+var (
+	ErrInvalidClosingPeriod = errors.New("invalid closing period")
+	ErrClosingExists        = errors.New("period is already closed")
+	ErrClosingNotFound      = errors.New("stock closing not found")
+)
+
+// ClosingService closes months: it freezes the quantity and value of every product and lote at the
+// end of the month, after which the closing cannot change.
+type ClosingService interface {
+	Close(period string, actor models.Actor) (*models.StockClosing, error)
+	ClosePreviousMonthForAllUsers() ([]models.StockClosing, error)
+	List(userID int) ([]models.StockClosing, error)
+	Get(period string, userID int) (*models.StockClosing, error)
+	Compare(from, to string, userID int) (*models.StockClosingComparison, error)
+}
+
+type closingService struct {
+	repo        repository.StockClosingRepository
+	productRepo repository.ProductRepository
+	paramsRepo  repository.ProductParametersRepository
+	stockSvc    StockReportService
+}
+
+// NewClosingService creates a new ClosingService
+func NewClosingService(repo repository.StockClosingRepository, productRepo repository.ProductRepository, paramsRepo repository.ProductParametersRepository, stockSvc StockReportService) ClosingService {
+	return &closingService{
+		repo:        repo,
+		productRepo: productRepo,
+		paramsRepo:  paramsRepo,
+		stockSvc:    stockSvc,
+	}
+}
+
+// parseClosingPeriod parses a YYYY-MM period and returns its first instant and the first instant
+// of the next month, in the server's time zone.
+func parseClosingPeriod(period string) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation("2006-01", period, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: expected YYYY-MM, got %q", ErrInvalidClosingPeriod, period)
+	}
+	return start, start.AddDate(0, 1, 0), nil
+}
+
+// Close closes a month that is over for the actor, recording the stock reconstructed at its end.
+// From then on, no change dated inside the month is accepted.
+func (s *closingService) Close(period string, actor models.Actor) (*models.StockClosing, error) {
+	return s.close(period, actor.UserID, "manual", actor.Username, time.Now())
+}
+
+// ClosePreviousMonthForAllUsers closes the month that just ended for every user owning products
+// who has not closed it yet; used by the periodic job.
+func (s *closingService) ClosePreviousMonthForAllUsers() ([]models.StockClosing, error) {
+	now := time.Now()
+	period := monthStart(now).AddDate(0, -1, 0).Format("2006-01")
+	userIDs, err := s.productRepo.GetOwnerIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	var closings []models.StockClosing
+	for _, userID := range userIDs {
+		closing, err := s.close(period, userID, "scheduled", "", now)
+		if errors.Is(err, ErrClosingExists) {
+			continue
+		}
+		if err != nil {
+			log.Printf("WARN: stock closing of %s failed for user %d: %v", period, userID, err)
+			continue
+		}
+		closing.Products = nil
+		closings = append(closings, *closing)
+	}
+	return closings, nil
+}
+
+func (s *closingService) close(period string, userID int, triggeredBy, closedBy string, now time.Time) (*models.StockClosing, error) {
+	_, end, err := parseClosingPeriod(period)
+	if err != nil {
+		return nil, err
+	}
+	if end.After(now) {
+		return nil, fmt.Errorf("%w: %s is not over", ErrInvalidClosingPeriod, period)
+	}
+	exists, err := s.repo.Exists(period, userID)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrClosingExists
+	}
+
+	closing := &models.StockClosing{
+		Period:      period,
+		PeriodEnd:   end,
+		StockAt:     end,
+		TriggeredBy: triggeredBy,
+		ClosedBy:    closedBy,
+	}
+	report, err := s.stockSvc.GetStockAt(end, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := s.paramsRepo.List(userID)
+	if err != nil {
+		return nil, err
+	}
+	unitCosts := make(map[string]float64)
+	for _, p := range params {
+		if p.UnitCost != nil {
+			unitCosts[p.ProductID] = *p.UnitCost
+		}
+	}
+
+	closing.Products = make([]models.StockClosingProduct, 0, len(report.Products))
+	for _, position := range report.Products {
+		product := models.StockClosingProduct{
+			ProductID:   position.ProductID,
+			ProductName: position.ProductName,
+			Unit:        position.Unit,
+			Quantity:    position.Quantity,
+			Lotes:       make([]models.StockClosingLote, 0, len(position.Lotes)),
+		}
+		cost, hasCost := unitCosts[position.ProductID]
+		if hasCost {
+			product.UnitCost = &cost
+			product.Value = closingValue(cost, position.Quantity)
+			if closing.TotalValue == nil {
+				closing.TotalValue = new(float64)
+			}
+			*closing.TotalValue += *product.Value
+		}
+		for _, l := range position.Lotes {
+			lote := models.StockClosingLote{LoteID: l.LoteID, DataValidade: l.DataValidade, Quantity: l.Quantity}
+			if hasCost {
+				lote.Value = closingValue(cost, l.Quantity)
+			}
+			product.Lotes = append(product.Lotes, lote)
+		}
+		closing.Products = append(closing.Products, product)
+		closing.ProductCount++
+		closing.LoteCount += len(position.Lotes)
+	}
+	if closing.TotalValue != nil {
+		*closing.TotalValue = math.Round(*closing.TotalValue*100) / 100
+	}
+
+	if err := s.repo.Create(closing, userID); err != nil {
+		return nil, err
+	}
+	return closing, nil
+}
+
+// closingValue values a quantity at a unit cost, rounded to cents.
+func closingValue(unitCost, quantity float64) *float64 {
+	value := math.Round(unitCost*quantity*100) / 100
+	return &value
+}
+
+// List retrieves the user's closings without their products, newest period first.
+func (s *closingService) List(userID int) ([]models.StockClosing, error) {
+	return s.repo.List(userID)
+}
+
+// Get retrieves a closing with its products and lotes, or nil if the user did not close the period.
+func (s *closingService) Get(period string, userID int) (*models.StockClosing, error) {
+	if _, _, err := parseClosingPeriod(period); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByPeriod(period, userID)
+}
+
+// Compare compares two closings product by product, by name. Without periods it compares the two
+// latest closings; with only "to", that closing and the one before it.
+func (s *closingService) Compare(from, to string, userID int) (*models.StockClosingComparison, error) {
+	if from == "" || to == "" {
+		closings, err := s.repo.List(userID)
+		if err != nil {
+			return nil, err
+		}
+		for i, closing := range closings {
+			if to == "" || closing.Period == to {
+				if i+1 >= len(closings) {
+					break
+				}
+				to = closing.Period
+				if from == "" {
+					from = closings[i+1].Period
+				}
+				break
+			}
+		}
+		if from == "" || to == "" {
+			if to != "" {
+				return nil, fmt.Errorf("%w: no closing before %s", ErrClosingNotFound, to)
+			}
+			return nil, fmt.Errorf("%w: at least two closings are needed to compare", ErrClosingNotFound)
+		}
+	}
+
+	before, err := s.Get(from, userID)
+	if err != nil {
+		return nil, err
+	}
+	after, err := s.Get(to, userID)
+	if err != nil {
+		return nil, err
+	}
+	if before == nil || after == nil {
+		missing := from
+		if before != nil {
+			missing = to
+		}
+		return nil, fmt.Errorf("%w: %s", ErrClosingNotFound, missing)
+	}
+
+	type pair struct {
+		from, to *models.StockClosingProduct
+	}
+	pairs := make(map[string]*pair)
+	var order []string
+	for i := range before.Products {
+		id := before.Products[i].ProductID
+		pairs[id] = &pair{from: &before.Products[i]}
+		order = append(order, id)
+	}
+	for i := range after.Products {
+		id := after.Products[i].ProductID
+		if p, ok := pairs[id]; ok {
+			p.to = &after.Products[i]
+			continue
+		}
+		pairs[id] = &pair{to: &after.Products[i]}
+		order = append(order, id)
+	}
+
+	comparison := &models.StockClosingComparison{Products: make([]models.StockClosingProductDiff, 0, len(order))}
+	for _, id := range order {
+		p := pairs[id]
+		diff := models.StockClosingProductDiff{ProductID: id}
+		// The name and unit of the later closing win
+		for _, product := range []*models.StockClosingProduct{p.from, p.to} {
+			if product != nil {
+				diff.ProductName = product.ProductName
+				diff.Unit = product.Unit
+			}
+		}
+		if p.from != nil {
+			diff.QuantityFrom = p.from.Quantity
+			diff.ValueFrom = p.from.Value
+		}
+		if p.to != nil {
+			diff.QuantityTo = p.to.Quantity
+			diff.ValueTo = p.to.Value
+		}
+		diff.QuantityChange = roundQuantity(diff.QuantityTo - diff.QuantityFrom)
+		diff.ValueChange = valueChange(diff.ValueFrom, diff.ValueTo)
+		comparison.Products = append(comparison.Products, diff)
+	}
+	sort.SliceStable(comparison.Products, func(i, j int) bool {
+		return comparison.Products[i].ProductName < comparison.Products[j].ProductName
+	})
+
+	comparison.TotalValueChange = valueChange(before.TotalValue, after.TotalValue)
+	before.Products, after.Products = nil, nil
+	comparison.From, comparison.To = *before, *after
+	return comparison, nil
+}
+
+// valueChange is the difference between two values, counting a missing one as zero; nil if both
+// are missing.
+func valueChange(from, to *float64) *float64 {
+	if from == nil && to == nil {
+		return nil
+	}
+	change := math.Round((valueOrZero(to)-valueOrZero(from))*100) / 100
+	return &change
+}
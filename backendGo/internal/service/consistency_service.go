@@ -0,0 +1,236 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/repository"
+	"github.com/google/uuid"
+)
+
+// ProductActionQuantityAdjusted marks product history entries written by the
+// consistency checker to bring the replayed history back in line with the stock.
+const ProductActionQuantityAdjusted = "quantity_adjusted"
+
+const (
+	IssueLotesMismatch         = "lotes_mismatch"          // products.quantity differs from the sum of the product's lotes
+	IssueHistoryMismatch       = "history_mismatch"        // replaying the history gives a different quantity
+	IssueMissingHistory        = "missing_history"         // the history does not know the product at all
+	IssueDeletedWithoutHistory = "deleted_without_history" // the history still has a product that no longer exists
+)
+
+// ConsistencyService compares products.quantity with the lotes and the history of each product
+type ConsistencyService interface {
+	Check(userID int, fix bool) (*models.ConsistencyReport, error)
+	CheckAllUsers() (map[int]*models.ConsistencyReport, error)
+}
+
+type consistencyService struct {
+	productRepo  repository.ProductRepository
+	historyRepo  repository.HistoryRepository
+	archiveRepo  repository.HistoryArchiveRepository
+	snapshotRepo repository.StockSnapshotRepository
+	db           *sql.DB // For transactions
+}
+
+// NewConsistencyService creates a new ConsistencyService
+func NewConsistencyService(productRepo repository.ProductRepository, historyRepo repository.HistoryRepository, archiveRepo repository.HistoryArchiveRepository, snapshotRepo repository.StockSnapshotRepository, db *sql.DB) ConsistencyService {
+	return &consistencyService{
+		productRepo:  productRepo,
+		historyRepo:  historyRepo,
+		archiveRepo:  archiveRepo,
+		snapshotRepo: snapshotRepo,
+		db:           db,
+	}
+}
+
+// Check reports every product of the user whose quantity is inconsistent. When fix is true,
+// products with lotes get their quantity recomputed from the lotes, and a single history
+// batch of "quantity_adjusted" entries is recorded so that replaying the history matches the stock.
+func (s *consistencyService) Check(userID int, fix bool) (*models.ConsistencyReport, error) {
+	totals, err := s.productRepo.GetQuantityTotals(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Archived history is replaced by the snapshot taken at the archive cutoff
+	replayed, base, err := replayBase(s.archiveRepo, s.snapshotRepo, userID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.historyRepo.GetHistoryInRange(base, time.Time{}, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load history: %w", err)
+	}
+	for _, entry := range entries {
+		replayed.apply(entry)
+	}
+
+	report := &models.ConsistencyReport{
+		CheckedAt:       time.Now(),
+		ProductsChecked: len(totals),
+		Discrepancies:   []models.ConsistencyDiscrepancy{},
+	}
+
+	existing := make(map[string]bool, len(totals))
+	for _, t := range totals {
+		existing[t.ProductID] = true
+		stored := t.StoredQuantity
+		d := models.ConsistencyDiscrepancy{
+			ProductID:      t.ProductID,
+			ProductName:    t.ProductName,
+			StoredQuantity: &stored,
+			LotesQuantity:  t.LotesQuantity,
+			LoteCount:      t.LoteCount,
+		}
+
+		if t.LoteCount > 0 && !isZeroQuantity(t.StoredQuantity-t.LotesQuantity) {
+			d.Issues = append(d.Issues, IssueLotesMismatch)
+		}
+		if product, ok := replayed.products[t.ProductID]; ok {
+			historyQty := product.quantity
+			d.HistoryQuantity = &historyQty
+			if !isZeroQuantity(expectedQuantity(t) - historyQty) {
+				d.Issues = append(d.Issues, IssueHistoryMismatch)
+			}
+		} else {
+			d.Issues = append(d.Issues, IssueMissingHistory)
+		}
+
+		if len(d.Issues) > 0 {
+			report.Discrepancies = append(report.Discrepancies, d)
+		}
+	}
+
+	for id, product := range replayed.products {
+		if existing[id] {
+			continue
+		}
+		historyQty := product.quantity
+		report.Discrepancies = append(report.Discrepancies, models.ConsistencyDiscrepancy{
+			ProductID:       id,
+			ProductName:     product.name,
+			HistoryQuantity: &historyQty,
+			Issues:          []string{IssueDeletedWithoutHistory},
+		})
+	}
+	sort.Slice(report.Discrepancies, func(i, j int) bool {
+		return report.Discrepancies[i].ProductName < report.Discrepancies[j].ProductName
+	})
+
+	if fix && len(report.Discrepancies) > 0 {
+		batchID, err := s.fix(report.Discrepancies, userID)
+		if err != nil {
+			return nil, err
+		}
+		report.Fixed = true
+		report.AdjustmentBatchID = batchID
+	}
+
+	return report, nil
+}
+
+// CheckAllUsers runs a read-only check for every user owning products; used by the periodic job.
+func (s *consistencyService) CheckAllUsers() (map[int]*models.ConsistencyReport, error) {
+	userIDs, err := s.productRepo.GetOwnerIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make(map[int]*models.ConsistencyReport, len(userIDs))
+	for _, userID := range userIDs {
+		report, err := s.Check(userID, false)
+		if err != nil {
+			log.Printf("WARN: consistency check failed for user %d: %v", userID, err)
+			continue
+		}
+		reports[userID] = report
+	}
+	return reports, nil
+}
+
+// expectedQuantity is the quantity a product should have: the sum of its lotes if it has any,
+// its stored quantity otherwise.
+func expectedQuantity(t models.ProductQuantityTotals) float64 {
+	if t.LoteCount > 0 {
+		return t.LotesQuantity
+	}
+	return t.StoredQuantity
+}
+
+// fix applies the corrections and records them as one history batch, in a single transaction.
+func (s *consistencyService) fix(discrepancies []models.ConsistencyDiscrepancy, userID int) (string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Rollback if not committed
+
+	batchID := uuid.NewString()
+	now := time.Now()
+	var entries []models.History
+
+	for _, d := range discrepancies {
+		var change models.ProductChange
+		if d.StoredQuantity == nil {
+			// The product is gone: close its history with the deletion that was never recorded.
+			change = models.ProductChange{
+				ProductID:        d.ProductID,
+				ProductName:      d.ProductName,
+				Action:           "deleted",
+				QuantityBefore:   d.HistoryQuantity,
+				IsProductRemoval: true,
+			}
+		} else {
+			target := *d.StoredQuantity
+			if d.LoteCount > 0 {
+				if err := s.productRepo.SyncQuantityWithLotes(tx, d.ProductID, userID); err != nil {
+					return "", err
+				}
+				target = d.LotesQuantity
+			}
+			change = models.ProductChange{
+				ProductID:      d.ProductID,
+				ProductName:    d.ProductName,
+				Action:         ProductActionQuantityAdjusted,
+				QuantityBefore: d.HistoryQuantity,
+				QuantityAfter:  &target,
+			}
+			if d.HistoryQuantity != nil {
+				delta := target - *d.HistoryQuantity
+				change.QuantityChanged = &delta
+			}
+			if !isZeroQuantity(*d.StoredQuantity - target) {
+				change.ChangedFields = []models.ChangedField{{Field: "quantity", OldValue: *d.StoredQuantity, NewValue: target}}
+			}
+		}
+
+		changes, err := json.Marshal(change)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal adjustment for product %s: %w", d.ProductID, err)
+		}
+		entries = append(entries, models.History{
+			ID:         uuid.NewString(),
+			Date:       now,
+			EntityType: EntityTypeProduct,
+			EntityID:   d.ProductID,
+			UserID:     userID,
+			Changes:    changes,
+			BatchID:    batchID,
+		})
+	}
+
+	if err := s.historyRepo.CreateBatchTx(tx, entries); err != nil {
+		return "", fmt.Errorf("failed to record adjustment batch: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return batchID, nil
+}
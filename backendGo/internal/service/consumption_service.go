@@ -0,0 +1,252 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/repository"
+)
+
+const (
+	DefaultForecastWindowDays = 30
+	MaxConsumptionWindowDays  = 3650
+
+	// consumptionSeasonalityMonths is how many calendar months, the current one included, seasonality covers.
+	consumptionSeasonalityMonths = 24
+)
+
+// DefaultConsumptionWindows are the windows, in days, of the consumption report when none are given.
+var DefaultConsumptionWindows = []int{7, 30, 90}
+
+// ConsumptionService derives consumption statistics and stock-out forecasts from the lote history
+type ConsumptionService interface {
+	GetConsumptionReport(windows []int, forecastDays int, userID int) (*models.ConsumptionReport, error)
+	FillDaysOfCover(products []models.Product, userID int) error
+	GetDailyRates(forecastDays int, userID int) (map[string]float64, error)
+	GetExpiryWasteReport(forecastDays int, userID int) (*models.ExpiryWasteReport, error)
+	GetABCReport(from, to time.Time, classALimit, classBLimit float64, userID int) (*models.ABCReport, error)
+	GetAgingReport(forecastDays int, userID int) (*models.StockAgingReport, error)
+}
+
+type consumptionService struct {
+	historyRepo repository.HistoryRepository
+	productRepo repository.ProductRepository
+	paramsRepo  repository.ProductParametersRepository
+}
+
+// NewConsumptionService creates a new ConsumptionService
+func NewConsumptionService(historyRepo repository.HistoryRepository, productRepo repository.ProductRepository, paramsRepo repository.ProductParametersRepository) ConsumptionService {
+	return &consumptionService{
+		historyRepo: historyRepo,
+		productRepo: productRepo,
+		paramsRepo:  paramsRepo,
+	}
+}
+
+// consumptionEvent is a quantity taken out of a product's stock at some instant.
+type consumptionEvent struct {
+	productID string
+	date      time.Time
+	quantity  float64
+}
+
+// GetConsumptionReport computes, for every product, the total, daily and weekly consumption over each
+// window (in days, ending now), the consumption by calendar month over the last 24 months, and the
+// days of cover and stock-out date at the average daily consumption of the forecast window.
+func (s *consumptionService) GetConsumptionReport(windows []int, forecastDays int, userID int) (*models.ConsumptionReport, error) {
+	now := time.Now()
+	seasonalityFrom := monthStart(now).AddDate(0, -(consumptionSeasonalityMonths - 1), 0)
+	since := seasonalityFrom
+	for _, days := range append([]int{forecastDays}, windows...) {
+		if start := now.AddDate(0, 0, -days); start.Before(since) {
+			since = start
+		}
+	}
+
+	products, err := s.productRepo.GetAll(userID)
+	if err != nil {
+		return nil, err
+	}
+	events, err := s.loadConsumption(since, nil, userID)
+	if err != nil {
+		return nil, err
+	}
+	byProduct := make(map[string][]consumptionEvent)
+	for _, e := range events {
+		byProduct[e.productID] = append(byProduct[e.productID], e)
+	}
+
+	// How many times each calendar month occurs in the seasonality period
+	var occurrences [12]int
+	for m := seasonalityFrom; !m.After(now); m = m.AddDate(0, 1, 0) {
+		occurrences[m.Month()-1]++
+	}
+
+	report := &models.ConsumptionReport{
+		GeneratedAt:        now,
+		ForecastWindowDays: forecastDays,
+		SeasonalityFrom:    seasonalityFrom.Format("2006-01-02"),
+		Products:           make([]models.ProductConsumption, 0, len(products)),
+	}
+	for _, p := range products {
+		productEvents := byProduct[p.ID]
+		pc := models.ProductConsumption{
+			ProductID:       p.ID,
+			ProductName:     p.Name,
+			Unit:            p.Unit,
+			CurrentQuantity: p.Quantity,
+			Windows:         make([]models.ConsumptionWindow, 0, len(windows)),
+			Seasonality:     make([]models.MonthlySeasonality, 12),
+		}
+		for _, days := range windows {
+			total := consumedSince(productEvents, now.AddDate(0, 0, -days))
+			pc.Windows = append(pc.Windows, models.ConsumptionWindow{
+				Days:          days,
+				Total:         roundQuantity(total),
+				DailyAverage:  roundQuantity(total / float64(days)),
+				WeeklyAverage: roundQuantity(total / float64(days) * 7),
+			})
+		}
+
+		var monthTotals [12]float64
+		var seasonTotal float64
+		for _, e := range productEvents {
+			if !e.date.Before(seasonalityFrom) {
+				monthTotals[e.date.Month()-1] += e.quantity
+				seasonTotal += e.quantity
+			}
+		}
+		averageMonth := seasonTotal / consumptionSeasonalityMonths
+		for i := range pc.Seasonality {
+			season := models.MonthlySeasonality{Month: i + 1, Total: roundQuantity(monthTotals[i])}
+			if occurrences[i] > 0 {
+				average := monthTotals[i] / float64(occurrences[i])
+				season.MonthlyAverage = roundQuantity(average)
+				if averageMonth > 0 {
+					index := math.Round(average/averageMonth*100) / 100
+					season.Index = &index
+				}
+			}
+			pc.Seasonality[i] = season
+		}
+
+		pc.DailyRate = roundQuantity(consumedSince(productEvents, now.AddDate(0, 0, -forecastDays)) / float64(forecastDays))
+		pc.DaysOfCover, pc.StockOutDate = forecastCover(p.Quantity, pc.DailyRate, now)
+		report.Products = append(report.Products, pc)
+	}
+	sort.Slice(report.Products, func(i, j int) bool { return report.Products[i].ProductName < report.Products[j].ProductName })
+	return report, nil
+}
+
+// FillDaysOfCover sets the days of cover of each product at the average daily consumption of the
+// default forecast window. Products without consumption in the window are left without it.
+func (s *consumptionService) FillDaysOfCover(products []models.Product, userID int) error {
+	rates, err := s.GetDailyRates(DefaultForecastWindowDays, userID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for i := range products {
+		products[i].DaysOfCover, _ = forecastCover(products[i].Quantity, rates[products[i].ID], now)
+	}
+	return nil
+}
+
+// GetDailyRates returns the average daily consumption of each product over the last forecastDays
+// days. Products without consumption in the window are left out.
+func (s *consumptionService) GetDailyRates(forecastDays int, userID int) (map[string]float64, error) {
+	events, err := s.loadConsumption(time.Now().AddDate(0, 0, -forecastDays), nil, userID)
+	if err != nil {
+		return nil, err
+	}
+	rates := make(map[string]float64)
+	for _, e := range events {
+		rates[e.productID] += e.quantity
+	}
+	for id, consumed := range rates {
+		rates[id] = roundQuantity(consumed / float64(forecastDays))
+	}
+	return rates, nil
+}
+
+// loadConsumption reads the lote history since the given instant, up to another if given, and returns
+// its consumption events: every decrease of a lote's quantity, and the removal of a lote that was not
+// expired yet (the quantity of expired lotes that are removed is waste, not consumption).
+func (s *consumptionService) loadConsumption(since time.Time, until *time.Time, userID int) ([]consumptionEvent, error) {
+	var events []consumptionEvent
+	filter := models.HistoryFilter{From: &since, To: until, EntityType: EntityTypeLote}
+	err := s.historyRepo.WalkHistory(filter, userID, func(entry models.History) error {
+		var detail models.LoteChangeDetail
+		if err := json.Unmarshal(entry.Changes, &detail); err != nil {
+			log.Printf("WARN: consumption - failed to unmarshal lote change %s: %v", entry.ID, err)
+			return nil
+		}
+		if quantity := loteConsumption(entry, detail); quantity > 0 {
+			events = append(events, consumptionEvent{productID: detail.ProductID, date: entry.Date, quantity: quantity})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// loteConsumption returns the quantity a lote history entry took out of stock as consumption.
+func loteConsumption(entry models.History, detail models.LoteChangeDetail) float64 {
+	switch detail.Action {
+	case "updated":
+		if detail.QuantityBefore != nil && detail.QuantityAfter != nil && *detail.QuantityAfter < *detail.QuantityBefore {
+			return *detail.QuantityBefore - *detail.QuantityAfter
+		}
+	case "deleted":
+		if detail.QuantityBefore != nil && !loteExpiredAt(detail.DataValidade, entry.Date) {
+			return *detail.QuantityBefore
+		}
+	}
+	return 0
+}
+
+// loteExpiredAt reports whether a lote with the given expiry date was expired at the instant,
+// that is, whether the expiry date is before the instant's day.
+func loteExpiredAt(dataValidade *string, at time.Time) bool {
+	if dataValidade == nil {
+		return false
+	}
+	expiry := dateOnly(*dataValidade)
+	if _, err := time.Parse("2006-01-02", expiry); err != nil {
+		return false
+	}
+	return expiry < at.Local().Format("2006-01-02")
+}
+
+func consumedSince(events []consumptionEvent, since time.Time) float64 {
+	var total float64
+	for _, e := range events {
+		if !e.date.Before(since) {
+			total += e.quantity
+		}
+	}
+	return total
+}
+
+// forecastCover returns how many days the quantity lasts at the daily rate and the day it runs out,
+// both nil when nothing is being consumed.
+func forecastCover(quantity, dailyRate float64, now time.Time) (*float64, *string) {
+	if dailyRate <= 0 {
+		return nil, nil
+	}
+	days := math.Max(quantity, 0) / dailyRate
+	rounded := math.Round(days*10) / 10
+	stockOut := now.AddDate(0, 0, int(math.Floor(days))).Format("2006-01-02")
+	return &rounded, &stockOut
+}
+
+// roundQuantity rounds a computed quantity to 6 decimal places, hiding floating-point noise.
+func roundQuantity(q float64) float64 {
+	return math.Round(q*1e6) / 1e6
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/repository"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/utils"
+)
+
+const (
+	// dashboardRecentBatches is how many history batches the dashboard lists, at most.
+	dashboardRecentBatches = 10
+	// dashboardRecentDays is how far back, in days, a history batch counts as recent.
+	dashboardRecentDays = 30
+)
+
+// DashboardExpiringDays are the windows, in days, in which the dashboard counts expiring lotes.
+var DashboardExpiringDays = []int{7, 30, 90}
+
+// DashboardService assembles the dashboard summary
+type DashboardService interface {
+	GetDashboard(userID int) (*models.Dashboard, error)
+}
+
+type dashboardService struct {
+	repo          repository.DashboardRepository
+	backupManager *utils.BackupManager
+}
+
+// NewDashboardService creates a new DashboardService
+func NewDashboardService(repo repository.DashboardRepository, backupManager *utils.BackupManager) DashboardService {
+	return &dashboardService{repo: repo, backupManager: backupManager}
+}
+
+// GetDashboard sums the user's stock by unit, counts the lotes that are expired or expire soon, and
+// lists the low-stock products and the latest history batches, along with the status of the last
+// database backup. The totals are computed by the database; the counts across units are summed here.
+func (s *dashboardService) GetDashboard(userID int) (*models.Dashboard, error) {
+	now := time.Now()
+	units, err := s.repo.GetUnitTotals(now.Format("2006-01-02"), DashboardExpiringDays, userID)
+	if err != nil {
+		return nil, err
+	}
+	lowStock, err := s.repo.GetLowStock(userID)
+	if err != nil {
+		return nil, err
+	}
+	batches, err := s.repo.GetRecentBatches(now.AddDate(0, 0, -dashboardRecentDays), dashboardRecentBatches, userID)
+	if err != nil {
+		return nil, err
+	}
+	backup, err := s.backupManager.Status()
+	if err != nil {
+		log.Printf("WARN: dashboard - failed to read backup status: %v", err)
+	}
+
+	dashboard := &models.Dashboard{
+		GeneratedAt:   now,
+		ExpiringDays:  DashboardExpiringDays,
+		Units:         units,
+		LowStock:      lowStock,
+		RecentBatches: batches,
+		Backup:        backup,
+	}
+	for _, u := range units {
+		dashboard.Products += u.Products
+		dashboard.Lotes += u.Lotes
+		dashboard.ExpiredLotes += u.ExpiredLotes
+	}
+	return dashboard, nil
+}
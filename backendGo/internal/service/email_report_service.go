@@ -0,0 +1,416 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/repository"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/utils"
+)
+
+// Email report types
+const (
+	EmailReportStockSummary  = "stock_summary"
+	EmailReportExpiringLotes = "expiring_lotes"
+	EmailReportLowStock      = "low_stock"
+)
+
+// Email report delivery outcomes
+const (
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed"
+	DeliverySkipped = "skipped"
+)
+
+// MaxEmailReportDeliveries is the most deliveries the delivery log returns at once.
+const MaxEmailReportDeliveries = 500
+
+var ErrEmailNotConfigured = utils.ErrSMTPNotConfigured
+
+// EmailReportService manages the users' scheduled email reports and sends them
+type EmailReportService interface {
+	List(userID int) ([]models.EmailReport, error)
+	Get(id int64, userID int) (*models.EmailReport, error)
+	Create(input models.EmailReportInput, userID int) (*models.EmailReport, error)
+	Update(id int64, input models.EmailReportInput, userID int) (*models.EmailReport, error)
+	Delete(id int64, userID int) (bool, error)
+	SendNow(id int64, userID int) (*models.EmailReportDelivery, error)
+	SendDue(now time.Time) ([]models.EmailReportDelivery, error)
+	ListDeliveries(userID int, limit int) ([]models.EmailReportDelivery, error)
+}
+
+type emailReportService struct {
+	repo          repository.EmailReportRepository
+	productRepo   repository.ProductRepository
+	dashboardRepo repository.DashboardRepository
+	mailer        *utils.Mailer
+}
+
+// NewEmailReportService creates a new EmailReportService
+func NewEmailReportService(repo repository.EmailReportRepository, productRepo repository.ProductRepository, dashboardRepo repository.DashboardRepository, mailer *utils.Mailer) EmailReportService {
+	return &emailReportService{
+		repo:          repo,
+		productRepo:   productRepo,
+		dashboardRepo: dashboardRepo,
+		mailer:        mailer,
+	}
+}
+
+// List retrieves the user's email reports.
+func (s *emailReportService) List(userID int) ([]models.EmailReport, error) {
+	return s.repo.List(userID)
+}
+
+// Get retrieves an email report, or nil if the user has no such report.
+func (s *emailReportService) Get(id int64, userID int) (*models.EmailReport, error) {
+	return s.repo.GetByID(id, userID)
+}
+
+// emailReportFromInput applies the defaults of the omitted fields: weekly reports on Monday,
+// monthly reports on the 1st, at 7:00, expiring lotes within 30 days, enabled.
+func emailReportFromInput(input models.EmailReportInput, userID int) *models.EmailReport {
+	report := &models.EmailReport{
+		UserID:     userID,
+		ReportType: input.ReportType,
+		Recipients: input.Recipients,
+		Frequency:  input.Frequency,
+		Weekday:    1,
+		DayOfMonth: 1,
+		Hour:       7,
+		DaysAhead:  30,
+		SkipEmpty:  input.SkipEmpty,
+		Enabled:    true,
+	}
+	if input.Weekday != nil {
+		report.Weekday = *input.Weekday
+	}
+	if input.DayOfMonth != nil {
+		report.DayOfMonth = *input.DayOfMonth
+	}
+	if input.Hour != nil {
+		report.Hour = *input.Hour
+	}
+	if input.DaysAhead != nil {
+		report.DaysAhead = *input.DaysAhead
+	}
+	if input.Enabled != nil {
+		report.Enabled = *input.Enabled
+	}
+	return report
+}
+
+// Create saves a new email report.
+func (s *emailReportService) Create(input models.EmailReportInput, userID int) (*models.EmailReport, error) {
+	report := emailReportFromInput(input, userID)
+	if err := s.repo.Create(report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Update replaces an email report's settings, returning nil if the user has no such report.
+func (s *emailReportService) Update(id int64, input models.EmailReportInput, userID int) (*models.EmailReport, error) {
+	report := emailReportFromInput(input, userID)
+	report.ID = id
+	found, err := s.repo.Update(report)
+	if err != nil || !found {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Delete removes an email report and reports whether the user had such a report.
+func (s *emailReportService) Delete(id int64, userID int) (bool, error) {
+	return s.repo.Delete(id, userID)
+}
+
+// ListDeliveries retrieves the user's latest report deliveries.
+func (s *emailReportService) ListDeliveries(userID int, limit int) ([]models.EmailReportDelivery, error) {
+	return s.repo.ListDeliveries(userID, limit)
+}
+
+// SendNow sends an email report immediately, whatever its schedule and even if it is disabled, and
+// returns the logged delivery; nil if the user has no such report. Reports are always sent, even
+// without rows. A failure to send is logged as a failed delivery, not returned as an error.
+func (s *emailReportService) SendNow(id int64, userID int) (*models.EmailReportDelivery, error) {
+	if !s.mailer.Enabled() {
+		return nil, ErrEmailNotConfigured
+	}
+	report, err := s.repo.GetByID(id, userID)
+	if err != nil || report == nil {
+		return nil, err
+	}
+	return s.deliver(*report, "manual", time.Now())
+}
+
+// SendDue sends the enabled reports scheduled for the hour of now, and returns their deliveries.
+// A report the scheduler already ran in this hour is not sent again.
+func (s *emailReportService) SendDue(now time.Time) ([]models.EmailReportDelivery, error) {
+	if !s.mailer.Enabled() {
+		return nil, ErrEmailNotConfigured
+	}
+	reports, err := s.repo.ListEnabled()
+	if err != nil {
+		return nil, err
+	}
+	hourStart := now.Truncate(time.Hour)
+	deliveries := []models.EmailReportDelivery{}
+	for _, report := range reports {
+		if !emailReportDue(report, now) || (report.LastRunAt != nil && !report.LastRunAt.Before(hourStart)) {
+			continue
+		}
+		// Mark the report first, so that a failing report is not retried in a loop
+		if err := s.repo.MarkRun(report.ID, now); err != nil {
+			log.Printf("WARN: email reports - %v", err)
+			continue
+		}
+		delivery, err := s.deliver(report, "scheduled", now)
+		if err != nil {
+			log.Printf("WARN: email reports - failed to run report %d of user %d: %v", report.ID, report.UserID, err)
+			continue
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+	return deliveries, nil
+}
+
+// emailReportDue reports whether a report's schedule falls on the hour of now.
+func emailReportDue(report models.EmailReport, now time.Time) bool {
+	if now.Hour() != report.Hour {
+		return false
+	}
+	switch report.Frequency {
+	case "daily":
+		return true
+	case "weekly":
+		return int(now.Weekday()) == report.Weekday
+	case "monthly":
+		return now.Day() == report.DayOfMonth
+	}
+	return false
+}
+
+// emailReportContent is a report ready to be rendered: a summary and a table, which also goes
+// out as the CSV attachment.
+type emailReportContent struct {
+	Title       string
+	GeneratedAt string
+	Summary     []string
+	Empty       string // Shown instead of the table when it has no rows
+	Headers     []string
+	Rows        [][]interface{}
+	fileName    string
+}
+
+// deliver builds a report, sends it and logs the delivery. Only failing to build or log the report
+// is returned as an error; a failure to send is logged in the delivery.
+func (s *emailReportService) deliver(report models.EmailReport, triggeredBy string, now time.Time) (*models.EmailReportDelivery, error) {
+	content, err := s.buildReport(report, now)
+	if err != nil {
+		return nil, err
+	}
+	delivery := &models.EmailReportDelivery{
+		ReportID:    &report.ID,
+		ReportType:  report.ReportType,
+		Recipients:  report.Recipients,
+		TriggeredBy: triggeredBy,
+		Status:      DeliverySent,
+		RowCount:    len(content.Rows),
+	}
+
+	if len(content.Rows) == 0 && report.SkipEmpty && triggeredBy == "scheduled" {
+		delivery.Status = DeliverySkipped
+	} else if msg, err := renderEmailReport(content, report.Recipients); err != nil {
+		return nil, err
+	} else if err := s.mailer.Send(msg); err != nil {
+		delivery.Status = DeliveryFailed
+		delivery.Error = err.Error()
+	}
+	if err := s.repo.CreateDelivery(delivery, report.UserID); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// buildReport gathers the data of a report.
+func (s *emailReportService) buildReport(report models.EmailReport, now time.Time) (*emailReportContent, error) {
+	content := &emailReportContent{GeneratedAt: now.Format("02/01/2006 15:04")}
+	today := now.Format("2006-01-02")
+	switch report.ReportType {
+	case EmailReportStockSummary:
+		content.Title = "Resumo do estoque"
+		content.fileName = "resumo_estoque_" + today + ".csv"
+		content.Empty = "Nenhum produto cadastrado."
+		units, err := s.dashboardRepo.GetUnitTotals(today, DashboardExpiringDays, report.UserID)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range units {
+			line := fmt.Sprintf("%s: %d produtos, %d lotes, %s %s em estoque", u.Unit, u.Products, u.Lotes, formatSheetQuantity(u.Quantity), u.Unit)
+			if u.ExpiredLotes > 0 {
+				line += fmt.Sprintf("; %d lotes vencidos (%s %s)", u.ExpiredLotes, formatSheetQuantity(u.ExpiredQuantity), u.Unit)
+			}
+			for _, w := range u.Expiring {
+				if w.Days == 30 && w.Lotes > 0 {
+					line += fmt.Sprintf("; %d lotes vencem em até 30 dias", w.Lotes)
+				}
+			}
+			content.Summary = append(content.Summary, line)
+		}
+		products, err := s.productRepo.GetAll(report.UserID)
+		if err != nil {
+			return nil, err
+		}
+		sort.SliceStable(products, func(i, j int) bool { return strings.ToLower(products[i].Name) < strings.ToLower(products[j].Name) })
+		content.Headers = []string{"Produto", "Unidade", "Quantidade", "Lotes", "Próxima validade"}
+		for _, p := range products {
+			next := ""
+			for _, l := range p.Lotes {
+				if validade := dateOnly(l.DataValidade); validade >= today && (next == "" || validade < next) {
+					next = validade
+				}
+			}
+			content.Rows = append(content.Rows, []interface{}{p.Name, p.Unit, p.Quantity, len(p.Lotes), formatExportDate(&next)})
+		}
+
+	case EmailReportExpiringLotes:
+		content.Title = fmt.Sprintf("Lotes vencidos ou que vencem em até %d dias", report.DaysAhead)
+		content.fileName = "lotes_vencendo_" + today + ".csv"
+		content.Empty = "Nenhum lote vencido ou perto do vencimento."
+		products, err := s.productRepo.GetAll(report.UserID)
+		if err != nil {
+			return nil, err
+		}
+		limit := now.AddDate(0, 0, report.DaysAhead).Format("2006-01-02")
+		type expiringLote struct {
+			product  models.Product
+			lote     models.Lote
+			validade string
+		}
+		var lotes []expiringLote
+		for _, p := range products {
+			for _, l := range p.Lotes {
+				if validade := dateOnly(l.DataValidade); validade <= limit {
+					lotes = append(lotes, expiringLote{product: p, lote: l, validade: validade})
+				}
+			}
+		}
+		sort.SliceStable(lotes, func(i, j int) bool { return lotes[i].validade < lotes[j].validade })
+		content.Headers = []string{"Produto", "Unidade", "Quantidade", "Validade", "Dias para vencer"}
+		expired := 0
+		todayDate, _ := time.ParseInLocation("2006-01-02", today, now.Location())
+		for _, l := range lotes {
+			days := ""
+			if expiry, err := time.ParseInLocation("2006-01-02", l.validade, now.Location()); err == nil {
+				n := int(expiry.Sub(todayDate).Hours() / 24)
+				days = fmt.Sprint(n)
+				if n < 0 {
+					days = "vencido"
+					expired++
+				}
+			}
+			content.Rows = append(content.Rows, []interface{}{l.product.Name, l.product.Unit, l.lote.Quantity, formatExportDate(&l.validade), days})
+		}
+		if len(lotes) > 0 {
+			content.Summary = []string{fmt.Sprintf("%d lotes vencidos e %d que vencem até %s.", expired, len(lotes)-expired, formatExportDate(&limit))}
+		}
+
+	case EmailReportLowStock:
+		content.Title = "Produtos com estoque baixo"
+		content.fileName = "estoque_baixo_" + today + ".csv"
+		content.Empty = "Nenhum produto com estoque baixo."
+		products, err := s.dashboardRepo.GetLowStock(report.UserID)
+		if err != nil {
+			return nil, err
+		}
+		content.Headers = []string{"Produto", "Unidade", "Quantidade", "Quantidade mínima"}
+		for _, p := range products {
+			var minQuantity interface{}
+			if p.MinQuantity != nil {
+				minQuantity = *p.MinQuantity
+			}
+			content.Rows = append(content.Rows, []interface{}{p.ProductName, p.Unit, p.Quantity, minQuantity})
+		}
+		if len(products) > 0 {
+			content.Summary = []string{fmt.Sprintf("%d produtos na quantidade mínima ou abaixo dela, ou zerados.", len(products))}
+		}
+
+	default:
+		return nil, errors.New("unknown email report type " + report.ReportType)
+	}
+	return content, nil
+}
+
+var emailReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"cell": formatEmailCell,
+	"numeric": func(v interface{}) bool {
+		switch v.(type) {
+		case float64, int:
+			return true
+		}
+		return false
+	},
+}).Parse(`<!DOCTYPE html>
+<html><body style="font-family: Arial, Helvetica, sans-serif; font-size: 14px; color: #222;">
+<h2 style="margin-bottom: 4px;">{{.Title}}</h2>
+<p style="color: #666; margin-top: 0;">Gerado em {{.GeneratedAt}}</p>
+{{range .Summary}}<p>{{.}}</p>
+{{end}}{{if .Rows}}<table style="border-collapse: collapse;" cellpadding="6">
+<tr>{{range .Headers}}<th style="border-bottom: 2px solid #444; text-align: left;">{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td style="border-bottom: 1px solid #ddd;{{if numeric .}} text-align: right;{{end}}">{{cell .}}</td>{{end}}</tr>
+{{end}}</table>
+<p style="color: #666;">A tabela segue em anexo em CSV.</p>{{else}}<p>{{.Empty}}</p>{{end}}
+</body></html>
+`))
+
+// formatEmailCell writes a table cell the way the report reads it in Portuguese.
+func formatEmailCell(v interface{}) string {
+	switch value := v.(type) {
+	case float64:
+		return formatSheetQuantity(value)
+	case nil:
+		return "-"
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// renderEmailReport turns the report into an email: the HTML body and the table as a CSV attachment.
+func renderEmailReport(content *emailReportContent, recipients []string) (utils.EmailMessage, error) {
+	var html bytes.Buffer
+	if err := emailReportTemplate.Execute(&html, content); err != nil {
+		return utils.EmailMessage{}, fmt.Errorf("failed to render email report: %w", err)
+	}
+	msg := utils.EmailMessage{
+		To:      recipients,
+		Subject: content.Title + " - " + content.GeneratedAt,
+		HTML:    html.String(),
+	}
+	if len(content.Rows) > 0 {
+		var csv bytes.Buffer
+		w, err := utils.NewCSVWriter(&csv)
+		if err == nil {
+			err = w.WriteHeader(content.Headers)
+		}
+		for _, row := range content.Rows {
+			if err == nil {
+				err = w.WriteRow(row)
+			}
+		}
+		if err == nil {
+			err = w.Close()
+		}
+		if err != nil {
+			return utils.EmailMessage{}, fmt.Errorf("failed to write email report attachment: %w", err)
+		}
+		msg.Attachments = []utils.EmailAttachment{{Name: content.fileName, ContentType: utils.TableContentType(utils.TableFormatCSV), Data: csv.Bytes()}}
+	}
+	return msg, nil
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+)
+
+// GetExpiryWasteReport projects, for every product with lotes, how much of each lote will expire
+// before it is used. Lotes are consumed first-expired first-out at the product's average daily
+// consumption over the forecast window: a lote can only be drawn from until the end of its expiry
+// day, and whatever is left then is waste. Waste is valued at the product's unit cost, when set.
+func (s *consumptionService) GetExpiryWasteReport(forecastDays int, userID int) (*models.ExpiryWasteReport, error) {
+	now := time.Now()
+	products, err := s.productRepo.GetAll(userID)
+	if err != nil {
+		return nil, err
+	}
+	rates, err := s.GetDailyRates(forecastDays, userID)
+	if err != nil {
+		return nil, err
+	}
+	params, err := s.paramsRepo.List(userID)
+	if err != nil {
+		return nil, err
+	}
+	unitCosts := make(map[string]*float64)
+	for _, p := range params {
+		unitCosts[p.ProductID] = p.UnitCost
+	}
+
+	report := &models.ExpiryWasteReport{
+		GeneratedAt:        now,
+		ForecastWindowDays: forecastDays,
+		Products:           []models.ProductExpiryProjection{},
+	}
+	for _, p := range products {
+		if len(p.Lotes) == 0 {
+			continue
+		}
+		projection := projectExpiry(p, rates[p.ID], unitCosts[p.ID], now)
+		if projection.EstimatedLoss != nil {
+			report.TotalEstimatedLoss += *projection.EstimatedLoss
+		} else if projection.ProjectedWaste > 0 {
+			report.UnvaluedProducts++
+		}
+		report.Products = append(report.Products, projection)
+	}
+	report.TotalEstimatedLoss = math.Round(report.TotalEstimatedLoss*100) / 100
+
+	// Largest losses first, then largest waste for products without a unit cost
+	sort.SliceStable(report.Products, func(i, j int) bool {
+		a, b := report.Products[i], report.Products[j]
+		lossA, lossB := valueOrZero(a.EstimatedLoss), valueOrZero(b.EstimatedLoss)
+		if lossA != lossB {
+			return lossA > lossB
+		}
+		if a.ProjectedWaste != b.ProjectedWaste {
+			return a.ProjectedWaste > b.ProjectedWaste
+		}
+		return a.ProductName < b.ProductName
+	})
+	return report, nil
+}
+
+// projectExpiry runs the FEFO projection of one product's lotes at the given daily rate.
+func projectExpiry(p models.Product, dailyRate float64, unitCost *float64, now time.Time) models.ProductExpiryProjection {
+	projection := models.ProductExpiryProjection{
+		ProductID:   p.ID,
+		ProductName: p.Name,
+		Unit:        p.Unit,
+		Quantity:    p.Quantity,
+		DailyRate:   roundQuantity(dailyRate),
+		UnitCost:    unitCost,
+		Lotes:       make([]models.LoteExpiryProjection, 0, len(p.Lotes)),
+	}
+
+	lotes := append([]models.Lote(nil), p.Lotes...)
+	sort.SliceStable(lotes, func(i, j int) bool { return dateOnly(lotes[i].DataValidade) < dateOnly(lotes[j].DataValidade) })
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	cursor := 0.0 // Days from now until the lotes projected so far are used up
+	for _, l := range lotes {
+		lp := models.LoteExpiryProjection{LoteID: l.ID, DataValidade: dateOnly(l.DataValidade), Quantity: l.Quantity}
+		expiry, err := time.ParseInLocation("2006-01-02", lp.DataValidade, now.Location())
+		if err != nil {
+			continue // Not a date; nothing can be projected
+		}
+		lp.DaysToExpiry = int(math.Round(expiry.Sub(today).Hours() / 24))
+		lp.Expired = expiry.Before(today)
+
+		// A lote is usable until the end of its expiry day
+		usableDays := expiry.AddDate(0, 0, 1).Sub(now).Hours() / 24
+		use := 0.0
+		if dailyRate > 0 && usableDays > cursor {
+			use = math.Min(l.Quantity, dailyRate*(usableDays-cursor))
+		}
+		if use > 0 {
+			cursor += use / dailyRate
+			if l.Quantity-use < 1e-9 {
+				usedUp := now.Add(time.Duration(cursor * 24 * float64(time.Hour))).Format("2006-01-02")
+				lp.UsedUpOn = &usedUp
+			}
+		}
+		lp.ProjectedUse = roundQuantity(use)
+		lp.ProjectedWaste = roundQuantity(l.Quantity - use)
+		if unitCost != nil {
+			loss := math.Round(*unitCost*lp.ProjectedWaste*100) / 100
+			lp.EstimatedLoss = &loss
+		}
+		projection.ProjectedWaste += lp.ProjectedWaste
+		projection.Lotes = append(projection.Lotes, lp)
+	}
+
+	projection.ProjectedWaste = roundQuantity(projection.ProjectedWaste)
+	if unitCost != nil {
+		loss := math.Round(*unitCost*projection.ProjectedWaste*100) / 100
+		projection.EstimatedLoss = &loss
+	}
+	return projection
+}
+
+func valueOrZero(v *float64) float64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
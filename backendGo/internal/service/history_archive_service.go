@@ -0,0 +1,316 @@
+package service
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/config"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/repository"
+)
+
+var (
+	ErrRetentionDisabled = errors.New("history retention is disabled")
+	ErrArchiveNotFound   = errors.New("history archive not found")
+	ErrArchiveRestored   = errors.New("history archive was already restored")
+)
+
+// HistoryArchiveService moves history older than the retention period to compressed JSONL files,
+// one per month, and restores them on demand.
+type HistoryArchiveService interface {
+	Archive(userID int, retentionMonths int) (*models.HistoryArchiveRun, error)
+	ArchiveAllUsers() ([]*models.HistoryArchiveRun, error)
+	ListArchives(userID int) ([]models.HistoryArchive, error)
+	Restore(archiveID int64, userID int) (int, error)
+}
+
+type historyArchiveService struct {
+	historyRepo  repository.HistoryRepository
+	archiveRepo  repository.HistoryArchiveRepository
+	snapshotRepo repository.StockSnapshotRepository
+	stockSvc     StockReportService
+	db           *sql.DB // For transactions
+	cfg          config.HistoryConfig
+}
+
+// NewHistoryArchiveService creates a new HistoryArchiveService
+func NewHistoryArchiveService(historyRepo repository.HistoryRepository, archiveRepo repository.HistoryArchiveRepository, snapshotRepo repository.StockSnapshotRepository, stockSvc StockReportService, db *sql.DB, cfg config.HistoryConfig) HistoryArchiveService {
+	return &historyArchiveService{
+		historyRepo:  historyRepo,
+		archiveRepo:  archiveRepo,
+		snapshotRepo: snapshotRepo,
+		stockSvc:     stockSvc,
+		db:           db,
+		cfg:          cfg,
+	}
+}
+
+// Archive archives the user's history dated before the start of the month retentionMonths ago.
+// Month by month, oldest first, it makes sure a snapshot exists at the end of the month (reconstructed
+// from the history about to be archived) and then moves that month's entries to a file. Older snapshots
+// are finally compacted to one per month, so point-in-time reports keep a monthly resolution.
+func (s *historyArchiveService) Archive(userID int, retentionMonths int) (*models.HistoryArchiveRun, error) {
+	if retentionMonths <= 0 {
+		return nil, ErrRetentionDisabled
+	}
+
+	cutoff := monthStart(time.Now()).AddDate(0, -retentionMonths, 0)
+	run := &models.HistoryArchiveRun{UserID: userID, ArchivedBefore: cutoff, Archives: []models.HistoryArchive{}}
+
+	earliest, err := s.archiveRepo.GetEarliestEntryDate(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !earliest.IsZero() {
+		for end := monthStart(earliest).AddDate(0, 1, 0); !end.After(cutoff); end = end.AddDate(0, 1, 0) {
+			created, err := s.ensureSnapshot(end, userID)
+			if err != nil {
+				return run, err
+			}
+			if created {
+				run.SnapshotsCreated++
+			}
+
+			archive, err := s.archiveBefore(end, userID)
+			if err != nil {
+				return run, err
+			}
+			if archive != nil {
+				run.Archives = append(run.Archives, *archive)
+				run.EntriesArchived += archive.EntryCount
+			}
+		}
+	}
+
+	if run.SnapshotRowsRemoved, err = s.snapshotRepo.CompactBefore(cutoff, userID); err != nil {
+		return run, err
+	}
+	return run, nil
+}
+
+// ArchiveAllUsers applies the configured retention to every user with history; used by the periodic job.
+func (s *historyArchiveService) ArchiveAllUsers() ([]*models.HistoryArchiveRun, error) {
+	if s.cfg.RetentionMonths <= 0 {
+		return nil, ErrRetentionDisabled
+	}
+	userIDs, err := s.historyRepo.GetChainOwnerIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []*models.HistoryArchiveRun
+	for _, userID := range userIDs {
+		run, err := s.Archive(userID, s.cfg.RetentionMonths)
+		if err != nil {
+			log.Printf("WARN: history archival failed for user %d: %v", userID, err)
+			continue
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+// ListArchives retrieves the user's archives, newest first.
+func (s *historyArchiveService) ListArchives(userID int) ([]models.HistoryArchive, error) {
+	return s.archiveRepo.List(userID)
+}
+
+// Restore re-imports the entries of an archive into history, after checking the file against
+// the checksum recorded when it was written. It returns the number of entries restored.
+func (s *historyArchiveService) Restore(archiveID int64, userID int) (int, error) {
+	archive, err := s.archiveRepo.GetByID(archiveID, userID)
+	if err != nil {
+		return 0, err
+	}
+	if archive == nil {
+		return 0, ErrArchiveNotFound
+	}
+	if archive.RestoredAt != nil {
+		return 0, ErrArchiveRestored
+	}
+
+	entries, err := readArchiveFile(filepath.Join(s.cfg.ArchiveDir, archive.FileName), archive.SHA256)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Rollback if not committed
+
+	// Archived entries keep their dates, which may lie inside closed months
+	if err := s.historyRepo.AllowBackdatedTx(tx); err != nil {
+		return 0, err
+	}
+	restored, err := s.archiveRepo.RestoreTx(tx, archive, entries)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return restored, nil
+}
+
+// ensureSnapshot stores a snapshot of the stock at the given instant unless one exists there.
+// It reports whether a snapshot was created.
+func (s *historyArchiveService) ensureSnapshot(at time.Time, userID int) (bool, error) {
+	exists, err := s.snapshotRepo.ExistsAt(at, userID)
+	if err != nil || exists {
+		return false, err
+	}
+	report, err := s.stockSvc.GetStockAt(at, userID)
+	if err != nil {
+		return false, err
+	}
+	if err := s.snapshotRepo.CreateFromPositions(at, userID, report.Products); err != nil {
+		return false, err
+	}
+	return len(report.Products) > 0, nil
+}
+
+// archiveBefore writes the entries dated before the given instant to a new archive file and removes
+// them from history. It returns nil if there is nothing to archive.
+func (s *historyArchiveService) archiveBefore(before time.Time, userID int) (*models.HistoryArchive, error) {
+	entries, err := s.archiveRepo.GetEntriesToArchive(before, userID)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+
+	month := before.AddDate(0, -1, 0).Format("2006-01")
+	archive := &models.HistoryArchive{
+		UserID:         userID,
+		FileName:       fmt.Sprintf("history-u%d-%s-%d.jsonl.gz", userID, month, time.Now().UnixNano()),
+		ArchivedBefore: before,
+		FirstEntryDate: entries[0].Date,
+		LastEntryDate:  entries[len(entries)-1].Date,
+		EntryCount:     len(entries),
+	}
+	path := filepath.Join(s.cfg.ArchiveDir, archive.FileName)
+	if archive.SizeBytes, archive.SHA256, err = writeArchiveFile(path, entries); err != nil {
+		return nil, err
+	}
+
+	if err := s.recordArchive(archive, entries); err != nil {
+		if rmErr := os.Remove(path); rmErr != nil {
+			log.Printf("WARN: failed to remove orphan history archive %s: %v", path, rmErr)
+		}
+		return nil, err
+	}
+	log.Printf("History archive %s written: %d entries of user %d", archive.FileName, archive.EntryCount, userID)
+	return archive, nil
+}
+
+// recordArchive records the archive and removes its entries from history in a single transaction.
+func (s *historyArchiveService) recordArchive(archive *models.HistoryArchive, entries []models.History) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Rollback if not committed
+
+	if err := s.archiveRepo.CreateTx(tx, archive, entries); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// writeArchiveFile writes entries as gzip-compressed JSON lines, through a temporary file so
+// a partial archive is never left under the final name. It returns the file size and its SHA-256.
+func writeArchiveFile(path string, entries []models.History) (int64, string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, "", fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	tmpPath := path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to create archive file: %w", err)
+	}
+	defer os.Remove(tmpPath) // No-op once renamed
+
+	hash := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(f, hash))
+	enc := json.NewEncoder(gz)
+	for _, entry := range entries {
+		if err := enc.Encode(models.NewHistoryAuditEntry(entry)); err != nil {
+			f.Close()
+			return 0, "", fmt.Errorf("failed to write history entry %s to archive: %w", entry.ID, err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		f.Close()
+		return 0, "", fmt.Errorf("failed to compress archive: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return 0, "", fmt.Errorf("failed to flush archive file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return 0, "", fmt.Errorf("failed to stat archive file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return 0, "", fmt.Errorf("failed to close archive file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return 0, "", fmt.Errorf("failed to move archive file into place: %w", err)
+	}
+	return info.Size(), hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// readArchiveFile reads the entries of an archive file, failing if its SHA-256 is not the expected one.
+func readArchiveFile(path, expectedSHA256 string) ([]models.History, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive file: %w", err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	tee := io.TeeReader(f, hash)
+	gz, err := gzip.NewReader(tee)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive file: %w", err)
+	}
+	defer gz.Close()
+
+	var entries []models.History
+	dec := json.NewDecoder(gz)
+	for {
+		var entry models.HistoryAuditEntry
+		if err := dec.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to decode archive entry %d: %w", len(entries)+1, err)
+		}
+		entries = append(entries, entry.Entry())
+	}
+	if _, err := io.Copy(io.Discard, tee); err != nil {
+		return nil, fmt.Errorf("failed to read archive file: %w", err)
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != expectedSHA256 {
+		return nil, fmt.Errorf("archive file %s does not match its recorded checksum", filepath.Base(path))
+	}
+	return entries, nil
+}
+
+// monthStart returns midnight of the first day of t's month, in t's location.
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/utils"
+)
+
+// historyExportHeaders are the columns of a history export, one row per entry.
+var historyExportHeaders = []string{
+	"Data", "Operação", "Tipo", "Ação", "ID Produto", "Produto", "ID Lote",
+	"Qtd Anterior", "Qtd Alterada", "Qtd Posterior", "Validade", "Validade Anterior", "Validade Nova",
+	"Lote Fornecedor", "Chave NF-e", "Campos Alterados", "Usuário", "IP", "ID Requisição",
+}
+
+var historyEntityLabels = map[string]string{
+	EntityTypeProduct:             "Produto",
+	EntityTypeLote:                "Lote",
+	EntityTypeProductBatchContext: "Contexto do Produto",
+}
+
+var historyActionLabels = map[string]string{
+	"created":                 "Criado",
+	"updated":                 "Atualizado",
+	"deleted":                 "Excluído",
+	"quantity_updated":        "Quantidade atualizada",
+	"details_updated":         "Detalhes atualizados",
+	"product_details_updated": "Detalhes do produto atualizados",
+	"quantity_adjusted":       "Quantidade ajustada",
+}
+
+// ExportHistory writes the entries matching the filter to w in chronological order, one row per entry,
+// with the fields of the product and lote changes flattened into columns. Entries are streamed from
+// the database; only the user's product names are kept in memory.
+func (s *historyService) ExportHistory(filter models.HistoryFilter, userID int, w utils.TableWriter) error {
+	products, err := s.productRepo.GetAll(userID)
+	if err != nil {
+		return fmt.Errorf("failed to get products for history export: %w", err)
+	}
+	// Names of deleted products are picked up from their entries as the export goes.
+	names := make(map[string]string, len(products))
+	for _, p := range products {
+		names[p.ID] = p.Name
+	}
+
+	if err := w.WriteHeader(historyExportHeaders); err != nil {
+		return fmt.Errorf("failed to write export header: %w", err)
+	}
+	err = s.repo.WalkHistory(filter, userID, func(entry models.History) error {
+		if err := w.WriteRow(historyExportRow(entry, names)); err != nil {
+			return fmt.Errorf("failed to write history entry %s: %w", entry.ID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// historyExportRow flattens an entry into the historyExportHeaders columns.
+func historyExportRow(entry models.History, names map[string]string) []interface{} {
+	var action, productID, productName, loteID string
+	var before, changed, after *float64
+	var validade, validadeOld, validadeNew, lotNumber, invoiceKey, fields string
+
+	switch entry.EntityType {
+	case EntityTypeProduct:
+		var change models.ProductChange
+		if json.Unmarshal(entry.Changes, &change) == nil {
+			action, productID, productName = change.Action, entry.EntityID, change.ProductName
+			before, changed, after = change.QuantityBefore, change.QuantityChanged, change.QuantityAfter
+			fields = formatChangedFields(change.ChangedFields)
+		}
+	case EntityTypeLote:
+		var change models.LoteChangeDetail
+		if json.Unmarshal(entry.Changes, &change) == nil {
+			action, productID, loteID = change.Action, change.ProductID, change.LoteID
+			before, changed, after = change.QuantityBefore, change.QuantityChanged, change.QuantityAfter
+			validade = formatExportDate(change.DataValidade)
+			validadeOld = formatExportDate(change.DataValidadeOld)
+			validadeNew = formatExportDate(change.DataValidadeNew)
+			lotNumber, invoiceKey = change.LotNumber, change.InvoiceKey
+		}
+	case EntityTypeProductBatchContext:
+		var change models.ProductBatchContextChangeDetail
+		if json.Unmarshal(entry.Changes, &change) == nil {
+			productID, productName = entry.EntityID, change.ProductNameSnapshot
+			quantityBefore, quantityAfter := change.QuantityBeforeBatch, change.QuantityAfterBatch
+			quantityChanged := quantityAfter - quantityBefore
+			before, changed, after = &quantityBefore, &quantityChanged, &quantityAfter
+		}
+	}
+
+	if productName != "" {
+		names[productID] = productName
+	} else {
+		productName = names[productID]
+	}
+	if label, ok := historyActionLabels[action]; ok {
+		action = label
+	}
+	entityType := entry.EntityType
+	if label, ok := historyEntityLabels[entityType]; ok {
+		entityType = label
+	}
+
+	return []interface{}{
+		entry.Date.Local().Format("02/01/2006 15:04:05"), entry.BatchID, entityType, action, productID, productName, loteID,
+		before, changed, after, validade, validadeOld, validadeNew,
+		lotNumber, invoiceKey, fields, entry.ActorUsername, entry.ClientIP, entry.RequestID,
+	}
+}
+
+// formatChangedFields renders changed fields as "field: old → new", separated by "; ".
+func formatChangedFields(fields []models.ChangedField) string {
+	parts := make([]string, 0, len(fields))
+	for _, f := range fields {
+		part := fmt.Sprintf("%s: %s → %s", f.Field, formatExportValue(f.OldValue), formatExportValue(f.NewValue))
+		if f.LoteID != "" {
+			part += " (lote " + f.LoteID + ")"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func formatExportValue(v interface{}) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprint(v)
+}
+
+// formatExportDate renders a YYYY-MM-DD expiry date as DD/MM/YYYY, leaving other values as they are.
+func formatExportDate(date *string) string {
+	if date == nil {
+		return ""
+	}
+	t, err := time.Parse("2006-01-02", dateOnly(*date))
+	if err != nil {
+		return *date
+	}
+	return t.Format("02/01/2006")
+}
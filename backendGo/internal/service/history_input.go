@@ -0,0 +1,335 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxClientHistoryBatch is the largest number of entries accepted in one client batch.
+	MaxClientHistoryBatch = 500
+	// maxClientClockSkew is how far a client-supplied date may be from the server clock.
+	// Entries are always stored with the server time; the date is only checked.
+	maxClientClockSkew = 5 * time.Minute
+)
+
+// Actions a client may record for each entity type. "quantity_adjusted" is reserved
+// for the consistency checker and cannot be submitted.
+var (
+	clientProductActions = map[string]bool{"created": true, "updated": true, "deleted": true,
+		"quantity_updated": true, "details_updated": true, "product_details_updated": true}
+	clientLoteActions = map[string]bool{"created": true, "updated": true, "deleted": true}
+)
+
+// HistoryValidationError reports why a client-submitted history entry was rejected.
+type HistoryValidationError struct {
+	Index  int // Position of the entry in the submitted batch
+	Reason string
+}
+
+func (e *HistoryValidationError) Error() string {
+	return fmt.Sprintf("entry %d: %s", e.Index, e.Reason)
+}
+
+// RecordClientEntries validates history entries submitted by a client and stores them as one
+// batch owned by the actor. Each entry's changes must match the schema of its entity type exactly,
+// and the entity must belong to the actor (or, once deleted, have history of the actor).
+// If batchID is empty a new one is generated, except for a single entry which uses its own ID.
+func (s *historyService) RecordClientEntries(inputs []models.HistoryEntryInput, actor models.Actor, batchID string) ([]models.History, error) {
+	if len(inputs) == 0 {
+		return nil, &HistoryValidationError{Reason: "no history entries provided"}
+	}
+	if len(inputs) > MaxClientHistoryBatch {
+		return nil, &HistoryValidationError{Index: MaxClientHistoryBatch, Reason: fmt.Sprintf("a batch accepts at most %d entries", MaxClientHistoryBatch)}
+	}
+
+	now := time.Now()
+	entries := make([]models.History, len(inputs))
+	for i, input := range inputs {
+		if input.Date != nil && (input.Date.Before(now.Add(-maxClientClockSkew)) || input.Date.After(now.Add(maxClientClockSkew))) {
+			return nil, &HistoryValidationError{Index: i, Reason: "date differs from the server clock; history dates are set by the server"}
+		}
+		changes, err := s.validateClientChanges(i, input, actor.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		entries[i] = newActorEntry(input.EntityType, input.EntityID, changes, actor, now)
+	}
+
+	stored := []models.History{}
+	if batchID == "" {
+		batchID = uuid.NewString()
+		if len(entries) == 1 {
+			batchID = entries[0].ID
+		}
+	} else {
+		var err error
+		if stored, err = s.repo.GetByBatchID(batchID, actor.UserID); err != nil {
+			return nil, fmt.Errorf("failed to load history batch %s: %w", batchID, err)
+		}
+	}
+	for i := range entries {
+		entries[i].BatchID = batchID
+	}
+	if err := checkBatchContexts(entries, stored); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateBatch(entries); err != nil {
+		return nil, fmt.Errorf("failed to store history entries: %w", err)
+	}
+	return entries, nil
+}
+
+// validateClientChanges decodes the changes of one entry with the schema of its entity type,
+// checks them and the ownership of the entity, and returns them re-encoded.
+func (s *historyService) validateClientChanges(index int, input models.HistoryEntryInput, userID int) (json.RawMessage, error) {
+	invalid := func(format string, args ...interface{}) error {
+		return &HistoryValidationError{Index: index, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	if input.EntityID == "" || len(input.EntityID) > 100 {
+		return nil, invalid("entityId is required and must have at most 100 characters")
+	}
+
+	var detail interface{}
+	switch input.EntityType {
+	case EntityTypeProduct:
+		var change models.ProductChange
+		if err := decodeStrict(input.Changes, &change); err != nil {
+			return nil, invalid("invalid product changes: %v", err)
+		}
+		if change.ProductID != input.EntityID {
+			return nil, invalid("changes.productId must equal entityId")
+		}
+		if !clientProductActions[change.Action] {
+			return nil, invalid("invalid product action %q", change.Action)
+		}
+		if err := checkQuantities(change.QuantityBefore, change.QuantityAfter, change.QuantityChanged); err != nil {
+			return nil, invalid("%v", err)
+		}
+		for _, field := range change.ChangedFields {
+			if field.Field == "" {
+				return nil, invalid("every changedFields item needs a field name")
+			}
+		}
+		if found, err := s.productOwned(change.ProductID, change.Action == "deleted", userID); err != nil {
+			return nil, err
+		} else if !found {
+			return nil, invalid("product %s not found", change.ProductID)
+		}
+		detail = change
+
+	case EntityTypeLote:
+		var change models.LoteChangeDetail
+		if err := decodeStrict(input.Changes, &change); err != nil {
+			return nil, invalid("invalid lote changes: %v", err)
+		}
+		if change.LoteID != input.EntityID {
+			return nil, invalid("changes.loteId must equal entityId")
+		}
+		if change.ProductID == "" {
+			return nil, invalid("changes.productId is required")
+		}
+		if !clientLoteActions[change.Action] {
+			return nil, invalid("invalid lote action %q", change.Action)
+		}
+		if err := checkQuantities(change.QuantityBefore, change.QuantityAfter, change.QuantityChanged); err != nil {
+			return nil, invalid("%v", err)
+		}
+		for _, date := range []*string{change.DataValidade, change.DataValidadeOld, change.DataValidadeNew, change.DataFabricacao} {
+			if date == nil {
+				continue
+			}
+			if _, err := time.Parse("2006-01-02", *date); err != nil {
+				return nil, invalid("invalid date %q, expected YYYY-MM-DD", *date)
+			}
+		}
+		if found, err := s.loteOwned(change.LoteID, change.ProductID, change.Action == "deleted", userID); err != nil {
+			return nil, err
+		} else if !found {
+			return nil, invalid("lote %s not found for product %s", change.LoteID, change.ProductID)
+		}
+		detail = change
+
+	case EntityTypeProductBatchContext:
+		var change models.ProductBatchContextChangeDetail
+		if err := decodeStrict(input.Changes, &change); err != nil {
+			return nil, invalid("invalid product batch context: %v", err)
+		}
+		if change.ProductID != input.EntityID {
+			return nil, invalid("changes.productId must equal entityId")
+		}
+		if change.ProductNameSnapshot == "" {
+			return nil, invalid("changes.productNameSnapshot is required")
+		}
+		if change.QuantityBeforeBatch < 0 || change.QuantityAfterBatch < 0 {
+			return nil, invalid("quantities must not be negative")
+		}
+		// The product may have been deleted by the batch this context describes.
+		if found, err := s.productOwned(change.ProductID, true, userID); err != nil {
+			return nil, err
+		} else if !found {
+			return nil, invalid("product %s not found", change.ProductID)
+		}
+		detail = change
+
+	default:
+		return nil, invalid("invalid entityType. Must be 'product', 'lote' or 'product_batch_context'")
+	}
+
+	changes, err := json.Marshal(detail)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal changes of entry %d: %w", index, err)
+	}
+	return changes, nil
+}
+
+// checkBatchContexts checks every product batch context among entries against the changes
+// of its product in the same batch, both those already stored and those submitted with it.
+// The live product quantity is not used: other members of the account may change it meanwhile.
+func checkBatchContexts(entries, stored []models.History) error {
+	batch := append(append([]models.History{}, stored...), entries...)
+	for i, entry := range entries {
+		if entry.EntityType != EntityTypeProductBatchContext {
+			continue
+		}
+		var context models.ProductBatchContextChangeDetail
+		if err := json.Unmarshal(entry.Changes, &context); err != nil {
+			return fmt.Errorf("failed to decode product batch context of entry %d: %w", i, err)
+		}
+
+		expected, found := batchQuantityAfter(batch, context.ProductID, context.QuantityBeforeBatch)
+		if !found {
+			return &HistoryValidationError{Index: i, Reason: fmt.Sprintf("the batch has no changes of product %s", context.ProductID)}
+		}
+		if !isZeroQuantity(context.QuantityAfterBatch - expected) {
+			return &HistoryValidationError{Index: i, Reason: fmt.Sprintf(
+				"quantityAfterBatch %.2f does not match the changes of product %s in the batch (%.2f)",
+				context.QuantityAfterBatch, context.ProductID, expected)}
+		}
+	}
+	return nil
+}
+
+// batchQuantityAfter applies the product and lote changes of a product found in a batch to
+// its quantity before the batch. Lote changes decide the quantity of a product that has
+// them, as the products.quantity trigger does, and a deleted product ends with none.
+func batchQuantityAfter(batch []models.History, productID string, before float64) (float64, bool) {
+	var productDelta, loteDelta float64
+	found, hasLotes, deleted := false, false, false
+	for _, entry := range batch {
+		switch entry.EntityType {
+		case EntityTypeLote:
+			var change models.LoteChangeDetail
+			if json.Unmarshal(entry.Changes, &change) != nil || change.ProductID != productID {
+				continue
+			}
+			found, hasLotes = true, true
+			loteDelta += quantityDelta(change.Action, change.QuantityBefore, change.QuantityAfter)
+		case EntityTypeProduct:
+			var change models.ProductChange
+			if entry.EntityID != productID || json.Unmarshal(entry.Changes, &change) != nil {
+				continue
+			}
+			found = true
+			deleted = change.Action == "deleted"
+			productDelta += quantityDelta(change.Action, change.QuantityBefore, change.QuantityAfter)
+		}
+	}
+	switch {
+	case deleted:
+		return 0, found
+	case hasLotes:
+		return before + loteDelta, found
+	default:
+		return before + productDelta, found
+	}
+}
+
+// quantityDelta is the quantity a single product or lote change adds.
+func quantityDelta(action string, before, after *float64) float64 {
+	switch {
+	case action == "created" && after != nil:
+		return *after
+	case action == "deleted" && before != nil:
+		return -*before
+	case before != nil && after != nil:
+		return *after - *before
+	}
+	return 0
+}
+
+// decodeStrict decodes data into v, rejecting unknown fields and trailing data.
+func decodeStrict(data json.RawMessage, v interface{}) error {
+	if len(data) == 0 {
+		return fmt.Errorf("changes are required")
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return fmt.Errorf("unexpected data after the changes object")
+	}
+	return nil
+}
+
+// checkQuantities rejects negative quantities and a quantityChanged that does not match before and after.
+func checkQuantities(before, after, changed *float64) error {
+	if (before != nil && *before < 0) || (after != nil && *after < 0) {
+		return fmt.Errorf("quantities must not be negative")
+	}
+	if before != nil && after != nil && changed != nil && !isZeroQuantity(*after-*before-*changed) {
+		return fmt.Errorf("quantityChanged must equal quantityAfter - quantityBefore")
+	}
+	return nil
+}
+
+// productOwned reports whether the product belongs to the user. When allowDeleted is true,
+// a product that no longer exists counts if the user has history for it.
+func (s *historyService) productOwned(productID string, allowDeleted bool, userID int) (bool, error) {
+	product, err := s.productRepo.GetByID(productID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check product %s: %w", productID, err)
+	}
+	if product != nil {
+		return true, nil
+	}
+	if !allowDeleted {
+		return false, nil
+	}
+	return s.hasHistory(EntityTypeProduct, productID, userID)
+}
+
+// loteOwned reports whether the lote belongs to the user and to the given product. When
+// allowDeleted is true, a lote that no longer exists counts if the user has history for it.
+func (s *historyService) loteOwned(loteID, productID string, allowDeleted bool, userID int) (bool, error) {
+	lote, err := s.loteRepo.GetByID(loteID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check lote %s: %w", loteID, err)
+	}
+	if lote != nil {
+		return lote.ProductID == productID, nil
+	}
+	if !allowDeleted {
+		return false, nil
+	}
+	return s.hasHistory(EntityTypeLote, loteID, userID)
+}
+
+// hasHistory reports whether the user has any history entry for the entity.
+func (s *historyService) hasHistory(entityType, entityID string, userID int) (bool, error) {
+	entries, err := s.repo.GetHistoryByEntity(entityType, entityID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check history of %s %s: %w", entityType, entityID, err)
+	}
+	return len(entries) > 0, nil
+}
@@ -2,12 +2,15 @@ package service
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
+	"unicode/utf8"
 
 	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
 	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/repository"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/utils"
 	"github.com/google/uuid"
 )
 
@@ -19,27 +22,33 @@ const (
 
 // HistoryService defines the interface for history operations
 type HistoryService interface {
-	RecordChange(entityType string, entityID string, changeDetail interface{}, userID int, operationBatchIDHeader ...string) error
-	GetHistory(limit, offset int, userID int) ([]models.History, error)
+	RecordChange(entityType string, entityID string, changeDetail interface{}, actor models.Actor, operationBatchIDHeader ...string) error
+	GetHistory(filter models.HistoryFilter, limit, offset int, userID int) ([]models.History, error)
+	GetAuditTrail(filter models.HistoryFilter, limit, offset int, userID int) ([]models.HistoryAuditEntry, error)
 	GetHistoryForEntity(entityType, entityID string, userID int) ([]models.History, error)
-	CreateRawHistoryEntry(entry models.History) error
-	CreateBatch(entries []models.History) (string, error)
+	RecordClientEntries(inputs []models.HistoryEntryInput, actor models.Actor, batchID string) ([]models.History, error)
 	GetByBatchID(batchID string, userID int) ([]models.History, error)
-	GetGroupedHistory(page, pageSize int, userID int) (*models.PaginatedHistoryBatchGroups, error)
+	GetGroupedHistory(filter models.HistoryFilter, page, pageSize int, userID int) (*models.PaginatedHistoryBatchGroups, error)
+	VerifyChain(userID int) (*models.HistoryChainVerification, error)
+	GetProductTimeline(productID string, from, to *time.Time, userID int) (*models.ProductTimeline, error)
+	ExportHistory(filter models.HistoryFilter, userID int, w utils.TableWriter) error
 }
 
 type historyService struct {
-	repo        repository.HistoryRepository
-	productRepo repository.ProductRepository // Added product repository
+	repo         repository.HistoryRepository
+	productRepo  repository.ProductRepository // Added product repository
+	loteRepo     repository.LoteRepository    // Ownership checks of client-submitted entries
+	archiveRepo  repository.HistoryArchiveRepository
+	snapshotRepo repository.StockSnapshotRepository
 }
 
 // NewHistoryService creates a new HistoryService
-func NewHistoryService(repo repository.HistoryRepository, productRepo repository.ProductRepository) HistoryService {
-	return &historyService{repo: repo, productRepo: productRepo}
+func NewHistoryService(repo repository.HistoryRepository, productRepo repository.ProductRepository, loteRepo repository.LoteRepository, archiveRepo repository.HistoryArchiveRepository, snapshotRepo repository.StockSnapshotRepository) HistoryService {
+	return &historyService{repo: repo, productRepo: productRepo, loteRepo: loteRepo, archiveRepo: archiveRepo, snapshotRepo: snapshotRepo}
 }
 
-// RecordChange creates a new history entry
-func (s *historyService) RecordChange(entityType string, entityID string, changeDetail interface{}, userID int, operationBatchIDHeader ...string) error {
+// RecordChange creates a new history entry owned by the actor, recording who made the change and from where
+func (s *historyService) RecordChange(entityType string, entityID string, changeDetail interface{}, actor models.Actor, operationBatchIDHeader ...string) error {
 	jsonData, err := json.Marshal(changeDetail)
 	if err != nil {
 		return fmt.Errorf("failed to marshal change detail: %w", err)
@@ -52,25 +61,61 @@ func (s *historyService) RecordChange(entityType string, entityID string, change
 		// If no batch ID from header, generate a new one for this single operation
 		// or use the history entry's own ID as its batch ID.
 		// For now, let's ensure every record has a BatchID, defaulting to its own ID if not part of a larger client-defined batch.
-		// This will be overridden if CreateRawHistoryEntry is called with an explicit BatchID.
+		// createEntry defaults it to the entry's own ID.
 	}
 
-	historyEntry := models.History{
-		ID:         uuid.NewString(),
-		Date:       time.Now().Format(time.RFC3339),
-		EntityType: entityType,
-		EntityID:   entityID,
-		UserID:     userID,
-		Changes:    jsonData,
-		BatchID:    batchID, // Will be set properly by CreateRawHistoryEntry
+	historyEntry := newActorEntry(entityType, entityID, jsonData, actor, time.Now())
+	historyEntry.BatchID = batchID // Will be set properly by createEntry
+
+	return s.createEntry(historyEntry)
+}
+
+// newActorEntry builds a history entry owned by the actor and recording who made the change.
+// The caller sets the BatchID.
+func newActorEntry(entityType, entityID string, changes json.RawMessage, actor models.Actor, date time.Time) models.History {
+	return models.History{
+		ID:            uuid.NewString(),
+		Date:          date,
+		EntityType:    entityType,
+		EntityID:      entityID,
+		UserID:        actor.UserID,
+		Changes:       changes,
+		ActorUserID:   &actor.ActorUserID,
+		ActorUsername: truncate(actor.Username, 255),
+		ClientIP:      truncate(actor.ClientIP, 64),
+		UserAgent:     truncate(actor.UserAgent, 512),
+		RequestID:     truncate(actor.RequestID, 64),
+	}
+}
+
+// truncate cuts s to at most max bytes, without splitting a UTF-8 character, to fit its column.
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
 	}
+	return s[:max]
+}
 
-	return s.CreateRawHistoryEntry(historyEntry)
+// GetHistory retrieves a paginated list of the history entries matching the filter
+func (s *historyService) GetHistory(filter models.HistoryFilter, limit, offset int, userID int) ([]models.History, error) {
+	return s.repo.GetHistory(filter, limit, offset, userID)
 }
 
-// GetHistory retrieves a paginated list of all history entries
-func (s *historyService) GetHistory(limit, offset int, userID int) ([]models.History, error) {
-	return s.repo.GetHistory(limit, offset, userID)
+// GetAuditTrail retrieves a paginated list of the history entries matching the filter with their
+// hash chain links.
+func (s *historyService) GetAuditTrail(filter models.HistoryFilter, limit, offset int, userID int) ([]models.HistoryAuditEntry, error) {
+	entries, err := s.repo.GetHistory(filter, limit, offset, userID)
+	if err != nil {
+		return nil, err
+	}
+	audit := make([]models.HistoryAuditEntry, len(entries))
+	for i, entry := range entries {
+		audit[i] = models.NewHistoryAuditEntry(entry)
+	}
+	return audit, nil
 }
 
 // GetHistoryForEntity retrieves history for a specific entity
@@ -78,47 +123,29 @@ func (s *historyService) GetHistoryForEntity(entityType, entityID string, userID
 	return s.repo.GetHistoryByEntity(entityType, entityID, userID)
 }
 
-// CreateRawHistoryEntry directly creates a history entry in the database.
+// createEntry stores a history entry built by the service.
 // If entry.BatchID is empty, it defaults to entry.ID.
-func (s *historyService) CreateRawHistoryEntry(entry models.History) error {
+func (s *historyService) createEntry(entry models.History) error {
 	if entry.ID == "" {
 		entry.ID = uuid.NewString()
 	}
 	if entry.BatchID == "" {
 		entry.BatchID = entry.ID // Default BatchID to the record's own ID if not provided
 	}
-	if entry.Date == "" {
-		entry.Date = time.Now().Format(time.RFC3339)
+	if entry.Date.IsZero() {
+		entry.Date = time.Now()
 	}
 	return s.repo.Create(&entry)
 }
 
-// CreateBatch creates multiple history entries with a shared, new batch ID.
-func (s *historyService) CreateBatch(entries []models.History) (string, error) {
-	if len(entries) == 0 {
-		return "", fmt.Errorf("no entries provided for batch creation")
-	}
-	batchID := uuid.NewString()
-	for i := range entries {
-		entries[i].BatchID = batchID
-		if entries[i].ID == "" {
-			entries[i].ID = uuid.NewString()
-		}
-		if entries[i].Date == "" {
-			entries[i].Date = time.Now().Format(time.RFC3339)
-		}
-	}
-	return batchID, s.repo.CreateBatch(entries)
-}
-
 // GetByBatchID retrieves all history entries for a specific batch ID.
 func (s *historyService) GetByBatchID(batchID string, userID int) ([]models.History, error) {
 	return s.repo.GetByBatchID(batchID, userID)
 }
 
 // GetGroupedHistory retrieves history entries grouped by batch ID, with pagination for batches.
-func (s *historyService) GetGroupedHistory(page, pageSize int, userID int) (*models.PaginatedHistoryBatchGroups, error) {
-	paginatedRawGroups, err := s.repo.GetGroupedHistoryBatches(page, pageSize, userID)
+func (s *historyService) GetGroupedHistory(filter models.HistoryFilter, page, pageSize int, userID int) (*models.PaginatedHistoryBatchGroups, error) {
+	paginatedRawGroups, err := s.repo.GetGroupedHistoryBatches(filter, page, pageSize, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get grouped history batches from repo: %w", err)
 	}
@@ -214,3 +241,193 @@ func (s *historyService) GetGroupedHistory(page, pageSize int, userID int) (*mod
 		TotalPages:   paginatedRawGroups.TotalPages,
 	}, nil
 }
+
+// errChainBroken stops the chain walk at the first broken link.
+var errChainBroken = errors.New("history chain broken")
+
+// VerifyChain walks the user's history hash chain in sequence order and reports the first broken link:
+// a gap in the sequence, a prev_hash that does not match the previous entry, a hash that does not match
+// the entry's content, or a chain head that does not match the last entry.
+func (s *historyService) VerifyChain(userID int) (*models.HistoryChainVerification, error) {
+	result := &models.HistoryChainVerification{UserID: userID, VerifiedAt: time.Now()}
+
+	headSeq, headHash, err := s.repo.GetChainHead(userID)
+	if err != nil {
+		return nil, err
+	}
+	result.HeadSeq = headSeq
+
+	if result.UnchainedEntries, err = s.repo.CountUnchained(userID); err != nil {
+		return nil, err
+	}
+
+	// Archived entries only keep their links: those are checked for continuity, their content
+	// is covered by the checksum of the archive file.
+	links, err := s.archiveRepo.GetArchivedLinks(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var prevSeq int64
+	prevHash := ""
+	// followLinks advances past the archived links below seq, reporting the first one that does not follow.
+	followLinks := func(seq int64) bool {
+		for len(links) > 0 && links[0].Seq < seq {
+			link := links[0]
+			links = links[1:]
+			if link.Seq != prevSeq+1 || link.PrevHash != prevHash {
+				result.FirstBrokenLink = &models.HistoryChainBreak{
+					Seq:    link.Seq,
+					Reason: fmt.Sprintf("archived link %d does not follow seq %d", link.Seq, prevSeq),
+				}
+				return false
+			}
+			result.ArchivedLinks++
+			prevSeq, prevHash = link.Seq, link.Hash
+		}
+		return true
+	}
+
+	walkErr := s.repo.WalkChain(userID, func(entry models.History) error {
+		fail := func(reason string) error {
+			result.FirstBrokenLink = &models.HistoryChainBreak{Seq: entry.Seq, HistoryID: entry.ID, Reason: reason}
+			return errChainBroken
+		}
+
+		if !followLinks(entry.Seq) {
+			return errChainBroken
+		}
+		if entry.Seq != prevSeq+1 {
+			return fail(fmt.Sprintf("expected seq %d, found %d: entries are missing", prevSeq+1, entry.Seq))
+		}
+		if entry.PrevHash != prevHash {
+			return fail("prev_hash does not match the hash of the previous entry")
+		}
+		expected, err := repository.HistoryEntryHash(entry.PrevHash, entry)
+		if err != nil {
+			return fail(err.Error())
+		}
+		if expected != entry.Hash {
+			return fail("hash does not match the entry content: the entry was modified")
+		}
+
+		result.CheckedEntries++
+		prevSeq, prevHash = entry.Seq, entry.Hash
+		return nil
+	})
+	if walkErr != nil && !errors.Is(walkErr, errChainBroken) {
+		return nil, fmt.Errorf("failed to walk history chain: %w", walkErr)
+	}
+
+	if result.FirstBrokenLink == nil && followLinks(headSeq+1) {
+		if prevSeq != headSeq {
+			result.FirstBrokenLink = &models.HistoryChainBreak{
+				Seq:    prevSeq + 1,
+				Reason: fmt.Sprintf("chain ends at seq %d but its head records seq %d: trailing entries were removed", prevSeq, headSeq),
+			}
+		} else if prevHash != headHash {
+			result.FirstBrokenLink = &models.HistoryChainBreak{
+				Seq:    prevSeq,
+				Reason: "hash of the last entry does not match the chain head",
+			}
+		}
+	}
+	result.Valid = result.FirstBrokenLink == nil
+	return result, nil
+}
+
+// GetProductTimeline merges the product's own entries, its batch contexts and the entries of all
+// its lotes. The running quantity is replayed from the whole retained history, starting from the
+// snapshot at the archive cutoff if older history was archived; from and to (optional) only
+// limit the events returned. It returns nil if the product has neither stock nor history.
+func (s *historyService) GetProductTimeline(productID string, from, to *time.Time, userID int) (*models.ProductTimeline, error) {
+	product, err := s.productRepo.GetByID(productID, userID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.repo.GetProductHistory(productID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if product == nil && len(entries) == 0 {
+		return nil, nil
+	}
+
+	timeline := &models.ProductTimeline{ProductID: productID, Events: []models.ProductTimelineEvent{}}
+	if product != nil {
+		timeline.ProductName = product.Name
+		timeline.CurrentQuantity = &product.Quantity
+	}
+
+	// Archived history is replaced by the snapshot taken at the archive cutoff
+	state, base, err := replayBase(s.archiveRepo, s.snapshotRepo, userID)
+	if err != nil {
+		return nil, err
+	}
+	if product != nil && state.products[productID] == nil && !hasCreationEntry(entries, base) {
+		// The product predates its history, so it starts from its current stock with the
+		// entries undone back to the first one
+		state = stateFromProducts([]models.Product{*product})
+		for i := len(entries) - 1; i >= 0; i-- {
+			if base.IsZero() || entries[i].Date.After(base) {
+				state.undo(entries[i])
+			}
+		}
+	}
+	quantity := 0.0
+	if replayed := state.products[productID]; replayed != nil {
+		quantity = replayed.quantity
+	}
+	for _, entry := range entries {
+		if base.IsZero() || entry.Date.After(base) {
+			state.apply(entry)
+		}
+		before := quantity
+		quantity = 0
+		if replayed := state.products[productID]; replayed != nil {
+			quantity = replayed.quantity
+			if timeline.ProductName == "" {
+				timeline.ProductName = replayed.name
+			}
+		}
+
+		if (from != nil && entry.Date.Before(*from)) || (to != nil && entry.Date.After(*to)) {
+			continue
+		}
+		var detail struct {
+			Action string `json:"action"`
+		}
+		if err := json.Unmarshal(entry.Changes, &detail); err != nil {
+			log.Printf("WARN: product timeline - failed to unmarshal action of %s: %v", entry.ID, err)
+		}
+		timeline.Events = append(timeline.Events, models.ProductTimelineEvent{
+			HistoryID:      entry.ID,
+			Date:           entry.Date,
+			BatchID:        entry.BatchID,
+			EntityType:     entry.EntityType,
+			EntityID:       entry.EntityID,
+			Action:         detail.Action,
+			QuantityChange: quantity - before,
+			QuantityAfter:  quantity,
+			ActorUsername:  entry.ActorUsername,
+			Changes:        entry.Changes,
+		})
+	}
+	return timeline, nil
+}
+
+// hasCreationEntry reports whether the product history after base includes the product's creation.
+func hasCreationEntry(entries []models.History, base time.Time) bool {
+	for _, entry := range entries {
+		if entry.EntityType != EntityTypeProduct || (!base.IsZero() && !entry.Date.After(base)) {
+			continue
+		}
+		var detail struct {
+			Action string `json:"action"`
+		}
+		if json.Unmarshal(entry.Changes, &detail) == nil && detail.Action == "created" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/repository"
+)
+
+// The fakes embed the repository interfaces; only the methods used by the timeline are implemented.
+type timelineHistoryRepo struct {
+	repository.HistoryRepository
+	entries []models.History
+}
+
+func (r *timelineHistoryRepo) GetProductHistory(productID string, userID int) ([]models.History, error) {
+	return r.entries, nil
+}
+
+type timelineProductRepo struct {
+	repository.ProductRepository
+	product *models.Product
+}
+
+func (r *timelineProductRepo) GetByID(id string, userID int) (*models.Product, error) {
+	return r.product, nil
+}
+
+type timelineArchiveRepo struct {
+	repository.HistoryArchiveRepository
+}
+
+func (r *timelineArchiveRepo) GetCutoff(userID int) (time.Time, error) {
+	return time.Time{}, nil
+}
+
+func productEntry(t *testing.T, id string, date time.Time, change models.ProductChange) models.History {
+	t.Helper()
+	changes, err := json.Marshal(change)
+	if err != nil {
+		t.Fatalf("marshal changes: %v", err)
+	}
+	return models.History{ID: id, EntityType: EntityTypeProduct, EntityID: change.ProductID, Changes: changes, Date: date, BatchID: id}
+}
+
+func TestGetProductTimelineWithoutCreationEntry(t *testing.T) {
+	quantity := func(q float64) *float64 { return &q }
+	start := time.Date(2026, 9, 1, 10, 0, 0, 0, time.UTC)
+	entries := []models.History{
+		productEntry(t, "h1", start, models.ProductChange{ProductID: "p1", Action: "quantity_updated", QuantityBefore: quantity(5), QuantityAfter: quantity(10)}),
+		productEntry(t, "h2", start.Add(time.Hour), models.ProductChange{ProductID: "p1", Action: "quantity_updated", QuantityBefore: quantity(10), QuantityAfter: quantity(8)}),
+	}
+	svc := NewHistoryService(
+		&timelineHistoryRepo{entries: entries},
+		&timelineProductRepo{product: &models.Product{ID: "p1", Name: "Cimento", Unit: "kg", Quantity: 8}},
+		nil, &timelineArchiveRepo{}, nil)
+
+	timeline, err := svc.GetProductTimeline("p1", nil, nil, 1)
+	if err != nil {
+		t.Fatalf("GetProductTimeline: %v", err)
+	}
+	if len(timeline.Events) != 2 {
+		t.Fatalf("got %d events, want 2", len(timeline.Events))
+	}
+	want := []struct{ change, after float64 }{{5, 10}, {-2, 8}}
+	for i, event := range timeline.Events {
+		if event.QuantityChange != want[i].change || event.QuantityAfter != want[i].after {
+			t.Errorf("event %d: change %.2f after %.2f, want change %.2f after %.2f",
+				i, event.QuantityChange, event.QuantityAfter, want[i].change, want[i].after)
+		}
+	}
+}
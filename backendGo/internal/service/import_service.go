@@ -0,0 +1,399 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/repository"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/utils"
+	"github.com/google/uuid"
+)
+
+// MaxImportRows is the largest number of data rows accepted in one import.
+const MaxImportRows = 5000
+
+// ErrInvalidImportFile is returned when the uploaded file cannot be read as a product spreadsheet.
+var ErrInvalidImportFile = errors.New("invalid import file")
+
+// importUnits maps the accepted spellings of a unit to the unit stored on the product.
+var importUnits = map[string]string{"l": "L", "kg": "kg"}
+
+// ImportService creates products and lotes from a spreadsheet in the layout of the product export.
+type ImportService interface {
+	Import(data []byte, dryRun bool, actor models.Actor, batchID string) (*models.ImportResult, error)
+}
+
+type importService struct {
+	productRepo repository.ProductRepository
+	loteRepo    repository.LoteRepository
+	historyRepo repository.HistoryRepository
+	db          *sql.DB // For transactions
+}
+
+// NewImportService creates a new ImportService
+func NewImportService(productRepo repository.ProductRepository, loteRepo repository.LoteRepository, historyRepo repository.HistoryRepository, db *sql.DB) ImportService {
+	return &importService{productRepo: productRepo, loteRepo: loteRepo, historyRepo: historyRepo, db: db}
+}
+
+// importColumns locates the columns of the spreadsheet by their heading.
+type importColumns map[string]int
+
+func (c importColumns) get(row []string, heading string) string {
+	i, ok := c[heading]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
+
+// Import validates every row of the spreadsheet and, unless dryRun is set or a row is invalid,
+// creates its products and lotes in one transaction, recorded as one history batch. Each "Produto"
+// row is followed by its "Lote" rows; a product without lotes gets "Qtd Total Produto" as quantity.
+// If batchID is empty a new one is generated.
+func (s *importService) Import(data []byte, dryRun bool, actor models.Actor, batchID string) (*models.ImportResult, error) {
+	rows, err := utils.ReadTable(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+	}
+	columns, err := importHeader(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows)-1 > MaxImportRows {
+		return nil, fmt.Errorf("%w: at most %d rows can be imported at once", ErrInvalidImportFile, MaxImportRows)
+	}
+
+	existing, err := s.productRepo.GetAll(actor.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get products for import: %w", err)
+	}
+	result := s.validate(rows, columns, existing, actor.UserID)
+	result.DryRun = dryRun
+	if dryRun || len(result.Errors) > 0 {
+		return result, nil
+	}
+
+	if batchID == "" {
+		batchID = uuid.NewString()
+	}
+	if err := s.apply(result.Products, actor, batchID); err != nil {
+		return nil, err
+	}
+	result.Applied = true
+	result.BatchID = batchID
+	return result, nil
+}
+
+// importHeader finds the header row (the first one) and checks its required headings.
+func importHeader(rows [][]string) (importColumns, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: the file is empty", ErrInvalidImportFile)
+	}
+	columns := importColumns{}
+	for i, heading := range rows[0] {
+		heading = strings.Join(strings.Fields(heading), " ")
+		for _, known := range ProductExportHeaders {
+			if strings.EqualFold(heading, known) {
+				columns[known] = i
+			}
+		}
+	}
+	var missing []string
+	for _, required := range []string{"Tipo", "Nome Produto", "Unidade Produto"} {
+		if _, ok := columns[required]; !ok {
+			missing = append(missing, required)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: missing columns %s; expected the headings %s", ErrInvalidImportFile,
+			strings.Join(missing, ", "), strings.Join(ProductExportHeaders, ", "))
+	}
+	return columns, nil
+}
+
+// validate checks every row and builds the products to create, with their lotes.
+func (s *importService) validate(rows [][]string, columns importColumns, existing []models.Product, userID int) *models.ImportResult {
+	result := &models.ImportResult{Errors: []models.ImportRowError{}, Products: []models.Product{}}
+	addError := func(row int, column, format string, args ...interface{}) {
+		result.Errors = append(result.Errors, models.ImportRowError{Row: row, Column: column, Message: fmt.Sprintf(format, args...)})
+	}
+
+	existingNames := make(map[string]bool, len(existing))
+	for _, p := range existing {
+		existingNames[importNameKey(p.Name)] = true
+	}
+	type importedProduct struct {
+		index    int      // Position in result.Products, -1 if the row was invalid
+		row      int      // Spreadsheet row of the product
+		total    *float64 // "Qtd Total Produto", if given
+		lotesSum float64
+	}
+	products := map[string]*importedProduct{} // By name key, including invalid rows
+	var current *importedProduct
+
+	for i, row := range rows[1:] {
+		rowNum := i + 2
+		tipo := columns.get(row, "Tipo")
+		name := columns.get(row, "Nome Produto")
+		if isBlankRow(row) {
+			continue
+		}
+
+		switch strings.ToLower(tipo) {
+		case "produto":
+			product := &importedProduct{index: -1, row: rowNum}
+			current = product
+			valid := true
+
+			key := importNameKey(name)
+			switch {
+			case name == "":
+				addError(rowNum, "Nome Produto", "product name is required")
+				valid = false
+			case utf8.RuneCountInString(name) > 100:
+				addError(rowNum, "Nome Produto", "product name must have at most 100 characters")
+				valid = false
+			case products[key] != nil:
+				addError(rowNum, "Nome Produto", "duplicate product name %q, also on row %d", name, products[key].row)
+				valid = false
+			case existingNames[key]:
+				addError(rowNum, "Nome Produto", "a product named %q already exists", name)
+				valid = false
+			}
+			if key != "" && products[key] == nil {
+				products[key] = product
+			}
+
+			unitValue := columns.get(row, "Unidade Produto")
+			unit, ok := importUnits[strings.ToLower(unitValue)]
+			if !ok {
+				addError(rowNum, "Unidade Produto", "unknown unit %q, must be 'L' or 'kg'", unitValue)
+				valid = false
+			}
+
+			if value := columns.get(row, "Qtd Total Produto"); value != "" {
+				total, err := parseImportNumber(value)
+				if err != nil || total < 0 {
+					addError(rowNum, "Qtd Total Produto", "invalid quantity %q, must be a number not below zero", value)
+					valid = false
+				} else {
+					product.total = &total
+				}
+			}
+
+			if valid {
+				product.index = len(result.Products)
+				result.Products = append(result.Products, models.Product{
+					ID:     uuid.NewString(),
+					Name:   strings.Join(strings.Fields(name), " "),
+					Unit:   unit,
+					UserID: userID,
+					Lotes:  []models.Lote{},
+				})
+			}
+
+		case "lote":
+			product := current
+			if name != "" {
+				// A named lote row belongs to that product, which must come before it.
+				product = products[importNameKey(name)]
+			}
+			if product == nil {
+				if name != "" {
+					addError(rowNum, "Nome Produto", "product %q is not on a row before this lote", name)
+				} else {
+					addError(rowNum, "Nome Produto", "lote row without a product row before it")
+				}
+				continue
+			}
+			valid := true
+
+			value := columns.get(row, "Qtd Lote")
+			quantity, err := parseImportNumber(value)
+			if err != nil || quantity <= 0 {
+				addError(rowNum, "Qtd Lote", "invalid lote quantity %q, must be a number above zero", value)
+				valid = false
+			}
+			value = columns.get(row, "Validade Lote")
+			validade, err := parseImportDate(value)
+			if err != nil {
+				addError(rowNum, "Validade Lote", "invalid data_validade %q, expected DD/MM/YYYY or YYYY-MM-DD", value)
+				valid = false
+			}
+
+			if valid {
+				product.lotesSum += quantity
+				if product.index >= 0 {
+					p := &result.Products[product.index]
+					p.Lotes = append(p.Lotes, models.Lote{ProductID: p.ID, UserID: userID, Quantity: quantity, DataValidade: validade})
+				}
+			}
+
+		default:
+			addError(rowNum, "Tipo", "invalid type %q, must be 'Produto' or 'Lote'", tipo)
+		}
+	}
+
+	// A product's quantity is the sum of its lotes; a stated total must agree with it.
+	for _, product := range products {
+		if product.index < 0 {
+			continue
+		}
+		p := &result.Products[product.index]
+		if len(p.Lotes) == 0 {
+			if product.total != nil {
+				p.Quantity = *product.total
+			}
+			continue
+		}
+		p.Quantity = product.lotesSum
+		if product.total != nil && !isZeroQuantity(*product.total-product.lotesSum) {
+			addError(product.row, "Qtd Total Produto", "total %g differs from the sum of the product's lotes (%g)", *product.total, product.lotesSum)
+		}
+	}
+	if len(result.Products) == 0 && len(result.Errors) == 0 {
+		addError(1, "", "the file has no product rows")
+	}
+	sort.SliceStable(result.Errors, func(i, j int) bool { return result.Errors[i].Row < result.Errors[j].Row })
+
+	for _, p := range result.Products {
+		result.LotesCount += len(p.Lotes)
+	}
+	result.ProductsCount = len(result.Products)
+	return result
+}
+
+// apply creates the products and lotes and their history in one transaction.
+func (s *importService) apply(products []models.Product, actor models.Actor, batchID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Rollback if not committed
+
+	now := time.Now()
+	var entries []models.History
+	record := func(entityType, entityID string, detail interface{}) error {
+		changes, err := json.Marshal(detail)
+		if err != nil {
+			return fmt.Errorf("failed to marshal change detail: %w", err)
+		}
+		entry := newActorEntry(entityType, entityID, changes, actor, now)
+		entry.BatchID = batchID
+		entries = append(entries, entry)
+		return nil
+	}
+
+	for i := range products {
+		p := &products[i]
+		// Lote inserts bring the product quantity up to their sum through the database trigger.
+		initial := p.Quantity
+		if len(p.Lotes) > 0 {
+			initial = 0
+		}
+		product := *p
+		product.Quantity = initial
+		if err := s.productRepo.CreateTx(tx, &product); err != nil {
+			return fmt.Errorf("failed to import product %q: %w", p.Name, err)
+		}
+		if err := record(EntityTypeProduct, p.ID, models.ProductChange{
+			ProductID:     p.ID,
+			ProductName:   p.Name,
+			Unit:          p.Unit,
+			Action:        "created",
+			QuantityAfter: &initial,
+			IsNewProduct:  true,
+		}); err != nil {
+			return err
+		}
+
+		for j := range p.Lotes {
+			lote := &p.Lotes[j]
+			if err := s.loteRepo.Create(tx, lote); err != nil {
+				return fmt.Errorf("failed to import lote of product %q: %w", p.Name, err)
+			}
+			quantity, validade := lote.Quantity, lote.DataValidade
+			if err := record(EntityTypeLote, lote.ID, models.LoteChangeDetail{
+				LoteID:        lote.ID,
+				ProductID:     p.ID,
+				Action:        "created",
+				QuantityAfter: &quantity,
+				DataValidade:  &validade,
+			}); err != nil {
+				return err
+			}
+		}
+
+		if err := record(EntityTypeProductBatchContext, p.ID, models.ProductBatchContextChangeDetail{
+			ProductID:           p.ID,
+			ProductNameSnapshot: p.Name,
+			QuantityBeforeBatch: 0,
+			QuantityAfterBatch:  p.Quantity,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := s.historyRepo.CreateBatchTx(tx, entries); err != nil {
+		return fmt.Errorf("failed to record import batch: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// importNameKey normalizes a product name for duplicate detection.
+func importNameKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+func isBlankRow(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// parseImportNumber parses a quantity written with '.' or, pt-BR style, ',' as decimal separator
+// (in which case '.' is a thousands separator).
+func parseImportNumber(value string) (float64, error) {
+	if strings.Contains(value, ",") {
+		value = strings.ReplaceAll(value, ".", "")
+		value = strings.Replace(value, ",", ".", 1)
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+		return 0, fmt.Errorf("invalid number %q", value)
+	}
+	return n, nil
+}
+
+// parseImportDate parses an expiry date written as DD/MM/YYYY (the frontend export), YYYY-MM-DD,
+// or stored by a spreadsheet as a date serial number, and returns it as YYYY-MM-DD.
+func parseImportDate(value string) (string, error) {
+	for _, layout := range []string{"02/01/2006", "2/1/2006", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format("2006-01-02"), nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Format("2006-01-02"), nil
+	}
+	// Serial numbers between 1900-01-01 and 9999-12-31.
+	if serial, err := strconv.ParseFloat(value, 64); err == nil && serial >= 1 && serial < 2958466 {
+		return utils.ExcelSerialToDate(serial).Format("2006-01-02"), nil
+	}
+	return "", fmt.Errorf("invalid date %q", value)
+}
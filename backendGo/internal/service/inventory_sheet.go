@@ -0,0 +1,256 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/repository"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/utils"
+)
+
+// InventorySheetService prints the stock as a PDF inventory sheet, to be counted and signed
+type InventorySheetService interface {
+	WriteInventoryPDF(actor models.Actor, w io.Writer) error
+}
+
+type inventorySheetService struct {
+	productRepo repository.ProductRepository
+	paramsRepo  repository.ProductParametersRepository
+}
+
+// NewInventorySheetService creates a new InventorySheetService
+func NewInventorySheetService(productRepo repository.ProductRepository, paramsRepo repository.ProductParametersRepository) InventorySheetService {
+	return &inventorySheetService{productRepo: productRepo, paramsRepo: paramsRepo}
+}
+
+// Layout of the inventory sheet, in points.
+const (
+	sheetMargin     = 36.0
+	sheetRowHeight  = 14.0
+	sheetFontSize   = 8.5
+	sheetHeaderSize = 14.0
+	sheetBodyTop    = 104.0                      // Baseline of the first row below the column headings
+	sheetBodyBottom = utils.PDFPageHeight - 50.0 // Rows stop above the footer
+)
+
+// Columns of the inventory sheet: their left edge and width. Quantities are right-aligned on their
+// column, and the count column is left blank for the person counting the stock.
+var sheetColumns = struct {
+	product, location, expiry, quantity, unit, count [2]float64
+}{
+	product:  [2]float64{sheetMargin, 200},
+	location: [2]float64{240, 100},
+	expiry:   [2]float64{344, 56},
+	quantity: [2]float64{404, 66},
+	unit:     [2]float64{476, 22},
+	count:    [2]float64{502, utils.PDFPageWidth - sheetMargin - 502},
+}
+
+// inventorySheet lays out the rows of the sheet, starting pages as they fill up.
+type inventorySheet struct {
+	doc         *utils.PDFDocument
+	y           float64
+	generatedAt time.Time
+	username    string
+}
+
+// WriteInventoryPDF writes an A4 inventory sheet with every product of the actor, by name, each
+// followed by its lotes by expiry date, with storage locations, a blank column to write the counted
+// quantity, totals per unit and lines to sign. Every page repeats the header, with the time of
+// generation and the user, and is numbered.
+func (s *inventorySheetService) WriteInventoryPDF(actor models.Actor, w io.Writer) error {
+	products, err := s.productRepo.GetAll(actor.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get products for the inventory sheet: %w", err)
+	}
+	params, err := s.paramsRepo.List(actor.UserID)
+	if err != nil {
+		return err
+	}
+	paramsByProduct := make(map[string]models.ProductParameters)
+	for _, p := range params {
+		paramsByProduct[p.ProductID] = p
+	}
+	sort.SliceStable(products, func(i, j int) bool {
+		return strings.ToLower(products[i].Name) < strings.ToLower(products[j].Name)
+	})
+
+	now := time.Now()
+	today := now.Format("2006-01-02")
+	sheet := &inventorySheet{doc: utils.NewPDFDocument("Inventário de Estoque"), generatedAt: now, username: actor.Username}
+	sheet.newPage()
+
+	type unitTotal struct {
+		products, lotes int
+		quantity, value float64
+		valued          bool
+	}
+	totals := make(map[string]*unitTotal)
+	for _, p := range products {
+		total, ok := totals[p.Unit]
+		if !ok {
+			total = &unitTotal{}
+			totals[p.Unit] = total
+		}
+		total.products++
+		total.lotes += len(p.Lotes)
+		total.quantity += p.Quantity
+		param := paramsByProduct[p.ID]
+		if param.UnitCost != nil {
+			total.value += *param.UnitCost * p.Quantity
+			total.valued = true
+		}
+
+		// Keep a product with its first lotes, rather than alone at the bottom of a page
+		sheet.ensureSpace(sheetRowHeight * float64(1+min(len(p.Lotes), 2)))
+		sheet.doc.FillRect(sheetMargin, sheet.y-sheetRowHeight+3.5, utils.PDFPageWidth-2*sheetMargin, sheetRowHeight, 0.93)
+		location := ""
+		if param.Location != nil {
+			location = *param.Location
+		}
+		sheet.row(true, p.Name, location, "", formatSheetQuantity(p.Quantity), p.Unit, len(p.Lotes) == 0)
+
+		lotes := append([]models.Lote(nil), p.Lotes...)
+		sort.SliceStable(lotes, func(i, j int) bool { return dateOnly(lotes[i].DataValidade) < dateOnly(lotes[j].DataValidade) })
+		for i, l := range lotes {
+			sheet.ensureSpace(sheetRowHeight)
+			validade := dateOnly(l.DataValidade)
+			label := fmt.Sprintf("    Lote %d", i+1)
+			if validade < today {
+				label += " (vencido)"
+			}
+			sheet.row(false, label, "", formatExportDate(&validade), formatSheetQuantity(l.Quantity), p.Unit, true)
+		}
+	}
+	if len(products) == 0 {
+		sheet.doc.Text(sheetMargin, sheet.y, sheetFontSize, false, "Nenhum produto cadastrado.")
+		sheet.y += sheetRowHeight
+	}
+
+	// Totals per unit
+	units := make([]string, 0, len(totals))
+	for unit := range totals {
+		units = append(units, unit)
+	}
+	sort.Strings(units)
+	sheet.ensureSpace(sheetRowHeight * float64(2+len(units)))
+	sheet.y += sheetRowHeight / 2
+	sheet.doc.Line(sheetMargin, sheet.y-sheetRowHeight+4, utils.PDFPageWidth-sheetMargin, sheet.y-sheetRowHeight+4, 0.8)
+	sheet.doc.Text(sheetMargin, sheet.y, sheetFontSize+1, true, "Totais")
+	sheet.y += sheetRowHeight
+	for _, unit := range units {
+		total := totals[unit]
+		text := fmt.Sprintf("%d produtos, %d lotes", total.products, total.lotes)
+		if total.valued {
+			text += ", valor em estoque R$ " + formatSheetMoney(total.value) + " (produtos com custo unitário)"
+		}
+		sheet.doc.Text(sheetMargin+10, sheet.y, sheetFontSize, false, utils.PDFFitText(text, sheetFontSize, false, sheetColumns.quantity[0]-sheetMargin-10))
+		sheet.doc.TextRight(sheetColumns.quantity[0]+sheetColumns.quantity[1], sheet.y, sheetFontSize, true, formatSheetQuantity(total.quantity))
+		sheet.doc.Text(sheetColumns.unit[0], sheet.y, sheetFontSize, true, unit)
+		sheet.y += sheetRowHeight
+	}
+
+	// Signatures
+	sheet.ensureSpace(90)
+	sheet.y += 40
+	half := (utils.PDFPageWidth - 2*sheetMargin - 30) / 2
+	for i, label := range []string{"Responsável pela contagem", "Conferido por"} {
+		x := sheetMargin + float64(i)*(half+30)
+		sheet.doc.Line(x, sheet.y, x+half, sheet.y, 0.6)
+		sheet.doc.Text(x, sheet.y+11, sheetFontSize, false, label)
+		sheet.doc.Text(x, sheet.y+34, sheetFontSize, false, "Data: ____/____/________")
+	}
+
+	// Number the pages now that their count is known
+	pages := sheet.doc.PageCount()
+	for page := 1; page <= pages; page++ {
+		sheet.doc.SetPage(page)
+		sheet.doc.TextRight(utils.PDFPageWidth-sheetMargin, utils.PDFPageHeight-28, sheetFontSize-1, false, fmt.Sprintf("Página %d de %d", page, pages))
+	}
+	if _, err := sheet.doc.WriteTo(w); err != nil {
+		return fmt.Errorf("failed to write inventory sheet: %w", err)
+	}
+	return nil
+}
+
+// newPage starts a page with the header and the column headings.
+func (s *inventorySheet) newPage() {
+	doc := s.doc
+	doc.AddPage()
+	right := utils.PDFPageWidth - sheetMargin
+	doc.Text(sheetMargin, 50, sheetHeaderSize, true, "Inventário de Estoque")
+	doc.TextRight(right, 50, sheetFontSize, false, "Gerado em "+s.generatedAt.Format("02/01/2006 15:04"))
+	doc.TextRight(right, 62, sheetFontSize, false, "Usuário: "+s.username)
+	doc.Line(sheetMargin, 70, right, 70, 1)
+
+	headingY := 86.0
+	doc.FillRect(sheetMargin, headingY-10, right-sheetMargin, 14, 0.8)
+	doc.Text(sheetColumns.product[0]+2, headingY, sheetFontSize, true, "Produto / Lote")
+	doc.Text(sheetColumns.location[0], headingY, sheetFontSize, true, "Local")
+	doc.Text(sheetColumns.expiry[0], headingY, sheetFontSize, true, "Validade")
+	doc.TextRight(sheetColumns.quantity[0]+sheetColumns.quantity[1], headingY, sheetFontSize, true, "Quantidade")
+	doc.Text(sheetColumns.unit[0], headingY, sheetFontSize, true, "Un.")
+	doc.Text(sheetColumns.count[0], headingY, sheetFontSize, true, "Contagem")
+
+	doc.Line(sheetMargin, utils.PDFPageHeight-40, right, utils.PDFPageHeight-40, 0.5)
+	doc.Text(sheetMargin, utils.PDFPageHeight-28, sheetFontSize-1, false, "Inventário de Estoque - "+s.generatedAt.Format("02/01/2006 15:04")+" - "+s.username)
+	s.y = sheetBodyTop
+}
+
+// ensureSpace starts a new page unless height points are left above the footer.
+func (s *inventorySheet) ensureSpace(height float64) {
+	if s.y+height-sheetRowHeight > sheetBodyBottom {
+		s.newPage()
+	}
+}
+
+// row writes one row of the table; countable rows get a line to write the counted quantity on.
+func (s *inventorySheet) row(bold bool, name, location, expiry, quantity, unit string, countable bool) {
+	doc := s.doc
+	c := sheetColumns
+	doc.Text(c.product[0]+2, s.y, sheetFontSize, bold, utils.PDFFitText(name, sheetFontSize, bold, c.product[1]-4))
+	doc.Text(c.location[0], s.y, sheetFontSize, false, utils.PDFFitText(location, sheetFontSize, false, c.location[1]-4))
+	doc.Text(c.expiry[0], s.y, sheetFontSize, false, expiry)
+	doc.TextRight(c.quantity[0]+c.quantity[1], s.y, sheetFontSize, bold, quantity)
+	doc.Text(c.unit[0], s.y, sheetFontSize, bold, unit)
+	if countable {
+		doc.Line(c.count[0], s.y+1, c.count[0]+c.count[1], s.y+1, 0.4)
+	}
+	s.y += sheetRowHeight
+}
+
+// formatSheetQuantity writes a quantity in the Brazilian format, with up to 3 decimal places.
+func formatSheetQuantity(q float64) string {
+	return formatSheetNumber(math.Round(q*1000)/1000, -1)
+}
+
+// formatSheetMoney writes an amount in the Brazilian format, with 2 decimal places.
+func formatSheetMoney(v float64) string {
+	return formatSheetNumber(v, 2)
+}
+
+// formatSheetNumber writes v with a dot between thousands and a decimal comma.
+func formatSheetNumber(v float64, decimals int) string {
+	s := strconv.FormatFloat(math.Abs(v), 'f', decimals, 64)
+	whole, fraction, hasFraction := strings.Cut(s, ".")
+	var b strings.Builder
+	if v < 0 {
+		b.WriteByte('-')
+	}
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(digit)
+	}
+	if hasFraction {
+		b.WriteString("," + fraction)
+	}
+	return b.String()
+}
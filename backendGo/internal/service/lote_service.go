@@ -10,11 +10,11 @@ import (
 )
 
 type LoteService interface {
-	CreateLote(productID string, loteReq models.Lote, userID int, operationBatchID string) (*models.Lote, error)
+	CreateLote(productID string, loteReq models.Lote, actor models.Actor, operationBatchID string) (*models.Lote, error)
 	GetLotesByProductID(productID string, userID int) ([]models.Lote, error)
 	GetLoteByID(loteID string, userID int) (*models.Lote, error)
-	UpdateLote(loteID string, loteReq models.Lote, userID int, operationBatchID string) (*models.Lote, error)
-	DeleteLote(loteID string, userID int, operationBatchID string) error
+	UpdateLote(loteID string, loteReq models.Lote, actor models.Actor, operationBatchID string) (*models.Lote, error)
+	DeleteLote(loteID string, actor models.Actor, operationBatchID string) error
 }
 
 type loteService struct {
@@ -33,7 +33,8 @@ func NewLoteService(loteRepo repository.LoteRepository, productRepo repository.P
 	}
 }
 
-func (s *loteService) CreateLote(productID string, loteReq models.Lote, userID int, operationBatchID string) (*models.Lote, error) {
+func (s *loteService) CreateLote(productID string, loteReq models.Lote, actor models.Actor, operationBatchID string) (*models.Lote, error) {
+	userID := actor.UserID // The actor owns the stock it changes
 	// Check if product exists
 	product, err := s.productRepo.GetByID(productID, userID)
 	if err != nil {
@@ -73,7 +74,7 @@ func (s *loteService) CreateLote(productID string, loteReq models.Lote, userID i
 		QuantityAfter: &newLote.Quantity,
 		DataValidade: &newLote.DataValidade,
 	}
-	if err := s.historySvc.RecordChange(EntityTypeLote, newLote.ID, changeDetail, userID, operationBatchID); err != nil {
+	if err := s.historySvc.RecordChange(EntityTypeLote, newLote.ID, changeDetail, actor, operationBatchID); err != nil {
 		// Log error, but don't fail the primary operation for history recording failure
 		fmt.Printf("Warning: failed to record history for lote creation %s: %v\n", newLote.ID, err)
 	}
@@ -100,7 +101,8 @@ func (s *loteService) GetLoteByID(loteID string, userID int) (*models.Lote, erro
     return lote, nil
 }
 
-func (s *loteService) UpdateLote(loteID string, loteReq models.Lote, userID int, operationBatchID string) (*models.Lote, error) {
+func (s *loteService) UpdateLote(loteID string, loteReq models.Lote, actor models.Actor, operationBatchID string) (*models.Lote, error) {
+	userID := actor.UserID // The actor owns the stock it changes
 	existingLote, err := s.loteRepo.GetByID(loteID, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch existing lote: %w", err)
@@ -145,7 +147,7 @@ func (s *loteService) UpdateLote(loteID string, loteReq models.Lote, userID int,
 		qtyChanged := existingLote.Quantity - originalQuantity
 		changeDetail.QuantityChanged = &qtyChanged
 	}
-	if err := s.historySvc.RecordChange(EntityTypeLote, loteID, changeDetail, userID, operationBatchID); err != nil {
+	if err := s.historySvc.RecordChange(EntityTypeLote, loteID, changeDetail, actor, operationBatchID); err != nil {
 		fmt.Printf("Warning: failed to record history for lote update %s: %v\n", loteID, err)
 	}
 
@@ -156,7 +158,8 @@ func (s *loteService) UpdateLote(loteID string, loteReq models.Lote, userID int,
 	return existingLote, nil
 }
 
-func (s *loteService) DeleteLote(loteID string, userID int, operationBatchID string) error {
+func (s *loteService) DeleteLote(loteID string, actor models.Actor, operationBatchID string) error {
+	userID := actor.UserID // The actor owns the stock it changes
 	existingLote, err := s.loteRepo.GetByID(loteID, userID)
 	if err != nil {
 		return fmt.Errorf("failed to fetch lote for deletion: %w", err)
@@ -183,7 +186,7 @@ func (s *loteService) DeleteLote(loteID string, userID int, operationBatchID str
 		QuantityBefore: &existingLote.Quantity,
 		DataValidade:   &existingLote.DataValidade,
 	}
-	if err := s.historySvc.RecordChange(EntityTypeLote, loteID, changeDetail, userID, operationBatchID); err != nil {
+	if err := s.historySvc.RecordChange(EntityTypeLote, loteID, changeDetail, actor, operationBatchID); err != nil {
 		fmt.Printf("Warning: failed to record history for lote deletion %s: %v\n", loteID, err)
 	}
 
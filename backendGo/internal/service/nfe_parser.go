@@ -0,0 +1,172 @@
+package service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+)
+
+// ErrInvalidNFe is returned when a file cannot be read as an NF-e invoice.
+var ErrInvalidNFe = errors.New("invalid NF-e")
+
+// nfeInfo is the infNFe group of an NF-e, with the fields used to receive stock.
+type nfeInfo struct {
+	ID  string `xml:"Id,attr"`
+	Ide struct {
+		Number   string `xml:"nNF"`
+		Series   string `xml:"serie"`
+		IssuedAt string `xml:"dhEmi"`
+		IssuedOn string `xml:"dEmi"` // Layouts before 3.10
+	} `xml:"ide"`
+	Emit struct {
+		CNPJ string `xml:"CNPJ"`
+		CPF  string `xml:"CPF"`
+		Name string `xml:"xNome"`
+	} `xml:"emit"`
+	Det []struct {
+		Item int `xml:"nItem,attr"`
+		Prod struct {
+			Code        string `xml:"cProd"`
+			EAN         string `xml:"cEAN"`
+			Description string `xml:"xProd"`
+			Unit        string `xml:"uCom"`
+			Quantity    string `xml:"qCom"`
+			TaxUnit     string `xml:"uTrib"`
+			TaxQuantity string `xml:"qTrib"`
+			Rastro      []struct {
+				Number         string `xml:"nLote"`
+				Quantity       string `xml:"qLote"`
+				DataFabricacao string `xml:"dFab"`
+				DataValidade   string `xml:"dVal"`
+			} `xml:"rastro"`
+		} `xml:"prod"`
+	} `xml:"det"`
+}
+
+// nfeDocument accepts both an authorized invoice (nfeProc) and a bare NFe document.
+type nfeDocument struct {
+	Proc     nfeInfo `xml:"NFe>infNFe"`
+	Bare     nfeInfo `xml:"infNFe"`
+	ProtKey  string  `xml:"protNFe>infProt>chNFe"`
+	ProtStat string  `xml:"protNFe>infProt>cStat"`
+}
+
+// ParseNFe reads the issuer, the items and their lots from an NF-e XML document.
+func ParseNFe(data []byte) (*models.NFeInvoice, error) {
+	var doc nfeDocument
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = true
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: failed to read XML: %v", ErrInvalidNFe, err)
+	}
+	info := doc.Proc
+	if info.ID == "" {
+		info = doc.Bare
+	}
+	if info.ID == "" {
+		return nil, fmt.Errorf("%w: infNFe group not found", ErrInvalidNFe)
+	}
+	if doc.ProtStat != "" && doc.ProtStat != "100" && doc.ProtStat != "150" {
+		return nil, fmt.Errorf("%w: invoice is not authorized (cStat %s)", ErrInvalidNFe, doc.ProtStat)
+	}
+
+	key := strings.TrimPrefix(info.ID, "NFe")
+	if doc.ProtKey != "" && doc.ProtKey != key {
+		return nil, fmt.Errorf("%w: access key of the protocol does not match the invoice", ErrInvalidNFe)
+	}
+	if !validNFeKey(key) {
+		return nil, fmt.Errorf("%w: invalid access key %q", ErrInvalidNFe, key)
+	}
+
+	invoice := &models.NFeInvoice{
+		AccessKey:        key,
+		Number:           strings.TrimSpace(info.Ide.Number),
+		Series:           strings.TrimSpace(info.Ide.Series),
+		SupplierDocument: strings.TrimSpace(info.Emit.CNPJ),
+		SupplierName:     strings.TrimSpace(info.Emit.Name),
+		Items:            []models.NFeItem{},
+	}
+	if invoice.SupplierDocument == "" {
+		invoice.SupplierDocument = strings.TrimSpace(info.Emit.CPF)
+	}
+	if invoice.SupplierDocument == "" {
+		return nil, fmt.Errorf("%w: issuer CNPJ/CPF not found", ErrInvalidNFe)
+	}
+	if issued, err := time.Parse(time.RFC3339, strings.TrimSpace(info.Ide.IssuedAt)); err == nil {
+		invoice.IssuedAt = issued
+	} else if issued, err := time.Parse("2006-01-02", strings.TrimSpace(info.Ide.IssuedOn)); err == nil {
+		invoice.IssuedAt = issued
+	} else {
+		return nil, fmt.Errorf("%w: invalid issue date", ErrInvalidNFe)
+	}
+	if len(info.Det) == 0 {
+		return nil, fmt.Errorf("%w: the invoice has no items", ErrInvalidNFe)
+	}
+
+	for _, det := range info.Det {
+		prod := det.Prod
+		quantity, err := strconv.ParseFloat(strings.TrimSpace(prod.Quantity), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: item %d has an invalid qCom %q", ErrInvalidNFe, det.Item, prod.Quantity)
+		}
+		item := models.NFeItem{
+			Item:         det.Item,
+			SupplierCode: strings.TrimSpace(prod.Code),
+			EAN:          strings.TrimSpace(prod.EAN),
+			Description:  strings.TrimSpace(prod.Description),
+			Unit:         strings.TrimSpace(prod.Unit),
+			Quantity:     quantity,
+			TaxUnit:      strings.TrimSpace(prod.TaxUnit),
+			Lots:         []models.NFeLot{},
+		}
+		if item.EAN == "SEM GTIN" {
+			item.EAN = ""
+		}
+		if prod.TaxQuantity != "" {
+			if item.TaxQuantity, err = strconv.ParseFloat(strings.TrimSpace(prod.TaxQuantity), 64); err != nil {
+				return nil, fmt.Errorf("%w: item %d has an invalid qTrib %q", ErrInvalidNFe, det.Item, prod.TaxQuantity)
+			}
+		}
+		for _, r := range prod.Rastro {
+			lot := models.NFeLot{
+				Number:         strings.TrimSpace(r.Number),
+				DataFabricacao: strings.TrimSpace(r.DataFabricacao),
+				DataValidade:   strings.TrimSpace(r.DataValidade),
+			}
+			if lot.Quantity, err = strconv.ParseFloat(strings.TrimSpace(r.Quantity), 64); err != nil {
+				return nil, fmt.Errorf("%w: item %d has an invalid qLote %q", ErrInvalidNFe, det.Item, r.Quantity)
+			}
+			item.Lots = append(item.Lots, lot)
+		}
+		invoice.Items = append(invoice.Items, item)
+	}
+	return invoice, nil
+}
+
+// validNFeKey checks the length and the modulo 11 check digit of an NF-e access key.
+func validNFeKey(key string) bool {
+	if len(key) != 44 {
+		return false
+	}
+	sum, weight := 0, 2
+	for i := 42; i >= 0; i-- {
+		if key[i] < '0' || key[i] > '9' {
+			return false
+		}
+		sum += int(key[i]-'0') * weight
+		if weight++; weight > 9 {
+			weight = 2
+		}
+	}
+	dv := 11 - sum%11
+	if dv >= 10 {
+		dv = 0
+	}
+	return key[43] == byte('0'+dv)
+}
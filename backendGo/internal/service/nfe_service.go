@@ -0,0 +1,365 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNFeAlreadyImported = errors.New("NF-e was already imported")
+	ErrInvalidNFeMapping  = errors.New("invalid NF-e mapping")
+)
+
+// nfeUnits maps NF-e unit codes to a product unit and how many of it one unit is.
+var nfeUnits = map[string]struct {
+	unit   string
+	factor float64
+}{
+	"KG": {"kg", 1}, "KGS": {"kg", 1}, "QUILO": {"kg", 1}, "G": {"kg", 0.001}, "GR": {"kg", 0.001},
+	"T": {"kg", 1000}, "TON": {"kg", 1000}, "TN": {"kg", 1000},
+	"L": {"L", 1}, "LT": {"L", 1}, "LTS": {"L", 1}, "LITRO": {"L", 1}, "ML": {"L", 0.001},
+}
+
+// NFeImportOptions controls how an NF-e invoice is received.
+type NFeImportOptions struct {
+	DryRun        bool
+	SkipUntracked bool                     // Leave out items without lots instead of rejecting the invoice
+	Mappings      []models.NFeMappingInput // Mappings for this invoice; they are remembered when applied
+}
+
+// NFeService receives stock from NF-e invoices.
+type NFeService interface {
+	Import(data []byte, opts NFeImportOptions, actor models.Actor, batchID string) (*models.NFeImportResult, error)
+	ListImports(userID int) ([]models.NFeImport, error)
+	ListMappings(userID int) ([]models.SupplierProductMapping, error)
+}
+
+type nfeService struct {
+	nfeRepo     repository.NFeRepository
+	productRepo repository.ProductRepository
+	loteRepo    repository.LoteRepository
+	historyRepo repository.HistoryRepository
+	db          *sql.DB // For transactions
+}
+
+// NewNFeService creates a new NFeService
+func NewNFeService(nfeRepo repository.NFeRepository, productRepo repository.ProductRepository, loteRepo repository.LoteRepository, historyRepo repository.HistoryRepository, db *sql.DB) NFeService {
+	return &nfeService{nfeRepo: nfeRepo, productRepo: productRepo, loteRepo: loteRepo, historyRepo: historyRepo, db: db}
+}
+
+// Import parses an NF-e invoice and maps each item to a product: by a mapping sent with the request,
+// by the mapping saved for the supplier code, or by a product with the same name. Each lot of the
+// rastro group becomes a lote. Unless opts.DryRun is set or an item is invalid, the lotes are created
+// in one transaction and one history batch whose entries carry the invoice key, and the mappings used
+// are remembered for the supplier's next invoices.
+func (s *nfeService) Import(data []byte, opts NFeImportOptions, actor models.Actor, batchID string) (*models.NFeImportResult, error) {
+	userID := actor.UserID
+	invoice, err := ParseNFe(data)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := s.nfeRepo.GetImport(invoice.AccessKey, userID)
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil {
+		return nil, fmt.Errorf("%w on %s (batch %s)", ErrNFeAlreadyImported, previous.ImportedAt.Format(time.RFC3339), previous.BatchID)
+	}
+
+	products, err := s.productRepo.GetAll(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get products for NF-e import: %w", err)
+	}
+	byID := make(map[string]models.Product, len(products))
+	byName := make(map[string]models.Product, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+		byName[importNameKey(p.Name)] = p
+	}
+
+	saved, err := s.nfeRepo.GetMappings(invoice.SupplierDocument, userID)
+	if err != nil {
+		return nil, err
+	}
+	requested, err := requestedMappings(opts.Mappings, invoice, byID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.NFeImportResult{Invoice: *invoice, DryRun: opts.DryRun, Valid: true, Items: make([]models.NFeImportItem, len(invoice.Items))}
+	for i, item := range invoice.Items {
+		result.Items[i] = s.receiveItem(item, opts, requested, saved, byID, byName, userID)
+		if len(result.Items[i].Errors) > 0 {
+			result.Valid = false
+		}
+	}
+	if opts.DryRun || !result.Valid {
+		return result, nil
+	}
+
+	if batchID == "" {
+		batchID = uuid.NewString()
+	}
+	if err := s.apply(result, requested, byID, actor, batchID); err != nil {
+		return nil, err
+	}
+	result.Applied = true
+	result.BatchID = batchID
+	return result, nil
+}
+
+// requestedMappings indexes the mappings sent with the request by supplier code, checking that
+// each one refers to an item of the invoice and to a product of the user.
+func requestedMappings(inputs []models.NFeMappingInput, invoice *models.NFeInvoice, byID map[string]models.Product) (map[string]models.NFeMappingInput, error) {
+	codes := make(map[string]bool, len(invoice.Items))
+	for _, item := range invoice.Items {
+		codes[item.SupplierCode] = true
+	}
+	mappings := make(map[string]models.NFeMappingInput, len(inputs))
+	for _, m := range inputs {
+		if !codes[m.SupplierCode] {
+			return nil, fmt.Errorf("%w: supplier code %q is not an item of the invoice", ErrInvalidNFeMapping, m.SupplierCode)
+		}
+		if _, ok := byID[m.ProductID]; !ok {
+			return nil, fmt.Errorf("%w: product %s not found", ErrInvalidNFeMapping, m.ProductID)
+		}
+		if m.UnitFactor != nil && (*m.UnitFactor <= 0 || math.IsInf(*m.UnitFactor, 0)) {
+			return nil, fmt.Errorf("%w: unitFactor of supplier code %q must be above zero", ErrInvalidNFeMapping, m.SupplierCode)
+		}
+		mappings[m.SupplierCode] = m
+	}
+	return mappings, nil
+}
+
+// receiveItem resolves the product of an invoice item and the lotes its lots become.
+func (s *nfeService) receiveItem(item models.NFeItem, opts NFeImportOptions, requested map[string]models.NFeMappingInput,
+	saved map[string]models.SupplierProductMapping, byID, byName map[string]models.Product, userID int) models.NFeImportItem {
+	received := models.NFeImportItem{
+		Item:         item.Item,
+		SupplierCode: item.SupplierCode,
+		Description:  item.Description,
+		Unit:         item.Unit,
+		Quantity:     item.Quantity,
+		Lotes:        []models.Lote{},
+	}
+	fail := func(format string, args ...interface{}) {
+		received.Errors = append(received.Errors, fmt.Sprintf(format, args...))
+	}
+
+	var product models.Product
+	var factor *float64
+	if m, ok := requested[item.SupplierCode]; ok {
+		product, factor, received.MappingSource = byID[m.ProductID], m.UnitFactor, "request"
+	} else if m, ok := saved[item.SupplierCode]; ok && byID[m.ProductID].ID != "" {
+		product, factor, received.MappingSource = byID[m.ProductID], m.UnitFactor, "saved"
+	} else if p, ok := byName[importNameKey(item.Description)]; ok {
+		product, received.MappingSource = p, "name"
+	} else {
+		fail("no product mapped to supplier code %q; send a mapping for it", item.SupplierCode)
+		return received
+	}
+	received.ProductID, received.ProductName = product.ID, product.Name
+
+	if len(item.Lots) == 0 {
+		if opts.SkipUntracked {
+			received.Skipped = true
+			received.Warnings = append(received.Warnings, "item has no lots (rastro) and was left out")
+		} else {
+			fail("item has no lots (rastro) with an expiry date; send skip_untracked=true to leave it out")
+		}
+		return received
+	}
+
+	if factor != nil {
+		received.UnitFactor = *factor
+	} else if f, ok := nfeUnitFactor(item, product.Unit); ok {
+		received.UnitFactor = f
+	} else {
+		fail("unit %q cannot be converted to the product unit %q; send a mapping with unitFactor", item.Unit, product.Unit)
+		return received
+	}
+
+	today := time.Now().Format("2006-01-02")
+	lotsTotal := 0.0
+	for _, lot := range item.Lots {
+		lotsTotal += lot.Quantity
+		validade, err := time.Parse("2006-01-02", lot.DataValidade)
+		if err != nil {
+			fail("lot %q has an invalid expiry date %q", lot.Number, lot.DataValidade)
+			continue
+		}
+		if lot.DataFabricacao != "" {
+			fabricacao, err := time.Parse("2006-01-02", lot.DataFabricacao)
+			if err != nil {
+				fail("lot %q has an invalid manufacturing date %q", lot.Number, lot.DataFabricacao)
+				continue
+			}
+			if fabricacao.After(validade) {
+				fail("lot %q expires before it was manufactured", lot.Number)
+				continue
+			}
+		}
+		if lot.Quantity <= 0 {
+			fail("lot %q has no quantity", lot.Number)
+			continue
+		}
+		if lot.DataValidade < today {
+			received.Warnings = append(received.Warnings, fmt.Sprintf("lot %q expired on %s", lot.Number, lot.DataValidade))
+		}
+		received.Lotes = append(received.Lotes, models.Lote{
+			ProductID:    product.ID,
+			UserID:       userID,
+			Quantity:     lot.Quantity * received.UnitFactor,
+			DataValidade: lot.DataValidade,
+		})
+	}
+	if !isZeroQuantity(lotsTotal - item.Quantity) {
+		received.Warnings = append(received.Warnings, fmt.Sprintf("lots add up to %g %s, the item quantity is %g", lotsTotal, item.Unit, item.Quantity))
+	}
+	return received
+}
+
+// nfeUnitFactor returns how many product units one commercial unit of the item is, from the
+// commercial unit or, failing that, from the tributable unit and quantity.
+func nfeUnitFactor(item models.NFeItem, productUnit string) (float64, bool) {
+	if u, ok := nfeUnits[strings.ToUpper(item.Unit)]; ok && u.unit == productUnit {
+		return u.factor, true
+	}
+	if u, ok := nfeUnits[strings.ToUpper(item.TaxUnit)]; ok && u.unit == productUnit && item.Quantity > 0 && item.TaxQuantity > 0 {
+		return item.TaxQuantity / item.Quantity * u.factor, true
+	}
+	return 0, false
+}
+
+// apply creates the lotes, their history and the record of the import in one transaction,
+// and remembers the mappings that were not already saved.
+func (s *nfeService) apply(result *models.NFeImportResult, requested map[string]models.NFeMappingInput, byID map[string]models.Product, actor models.Actor, batchID string) error {
+	invoice := result.Invoice
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Rollback if not committed
+
+	now := time.Now()
+	var entries []models.History
+	record := func(entityType, entityID string, detail interface{}) error {
+		changes, err := json.Marshal(detail)
+		if err != nil {
+			return fmt.Errorf("failed to marshal change detail: %w", err)
+		}
+		entry := newActorEntry(entityType, entityID, changes, actor, now)
+		entry.BatchID = batchID
+		entries = append(entries, entry)
+		return nil
+	}
+
+	added := map[string]float64{}
+	var productOrder []string
+	loteCount := 0
+	for i := range result.Items {
+		item := &result.Items[i]
+		if item.Skipped {
+			continue
+		}
+		if item.MappingSource != "saved" {
+			mapping := models.SupplierProductMapping{SupplierDocument: invoice.SupplierDocument, SupplierCode: item.SupplierCode, ProductID: item.ProductID}
+			if m, ok := requested[item.SupplierCode]; ok {
+				mapping.UnitFactor = m.UnitFactor
+			}
+			if err := s.nfeRepo.SaveMappingTx(tx, mapping, actor.UserID); err != nil {
+				return err
+			}
+		}
+
+		// Every lot became a lote, in order, since the item has no errors.
+		lots := invoice.Items[i].Lots
+		for j := range item.Lotes {
+			lote := &item.Lotes[j]
+			if err := s.loteRepo.Create(tx, lote); err != nil {
+				return fmt.Errorf("failed to create lote of item %d: %w", item.Item, err)
+			}
+			quantity, validade := lote.Quantity, lote.DataValidade
+			detail := models.LoteChangeDetail{
+				LoteID:        lote.ID,
+				ProductID:     lote.ProductID,
+				Action:        "created",
+				QuantityAfter: &quantity,
+				DataValidade:  &validade,
+				InvoiceKey:    invoice.AccessKey,
+				LotNumber:     truncate(lots[j].Number, 20),
+			}
+			if lots[j].DataFabricacao != "" {
+				fabricacao := lots[j].DataFabricacao
+				detail.DataFabricacao = &fabricacao
+			}
+			if err := record(EntityTypeLote, lote.ID, detail); err != nil {
+				return err
+			}
+			if _, ok := added[lote.ProductID]; !ok {
+				productOrder = append(productOrder, lote.ProductID)
+			}
+			added[lote.ProductID] += quantity
+			loteCount++
+		}
+	}
+
+	for _, productID := range productOrder {
+		product := byID[productID]
+		// The product quantity becomes the sum of its lotes (database trigger).
+		before, after := product.Quantity, added[productID]
+		if len(product.Lotes) > 0 {
+			after += before
+		}
+		if err := record(EntityTypeProductBatchContext, productID, models.ProductBatchContextChangeDetail{
+			ProductID:           productID,
+			ProductNameSnapshot: product.Name,
+			QuantityBeforeBatch: before,
+			QuantityAfterBatch:  after,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if len(entries) > 0 {
+		if err := s.historyRepo.CreateBatchTx(tx, entries); err != nil {
+			return fmt.Errorf("failed to record NF-e batch: %w", err)
+		}
+	}
+	if err := s.nfeRepo.CreateImportTx(tx, &models.NFeImport{
+		UserID:           actor.UserID,
+		AccessKey:        invoice.AccessKey,
+		Number:           truncate(invoice.Number, 9),
+		Series:           truncate(invoice.Series, 3),
+		SupplierDocument: truncate(invoice.SupplierDocument, 14),
+		SupplierName:     truncate(invoice.SupplierName, 255),
+		IssuedAt:         invoice.IssuedAt,
+		BatchID:          batchID,
+		LoteCount:        loteCount,
+	}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ListImports retrieves the user's imported invoices, newest first.
+func (s *nfeService) ListImports(userID int) ([]models.NFeImport, error) {
+	return s.nfeRepo.ListImports(userID)
+}
+
+// ListMappings retrieves the user's saved supplier code mappings.
+func (s *nfeService) ListMappings(userID int) ([]models.SupplierProductMapping, error) {
+	return s.nfeRepo.ListMappings(userID)
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/repository"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/utils"
+)
+
+// ProductExportHeaders are the columns of the product spreadsheet, as exported by the frontend.
+var ProductExportHeaders = []string{"Tipo", "Nome Produto", "Unidade Produto", "Qtd Total Produto", "Qtd Lote", "Validade Lote"}
+
+// ProductExportColumnWidths are the XLSX column widths used by the frontend, in characters.
+var ProductExportColumnWidths = []float64{6, 30, 15, 15, 15, 20}
+
+// ProductExportService builds the product spreadsheet on the server, so jobs and scripts
+// can produce it without a browser.
+type ProductExportService interface {
+	GetExportRows(userID int) ([]models.ProductExportRow, error)
+	ExportProducts(userID int, w utils.TableWriter) error
+}
+
+type productExportService struct {
+	productRepo repository.ProductRepository
+}
+
+// NewProductExportService creates a new ProductExportService
+func NewProductExportService(productRepo repository.ProductRepository) ProductExportService {
+	return &productExportService{productRepo: productRepo}
+}
+
+// GetExportRows returns the spreadsheet rows of the user's products, ordered by name, each product
+// followed by its lotes ordered by expiry date.
+func (s *productExportService) GetExportRows(userID int) ([]models.ProductExportRow, error) {
+	products, err := s.productRepo.GetAll(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get products for export: %w", err)
+	}
+	sort.SliceStable(products, func(i, j int) bool {
+		return strings.ToLower(products[i].Name) < strings.ToLower(products[j].Name)
+	})
+
+	rows := []models.ProductExportRow{}
+	for _, p := range products {
+		// Like the frontend, the total comes from the lotes when there are any.
+		total := p.Quantity
+		if len(p.Lotes) > 0 {
+			total = 0
+			for _, l := range p.Lotes {
+				total += l.Quantity
+			}
+		}
+		rows = append(rows, models.ProductExportRow{Tipo: "Produto", NomeProduto: p.Name, UnidadeProduto: p.Unit, QtdTotalProduto: &total})
+
+		for _, l := range p.Lotes {
+			quantity := l.Quantity
+			validade := dateOnly(l.DataValidade)
+			rows = append(rows, models.ProductExportRow{Tipo: "Lote", QtdLote: &quantity, ValidadeLote: formatExportDate(&validade)})
+		}
+	}
+	return rows, nil
+}
+
+// ExportProducts writes the spreadsheet rows of the user's products to w.
+func (s *productExportService) ExportProducts(userID int, w utils.TableWriter) error {
+	rows, err := s.GetExportRows(userID)
+	if err != nil {
+		return err
+	}
+	if err := w.WriteHeader(ProductExportHeaders); err != nil {
+		return fmt.Errorf("failed to write export header: %w", err)
+	}
+	for _, row := range rows {
+		cells := []interface{}{row.Tipo, row.NomeProduto, row.UnidadeProduto, row.QtdTotalProduto, row.QtdLote, row.ValidadeLote}
+		if err := w.WriteRow(cells); err != nil {
+			return fmt.Errorf("failed to write export row: %w", err)
+		}
+	}
+	return w.Close()
+}
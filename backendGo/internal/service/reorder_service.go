@@ -0,0 +1,322 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/repository"
+)
+
+const (
+	DefaultReorderHorizonDays = 7
+	PurchaseOrderStatusDraft  = "draft"
+)
+
+var ErrInvalidDraftOrder = errors.New("invalid draft order")
+
+// ReorderService turns stock levels, supplier lead times and consumption forecasts into reorder
+// suggestions, and suggestions into draft purchase orders.
+type ReorderService interface {
+	GetSuggestions(forecastDays, horizonDays int, userID int) (*models.ReorderSuggestionReport, error)
+	CreateDraftOrders(input models.DraftOrdersInput, userID int) ([]models.PurchaseOrder, error)
+	ListOrders(userID int) ([]models.PurchaseOrder, error)
+	GetOrder(id int64, userID int) (*models.PurchaseOrder, error)
+}
+
+type reorderService struct {
+	productRepo    repository.ProductRepository
+	paramsRepo     repository.ProductParametersRepository
+	supplierRepo   repository.SupplierRepository
+	nfeRepo        repository.NFeRepository
+	orderRepo      repository.PurchaseOrderRepository
+	consumptionSvc ConsumptionService
+	db             *sql.DB // For transactions
+}
+
+// NewReorderService creates a new ReorderService
+func NewReorderService(productRepo repository.ProductRepository, paramsRepo repository.ProductParametersRepository, supplierRepo repository.SupplierRepository, nfeRepo repository.NFeRepository, orderRepo repository.PurchaseOrderRepository, consumptionSvc ConsumptionService, db *sql.DB) ReorderService {
+	return &reorderService{
+		productRepo:    productRepo,
+		paramsRepo:     paramsRepo,
+		supplierRepo:   supplierRepo,
+		nfeRepo:        nfeRepo,
+		orderRepo:      orderRepo,
+		consumptionSvc: consumptionSvc,
+		db:             db,
+	}
+}
+
+// reorderPlan gathers what is known about restocking one product.
+type reorderPlan struct {
+	product    models.Product
+	params     models.ProductParameters
+	supplier   *models.Supplier
+	suggestion *models.ReorderSuggestion // nil when the product need not be ordered within the horizon
+}
+
+// GetSuggestions lists the products whose stock reaches the reorder point within horizonDays. The
+// reorder point is the minimum quantity plus the consumption expected during the supplier's lead time,
+// at the average daily consumption of the last forecastDays days. The suggested quantity brings the
+// stock on receipt up to the maximum quantity or, without one, to the reorder point plus another
+// forecast window of consumption.
+func (s *reorderService) GetSuggestions(forecastDays, horizonDays int, userID int) (*models.ReorderSuggestionReport, error) {
+	now := time.Now()
+	plans, err := s.plan(forecastDays, horizonDays, userID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.ReorderSuggestionReport{
+		GeneratedAt:        now,
+		ForecastWindowDays: forecastDays,
+		HorizonDays:        horizonDays,
+		Suggestions:        []models.ReorderSuggestion{},
+	}
+	for _, p := range plans {
+		if p.suggestion != nil {
+			report.Suggestions = append(report.Suggestions, *p.suggestion)
+		}
+	}
+	sort.SliceStable(report.Suggestions, func(i, j int) bool {
+		a, b := report.Suggestions[i], report.Suggestions[j]
+		if a.SuggestedOrderOn != b.SuggestedOrderOn {
+			return a.SuggestedOrderOn < b.SuggestedOrderOn
+		}
+		return a.ProductName < b.ProductName
+	})
+	return report, nil
+}
+
+// plan builds the reorder plan of every product of the user, keyed by product ID.
+func (s *reorderService) plan(forecastDays, horizonDays int, userID int, now time.Time) (map[string]*reorderPlan, error) {
+	products, err := s.productRepo.GetAll(userID)
+	if err != nil {
+		return nil, err
+	}
+	rates, err := s.consumptionSvc.GetDailyRates(forecastDays, userID)
+	if err != nil {
+		return nil, err
+	}
+	params, err := s.paramsRepo.List(userID)
+	if err != nil {
+		return nil, err
+	}
+	suppliers, err := s.supplierRepo.List(userID)
+	if err != nil {
+		return nil, err
+	}
+	mappings, err := s.nfeRepo.ListMappings(userID)
+	if err != nil {
+		return nil, err
+	}
+	imports, err := s.nfeRepo.ListImports(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	paramsByProduct := make(map[string]models.ProductParameters)
+	for _, p := range params {
+		paramsByProduct[p.ProductID] = p
+	}
+	// Suppliers without a record are known by the name on their latest invoice, with no lead time
+	supplierByDocument := make(map[string]models.Supplier)
+	for i := len(imports) - 1; i >= 0; i-- { // Newest first; let the newest name win
+		supplierByDocument[imports[i].SupplierDocument] = models.Supplier{Document: imports[i].SupplierDocument, Name: imports[i].SupplierName}
+	}
+	for _, sup := range suppliers {
+		supplierByDocument[sup.Document] = sup
+	}
+	// Without a preferred supplier, a product is bought from whoever last delivered it on an NF-e
+	lastSupplier := make(map[string]models.SupplierProductMapping)
+	for _, m := range mappings {
+		if last, ok := lastSupplier[m.ProductID]; !ok || m.UpdatedAt.After(last.UpdatedAt) {
+			lastSupplier[m.ProductID] = m
+		}
+	}
+
+	plans := make(map[string]*reorderPlan, len(products))
+	for _, product := range products {
+		p := &reorderPlan{product: product, params: paramsByProduct[product.ID]}
+		document := ""
+		if p.params.PreferredSupplierDocument != nil {
+			document = *p.params.PreferredSupplierDocument
+		} else if m, ok := lastSupplier[product.ID]; ok {
+			document = m.SupplierDocument
+		}
+		if document != "" {
+			supplier, ok := supplierByDocument[document]
+			if !ok {
+				supplier = models.Supplier{Document: document}
+			}
+			p.supplier = &supplier
+		}
+		p.suggestion = suggestReorder(p, rates[product.ID], forecastDays, horizonDays, now)
+		plans[product.ID] = p
+	}
+	return plans, nil
+}
+
+// suggestReorder returns the reorder suggestion of a plan, or nil if the product need not be ordered
+// within the horizon (or has neither stock levels nor consumption to base one on).
+func suggestReorder(p *reorderPlan, dailyRate float64, forecastDays, horizonDays int, now time.Time) *models.ReorderSuggestion {
+	if p.params.MinQuantity == nil && p.params.MaxQuantity == nil && dailyRate <= 0 {
+		return nil
+	}
+	leadTime := 0
+	if p.supplier != nil {
+		leadTime = p.supplier.LeadTimeDays
+	}
+	minQuantity := 0.0
+	if p.params.MinQuantity != nil {
+		minQuantity = *p.params.MinQuantity
+	}
+	quantity := p.product.Quantity
+	reorderPoint := minQuantity + dailyRate*float64(leadTime)
+
+	daysUntil := 0
+	if quantity > reorderPoint {
+		if dailyRate <= 0 {
+			return nil // Nothing is consumed, so the reorder point is never reached
+		}
+		days := math.Floor((quantity - reorderPoint) / dailyRate)
+		if days > float64(horizonDays) {
+			return nil
+		}
+		daysUntil = int(days)
+	}
+
+	target := reorderPoint + dailyRate*float64(forecastDays)
+	if p.params.MaxQuantity != nil {
+		target = *p.params.MaxQuantity
+	}
+	atReceipt := math.Max(0, quantity-dailyRate*float64(daysUntil+leadTime))
+	suggested := math.Ceil((target-atReceipt)*1000) / 1000
+	if suggested <= 0 {
+		return nil
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	orderOn := today.AddDate(0, 0, daysUntil)
+	suggestion := &models.ReorderSuggestion{
+		ProductID:         p.product.ID,
+		ProductName:       p.product.Name,
+		Unit:              p.product.Unit,
+		CurrentQuantity:   quantity,
+		DailyRate:         dailyRate,
+		MinQuantity:       p.params.MinQuantity,
+		MaxQuantity:       p.params.MaxQuantity,
+		LeadTimeDays:      leadTime,
+		ReorderPoint:      roundQuantity(reorderPoint),
+		SuggestedQuantity: suggested,
+		SuggestedOrderOn:  orderOn.Format("2006-01-02"),
+		ExpectedOn:        orderOn.AddDate(0, 0, leadTime).Format("2006-01-02"),
+		Supplier:          p.supplier,
+		UnitCost:          p.params.UnitCost,
+	}
+	if p.params.UnitCost != nil {
+		cost := math.Round(*p.params.UnitCost*suggested*100) / 100
+		suggestion.EstimatedCost = &cost
+	}
+	return suggestion
+}
+
+// CreateDraftOrders turns the selected products into draft purchase orders, one per supplier (products
+// without a supplier share an order without one), expected after the supplier's lead time from today.
+// Each item orders the quantity sent with it or, without one, the product's current suggested quantity.
+// The orders are created in one transaction.
+func (s *reorderService) CreateDraftOrders(input models.DraftOrdersInput, userID int) ([]models.PurchaseOrder, error) {
+	forecastDays := input.ForecastWindowDays
+	if forecastDays == 0 {
+		forecastDays = DefaultForecastWindowDays
+	}
+	now := time.Now()
+	// Any suggestion counts here, however far ahead its order date is
+	plans, err := s.plan(forecastDays, MaxConsumptionWindowDays, userID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	orders := []models.PurchaseOrder{}
+	orderBySupplier := make(map[string]int)
+	seen := make(map[string]bool)
+	for _, item := range input.Items {
+		p, ok := plans[item.ProductID]
+		if !ok {
+			return nil, fmt.Errorf("%w: product %s not found", ErrInvalidDraftOrder, item.ProductID)
+		}
+		if seen[item.ProductID] {
+			return nil, fmt.Errorf("%w: product %q is selected twice", ErrInvalidDraftOrder, p.product.Name)
+		}
+		seen[item.ProductID] = true
+
+		var quantity float64
+		switch {
+		case item.Quantity != nil:
+			quantity = *item.Quantity
+		case p.suggestion != nil:
+			quantity = p.suggestion.SuggestedQuantity
+		default:
+			return nil, fmt.Errorf("%w: product %q has no reorder suggestion; send the quantity to order", ErrInvalidDraftOrder, p.product.Name)
+		}
+
+		document := ""
+		if p.supplier != nil {
+			document = p.supplier.Document
+		}
+		i, ok := orderBySupplier[document]
+		if !ok {
+			order := models.PurchaseOrder{UserID: userID, Status: PurchaseOrderStatusDraft}
+			expectedOn := now.Format("2006-01-02")
+			if p.supplier != nil {
+				order.SupplierDocument = &p.supplier.Document
+				if p.supplier.Name != "" {
+					order.SupplierName = &p.supplier.Name
+				}
+				expectedOn = now.AddDate(0, 0, p.supplier.LeadTimeDays).Format("2006-01-02")
+			}
+			order.ExpectedOn = &expectedOn
+			orders = append(orders, order)
+			i = len(orders) - 1
+			orderBySupplier[document] = i
+		}
+		productID := p.product.ID
+		orders[i].Items = append(orders[i].Items, models.PurchaseOrderItem{
+			ProductID:   &productID,
+			ProductName: p.product.Name,
+			Unit:        p.product.Unit,
+			Quantity:    quantity,
+			UnitCost:    p.params.UnitCost,
+		})
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Rollback if not committed
+
+	for i := range orders {
+		if err := s.orderRepo.CreateTx(tx, &orders[i]); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return orders, nil
+}
+
+// ListOrders retrieves the user's purchase orders, newest first.
+func (s *reorderService) ListOrders(userID int) ([]models.PurchaseOrder, error) {
+	return s.orderRepo.List(userID)
+}
+
+// GetOrder retrieves a purchase order, or nil if the user has no such order.
+func (s *reorderService) GetOrder(id int64, userID int) (*models.PurchaseOrder, error) {
+	return s.orderRepo.GetByID(id, userID)
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/repository"
+)
+
+var ErrInvalidSpedOptions = errors.New("invalid SPED export options")
+
+// SpedValidationError lists the products whose mandatory Bloco H fields are missing or invalid.
+// Nothing is exported until every issue is fixed.
+type SpedValidationError struct {
+	Issues []models.SpedIssue
+}
+
+func (e *SpedValidationError) Error() string {
+	return fmt.Sprintf("%d mandatory SPED fields are missing or invalid", len(e.Issues))
+}
+
+// Limits of the Bloco H fields, from the SPED Fiscal (EFD ICMS/IPI) layout.
+const (
+	spedMaxItemCode = 60
+	spedMaxUnit     = 6
+	spedMaxAccount  = 255
+)
+
+// SpedService exports the stock in the layouts of SPED Fiscal
+type SpedService interface {
+	BuildBlocoH(opts models.SpedBlocoHOptions, userID int) (*models.SpedBlocoH, error)
+}
+
+type spedService struct {
+	closingRepo repository.StockClosingRepository
+	paramsRepo  repository.ProductParametersRepository
+	stockSvc    StockReportService
+}
+
+// NewSpedService creates a new SpedService
+func NewSpedService(closingRepo repository.StockClosingRepository, paramsRepo repository.ProductParametersRepository, stockSvc StockReportService) SpedService {
+	return &spedService{closingRepo: closingRepo, paramsRepo: paramsRepo, stockSvc: stockSvc}
+}
+
+// spedItem is a product in stock on the inventory date, one H010 record.
+type spedItem struct {
+	productID, name, code, unit string
+	quantity                    float64
+	unitCost                    *float64
+}
+
+// BuildBlocoH generates the inventory register of SPED Fiscal (block H: H001, H005, one H010 per
+// product in stock, H990) from a monthly closing, valued at the unit costs frozen in it, or from the
+// stock at the end of a day, valued at the current unit costs. The inventory is the one taken at the
+// end of the period (MOT_INV 01), of items owned by the company and in its possession (IND_PROP 0).
+// Item codes must match the company's register 0200. Any missing or invalid mandatory field fails
+// the export with a SpedValidationError listing them all.
+func (s *spedService) BuildBlocoH(opts models.SpedBlocoHOptions, userID int) (*models.SpedBlocoH, error) {
+	if opts.Closing != "" && opts.Date != "" {
+		return nil, fmt.Errorf("%w: use either a closing or a date, not both", ErrInvalidSpedOptions)
+	}
+	if opts.Account != "" && (len([]rune(opts.Account)) > spedMaxAccount || !spedText(opts.Account)) {
+		return nil, fmt.Errorf("%w: the account (COD_CTA) must have at most %d characters, without '|'", ErrInvalidSpedOptions, spedMaxAccount)
+	}
+
+	params, err := s.paramsRepo.List(userID)
+	if err != nil {
+		return nil, err
+	}
+	paramsByProduct := make(map[string]models.ProductParameters, len(params))
+	for _, p := range params {
+		paramsByProduct[p.ProductID] = p
+	}
+
+	var inventoryDate time.Time
+	var items []spedItem
+	switch {
+	case opts.Closing != "":
+		if _, _, err := parseClosingPeriod(opts.Closing); err != nil {
+			return nil, err
+		}
+		closing, err := s.closingRepo.GetByPeriod(opts.Closing, userID)
+		if err != nil {
+			return nil, err
+		}
+		if closing == nil {
+			return nil, fmt.Errorf("%w: %s", ErrClosingNotFound, opts.Closing)
+		}
+		inventoryDate = closing.PeriodEnd.AddDate(0, 0, -1)
+		for _, p := range closing.Products {
+			items = append(items, spedItem{productID: p.ProductID, name: p.ProductName, unit: p.Unit, quantity: p.Quantity, unitCost: p.UnitCost})
+		}
+	case opts.Date != "":
+		day, err := time.ParseInLocation("2006-01-02", opts.Date, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid date %q, expected YYYY-MM-DD", ErrInvalidSpedOptions, opts.Date)
+		}
+		if day.After(time.Now()) {
+			return nil, fmt.Errorf("%w: the inventory date must not be in the future", ErrInvalidSpedOptions)
+		}
+		inventoryDate = day
+		report, err := s.stockSvc.GetStockAt(day.AddDate(0, 0, 1).Add(-time.Nanosecond), userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range report.Products {
+			items = append(items, spedItem{productID: p.ProductID, name: p.ProductName, unit: p.Unit, quantity: p.Quantity, unitCost: paramsByProduct[p.ProductID].UnitCost})
+		}
+	default:
+		return nil, fmt.Errorf("%w: either a closing or a date is required", ErrInvalidSpedOptions)
+	}
+
+	// Only products in stock are inventoried; codes come from the current product parameters
+	inStock := items[:0]
+	for _, item := range items {
+		if math.Round(item.quantity*1000) <= 0 {
+			continue
+		}
+		if code := paramsByProduct[item.productID].Code; code != nil {
+			item.code = strings.TrimSpace(*code)
+		}
+		inStock = append(inStock, item)
+	}
+	items = inStock
+	sort.SliceStable(items, func(i, j int) bool { return items[i].code < items[j].code })
+
+	if issues := validateSpedItems(items); len(issues) > 0 {
+		return nil, &SpedValidationError{Issues: issues}
+	}
+
+	result := &models.SpedBlocoH{InventoryDate: inventoryDate.Format("2006-01-02"), Items: len(items)}
+	records := make([][]string, 0, len(items))
+	totalCents := int64(0)
+	for _, item := range items {
+		quantity := math.Round(item.quantity*1000) / 1000
+		valueCents := int64(math.Round(quantity * *item.unitCost * 100))
+		totalCents += valueCents
+		value := spedMoney(valueCents)
+		records = append(records, []string{"H010", item.code, item.unit, spedNumber(quantity, 3), spedNumber(*item.unitCost, 6),
+			value, "0", "", "", opts.Account, value})
+	}
+	result.TotalValue = float64(totalCents) / 100
+
+	lines := [][]string{
+		{"H001", "0"},
+		{"H005", inventoryDate.Format("02012006"), spedMoney(totalCents), "01"},
+	}
+	lines = append(lines, records...)
+	lines = append(lines, []string{"H990", strconv.Itoa(len(lines) + 1)})
+
+	var buf bytes.Buffer
+	for _, fields := range lines {
+		buf.WriteString("|" + strings.Join(fields, "|") + "|\r\n")
+	}
+	result.Content = latin1(buf.String())
+	return result, nil
+}
+
+// validateSpedItems checks the mandatory fields of the H010 records: a unique item code, the unit
+// and a positive unit cost.
+func validateSpedItems(items []spedItem) []models.SpedIssue {
+	issues := []models.SpedIssue{}
+	add := func(item spedItem, field, message string) {
+		issues = append(issues, models.SpedIssue{ProductID: item.productID, ProductName: item.name, Field: field, Message: message})
+	}
+	firstWithCode := make(map[string]spedItem)
+	for _, item := range items {
+		switch {
+		case item.code == "":
+			add(item, "COD_ITEM", "the product has no item code")
+		case len([]rune(item.code)) > spedMaxItemCode || !spedText(item.code):
+			add(item, "COD_ITEM", fmt.Sprintf("the item code must have at most %d characters, without '|'", spedMaxItemCode))
+		default:
+			if first, ok := firstWithCode[item.code]; ok {
+				add(item, "COD_ITEM", fmt.Sprintf("item code %s is also used by %s", item.code, first.name))
+			} else {
+				firstWithCode[item.code] = item
+			}
+		}
+		if item.unit == "" || len([]rune(item.unit)) > spedMaxUnit || !spedText(item.unit) {
+			add(item, "UNID", fmt.Sprintf("the unit must have 1 to %d characters, without '|'", spedMaxUnit))
+		}
+		if item.unitCost == nil {
+			add(item, "VL_UNIT", "the product has no unit cost")
+		} else if *item.unitCost <= 0 {
+			add(item, "VL_UNIT", "the unit cost must be greater than zero")
+		}
+	}
+	return issues
+}
+
+// spedText reports whether s can be written in a SPED field: no field separator or line break.
+func spedText(s string) bool {
+	return !strings.ContainsAny(s, "|\r\n")
+}
+
+// spedNumber writes v with a decimal comma, without thousands separators, with at most the given
+// number of decimal places.
+func spedNumber(v float64, decimals int) string {
+	pow := math.Pow(10, float64(decimals))
+	return strings.Replace(strconv.FormatFloat(math.Round(v*pow)/pow, 'f', -1, 64), ".", ",", 1)
+}
+
+// spedMoney writes an amount in cents with two decimal places and a decimal comma.
+func spedMoney(cents int64) string {
+	return fmt.Sprintf("%d,%02d", cents/100, cents%100)
+}
+
+// latin1 encodes s in ISO-8859-1, the encoding of SPED files; other characters are written as '?'.
+func latin1(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xFF {
+			r = '?'
+		}
+		out = append(out, byte(r))
+	}
+	return out
+}
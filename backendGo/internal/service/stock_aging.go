@@ -0,0 +1,167 @@
+package service
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+)
+
+const (
+	// slowMoverMinAgeDays is the average age, in days, from which a product's stock may be slow moving.
+	slowMoverMinAgeDays = 90
+	// slowMoverMinCoverDays is the days of cover above which stock that old is slow moving.
+	slowMoverMinCoverDays = 180
+)
+
+// stockAgeBucket is a range of days, inclusive; nil bounds are open.
+type stockAgeBucket struct {
+	label    string
+	fromDays *int
+	toDays   *int
+}
+
+func daysBound(days int) *int {
+	return &days
+}
+
+var (
+	// stockAgeBuckets group lotes by days since they entered stock.
+	stockAgeBuckets = []stockAgeBucket{
+		{"0-30", daysBound(0), daysBound(30)},
+		{"31-60", daysBound(31), daysBound(60)},
+		{"61-90", daysBound(61), daysBound(90)},
+		{"91-180", daysBound(91), daysBound(180)},
+		{"181-365", daysBound(181), daysBound(365)},
+		{"365+", daysBound(366), nil},
+	}
+	// stockExpiryBuckets group lotes by days until they expire.
+	stockExpiryBuckets = []stockAgeBucket{
+		{"expired", nil, daysBound(-1)},
+		{"0-30", daysBound(0), daysBound(30)},
+		{"31-90", daysBound(31), daysBound(90)},
+		{"91-180", daysBound(91), daysBound(180)},
+		{"181-365", daysBound(181), daysBound(365)},
+		{"365+", daysBound(366), nil},
+	}
+)
+
+func newAgeBuckets(buckets []stockAgeBucket) []models.StockAgeBucket {
+	result := make([]models.StockAgeBucket, len(buckets))
+	for i, b := range buckets {
+		result[i] = models.StockAgeBucket{Label: b.label, FromDays: b.fromDays, ToDays: b.toDays}
+	}
+	return result
+}
+
+// addToBucket adds a lote to the bucket its days fall in.
+func addToBucket(buckets []models.StockAgeBucket, days int, quantity float64) {
+	for i := range buckets {
+		b := &buckets[i]
+		if (b.FromDays == nil || days >= *b.FromDays) && (b.ToDays == nil || days <= *b.ToDays) {
+			b.Lotes++
+			b.Quantity = roundQuantity(b.Quantity + quantity)
+			return
+		}
+	}
+}
+
+// GetAgingReport buckets every lote in stock by the days since it was created and by the days until
+// it expires, per product and per unit. A product is a slow mover when its stock is on average at
+// least 90 days old and would last more than 180 days at the average daily consumption of the
+// forecast window, or is not being consumed at all.
+func (s *consumptionService) GetAgingReport(forecastDays int, userID int) (*models.StockAgingReport, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	products, err := s.productRepo.GetAll(userID)
+	if err != nil {
+		return nil, err
+	}
+	rates, err := s.GetDailyRates(forecastDays, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.StockAgingReport{
+		GeneratedAt:        now,
+		ForecastWindowDays: forecastDays,
+		Units:              []models.UnitAging{},
+		Products:           []models.ProductAging{},
+	}
+	unitIndex := make(map[string]int)
+	for _, p := range products {
+		if len(p.Lotes) == 0 {
+			continue
+		}
+		pa := models.ProductAging{
+			ProductID:   p.ID,
+			ProductName: p.Name,
+			Unit:        p.Unit,
+			Quantity:    p.Quantity,
+			DailyRate:   rates[p.ID],
+			ByAge:       newAgeBuckets(stockAgeBuckets),
+			ByExpiry:    newAgeBuckets(stockExpiryBuckets),
+		}
+		pa.DaysOfCover, _ = forecastCover(p.Quantity, pa.DailyRate, now)
+
+		i, ok := unitIndex[p.Unit]
+		if !ok {
+			report.Units = append(report.Units, models.UnitAging{
+				Unit:     p.Unit,
+				ByAge:    newAgeBuckets(stockAgeBuckets),
+				ByExpiry: newAgeBuckets(stockExpiryBuckets),
+			})
+			i = len(report.Units) - 1
+			unitIndex[p.Unit] = i
+		}
+		unit := &report.Units[i]
+
+		var weightedAge, lotesQuantity float64
+		for _, l := range p.Lotes {
+			created := l.CreatedAt.In(now.Location())
+			createdDay := time.Date(created.Year(), created.Month(), created.Day(), 0, 0, 0, 0, now.Location())
+			age := int(math.Round(today.Sub(createdDay).Hours() / 24))
+			if age < 0 {
+				age = 0
+			}
+			if age > pa.OldestAgeDays {
+				pa.OldestAgeDays = age
+			}
+			weightedAge += float64(age) * l.Quantity
+			lotesQuantity += l.Quantity
+			addToBucket(pa.ByAge, age, l.Quantity)
+			addToBucket(unit.ByAge, age, l.Quantity)
+
+			if expiry, err := time.ParseInLocation("2006-01-02", dateOnly(l.DataValidade), now.Location()); err == nil {
+				daysToExpiry := int(math.Round(expiry.Sub(today).Hours() / 24))
+				addToBucket(pa.ByExpiry, daysToExpiry, l.Quantity)
+				addToBucket(unit.ByExpiry, daysToExpiry, l.Quantity)
+			}
+		}
+		unit.Quantity = roundQuantity(unit.Quantity + lotesQuantity)
+		if lotesQuantity > 0 {
+			pa.AverageAgeDays = math.Round(weightedAge/lotesQuantity*10) / 10
+		}
+		pa.SlowMover = lotesQuantity > 0 && pa.AverageAgeDays >= slowMoverMinAgeDays &&
+			(pa.DaysOfCover == nil || *pa.DaysOfCover > slowMoverMinCoverDays)
+		if pa.SlowMover {
+			report.SlowMovers++
+		}
+		report.Products = append(report.Products, pa)
+	}
+
+	// Slow movers first, then the oldest stock
+	sort.SliceStable(report.Products, func(i, j int) bool {
+		a, b := report.Products[i], report.Products[j]
+		if a.SlowMover != b.SlowMover {
+			return a.SlowMover
+		}
+		if a.AverageAgeDays != b.AverageAgeDays {
+			return a.AverageAgeDays > b.AverageAgeDays
+		}
+		return a.ProductName < b.ProductName
+	})
+	sort.Slice(report.Units, func(i, j int) bool { return report.Units[i].Unit < report.Units[j].Unit })
+	return report, nil
+}
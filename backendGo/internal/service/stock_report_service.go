@@ -0,0 +1,577 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/repository"
+)
+
+const (
+	StockSourceSnapshot = "snapshot"
+	StockSourceCurrent  = "current"
+)
+
+// StockReportService reconstructs the stock of a user at arbitrary instants
+type StockReportService interface {
+	GetStockAt(at time.Time, userID int) (*models.StockAtReport, error)
+	GetStockDiff(from, to time.Time, userID int) (*models.StockDiffReport, error)
+	TakeSnapshot(userID int) (time.Time, error)
+	TakeSnapshotForAllUsers() (time.Time, error)
+}
+
+type stockReportService struct {
+	historyRepo  repository.HistoryRepository
+	productRepo  repository.ProductRepository
+	snapshotRepo repository.StockSnapshotRepository
+}
+
+// NewStockReportService creates a new StockReportService
+func NewStockReportService(historyRepo repository.HistoryRepository, productRepo repository.ProductRepository, snapshotRepo repository.StockSnapshotRepository) StockReportService {
+	return &stockReportService{
+		historyRepo:  historyRepo,
+		productRepo:  productRepo,
+		snapshotRepo: snapshotRepo,
+	}
+}
+
+// productState and loteState hold the replayable state of a single entity.
+// A product's quantity mirrors products.quantity, which the database trigger
+// overwrites with the sum of the product's lotes whenever one of them changes.
+type productState struct {
+	id       string
+	name     string
+	unit     string
+	quantity float64
+}
+
+type loteState struct {
+	id           string
+	productID    string
+	quantity     float64
+	dataValidade string
+}
+
+type stockState struct {
+	products map[string]*productState
+	lotes    map[string]*loteState
+}
+
+func newStockState() *stockState {
+	return &stockState{
+		products: make(map[string]*productState),
+		lotes:    make(map[string]*loteState),
+	}
+}
+
+// GetStockAt returns the quantity of every product and lote that existed at the given instant.
+// The latest snapshot taken at or before the instant is replayed forward using history;
+// without one, the current stock is replayed backward instead.
+func (s *stockReportService) GetStockAt(at time.Time, userID int) (*models.StockAtReport, error) {
+	snapshots, err := s.snapshotRepo.GetLatestAtOrBefore(at, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stock snapshot: %w", err)
+	}
+
+	report := &models.StockAtReport{Date: at}
+	var state *stockState
+
+	if len(snapshots) > 0 {
+		takenAt := snapshots[0].TakenAt
+		state = stateFromSnapshots(snapshots)
+
+		entries, err := s.historyRepo.GetHistoryInRange(takenAt, at, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load history after snapshot: %w", err)
+		}
+		for _, entry := range entries {
+			state.apply(entry)
+		}
+
+		report.Source = StockSourceSnapshot
+		report.BaseSnapshotAt = &takenAt
+		report.EventsReplayed = len(entries)
+
+		if err := s.fillMissingUnits(state, userID); err != nil {
+			return nil, err
+		}
+	} else {
+		products, err := s.productRepo.GetAll(userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load current products: %w", err)
+		}
+		state = stateFromProducts(products)
+
+		entries, err := s.historyRepo.GetHistoryInRange(at, time.Time{}, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load history after %s: %w", at.Format(time.RFC3339), err)
+		}
+		for i := len(entries) - 1; i >= 0; i-- {
+			state.undo(entries[i])
+		}
+		fillUnitsFromProducts(state, products)
+
+		report.Source = StockSourceCurrent
+		report.EventsReplayed = len(entries)
+	}
+
+	report.Products = state.positions()
+	return report, nil
+}
+
+// fillMissingUnits sets the unit of replayed products whose history did not record it (entries
+// written before units were recorded) from the current products.
+func (s *stockReportService) fillMissingUnits(state *stockState, userID int) error {
+	for _, product := range state.products {
+		if product.unit == "" {
+			products, err := s.productRepo.GetAll(userID)
+			if err != nil {
+				return fmt.Errorf("failed to load current products: %w", err)
+			}
+			fillUnitsFromProducts(state, products)
+			return nil
+		}
+	}
+	return nil
+}
+
+func fillUnitsFromProducts(state *stockState, products []models.Product) {
+	for _, p := range products {
+		if product := state.products[p.ID]; product != nil && product.unit == "" {
+			product.unit = p.Unit
+		}
+	}
+}
+
+// GetStockDiff compares the stock at two instants and lists the products whose
+// quantity, or the quantity of one of their lotes, changed in between.
+func (s *stockReportService) GetStockDiff(from, to time.Time, userID int) (*models.StockDiffReport, error) {
+	if to.Before(from) {
+		return nil, fmt.Errorf("'from' must not be after 'to'")
+	}
+
+	before, err := s.GetStockAt(from, userID)
+	if err != nil {
+		return nil, err
+	}
+	after, err := s.GetStockAt(to, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.StockDiffReport{
+		From:     from,
+		To:       to,
+		Products: diffPositions(before.Products, after.Products),
+	}, nil
+}
+
+// TakeSnapshot stores a snapshot of the user's current stock and returns its timestamp.
+func (s *stockReportService) TakeSnapshot(userID int) (time.Time, error) {
+	takenAt := time.Now()
+	if err := s.snapshotRepo.Create(takenAt, userID); err != nil {
+		return time.Time{}, fmt.Errorf("failed to take stock snapshot: %w", err)
+	}
+	return takenAt, nil
+}
+
+// TakeSnapshotForAllUsers stores a snapshot of every user's current stock; used by the periodic job.
+func (s *stockReportService) TakeSnapshotForAllUsers() (time.Time, error) {
+	takenAt := time.Now()
+	if err := s.snapshotRepo.CreateForAllUsers(takenAt); err != nil {
+		return time.Time{}, fmt.Errorf("failed to take stock snapshot: %w", err)
+	}
+	return takenAt, nil
+}
+
+// replayBase returns the state the retained history is replayed from, and the instant it holds.
+// Without archives that is an empty state and a zero time; otherwise it is the snapshot taken at
+// the archive cutoff (empty if the user had no stock then), and only entries after it remain.
+func replayBase(archiveRepo repository.HistoryArchiveRepository, snapshotRepo repository.StockSnapshotRepository, userID int) (*stockState, time.Time, error) {
+	cutoff, err := archiveRepo.GetCutoff(userID)
+	if err != nil || cutoff.IsZero() {
+		return newStockState(), time.Time{}, err
+	}
+	snapshots, err := snapshotRepo.GetLatestAtOrBefore(cutoff, userID)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to load stock snapshot at archive cutoff: %w", err)
+	}
+	if len(snapshots) == 0 || !snapshots[0].TakenAt.Equal(cutoff) {
+		return newStockState(), cutoff, nil
+	}
+	return stateFromSnapshots(snapshots), cutoff, nil
+}
+
+func stateFromSnapshots(snapshots []models.StockSnapshot) *stockState {
+	state := newStockState()
+	for _, snap := range snapshots {
+		if snap.LoteID == nil {
+			state.products[snap.ProductID] = &productState{
+				id:       snap.ProductID,
+				name:     snap.ProductName,
+				unit:     snap.Unit,
+				quantity: snap.Quantity,
+			}
+			continue
+		}
+		lote := &loteState{id: *snap.LoteID, productID: snap.ProductID, quantity: snap.Quantity}
+		if snap.DataValidade != nil {
+			lote.dataValidade = *snap.DataValidade
+		}
+		state.lotes[lote.id] = lote
+	}
+	return state
+}
+
+func stateFromProducts(products []models.Product) *stockState {
+	state := newStockState()
+	for _, p := range products {
+		state.products[p.ID] = &productState{id: p.ID, name: p.Name, unit: p.Unit, quantity: p.Quantity}
+		for _, l := range p.Lotes {
+			state.lotes[l.ID] = &loteState{
+				id:           l.ID,
+				productID:    p.ID,
+				quantity:     l.Quantity,
+				dataValidade: dateOnly(l.DataValidade),
+			}
+		}
+	}
+	return state
+}
+
+// apply moves the state forward past a history entry.
+func (st *stockState) apply(entry models.History) {
+	switch entry.EntityType {
+	case EntityTypeLote:
+		var detail models.LoteChangeDetail
+		if err := json.Unmarshal(entry.Changes, &detail); err != nil {
+			log.Printf("WARN: stock replay - failed to unmarshal lote change %s: %v", entry.ID, err)
+			return
+		}
+		switch detail.Action {
+		case "created", "updated":
+			lote := st.lotes[entry.EntityID]
+			if lote == nil {
+				lote = &loteState{id: entry.EntityID, productID: detail.ProductID}
+				st.lotes[entry.EntityID] = lote
+			}
+			if detail.QuantityAfter != nil {
+				lote.quantity = *detail.QuantityAfter
+			}
+			if detail.DataValidadeNew != nil {
+				lote.dataValidade = dateOnly(*detail.DataValidadeNew)
+			} else if detail.DataValidade != nil {
+				lote.dataValidade = dateOnly(*detail.DataValidade)
+			}
+		case "deleted":
+			delete(st.lotes, entry.EntityID)
+		}
+		st.syncProductWithLotes(detail.ProductID, true)
+
+	case EntityTypeProduct:
+		var detail models.ProductChange
+		if err := json.Unmarshal(entry.Changes, &detail); err != nil {
+			log.Printf("WARN: stock replay - failed to unmarshal product change %s: %v", entry.ID, err)
+			return
+		}
+		switch detail.Action {
+		case "created":
+			product := &productState{id: entry.EntityID, name: detail.ProductName, unit: detail.Unit}
+			if detail.QuantityAfter != nil {
+				product.quantity = *detail.QuantityAfter
+			}
+			st.products[entry.EntityID] = product
+		case "deleted":
+			delete(st.products, entry.EntityID)
+			st.deleteLotesOf(entry.EntityID)
+		case ProductActionQuantityAdjusted:
+			product := st.products[entry.EntityID]
+			if product == nil {
+				product = &productState{id: entry.EntityID, name: detail.ProductName}
+				st.products[entry.EntityID] = product
+			}
+			if detail.QuantityAfter != nil {
+				product.quantity = *detail.QuantityAfter
+			}
+		default:
+			if product := st.products[entry.EntityID]; product != nil {
+				applyChangedFields(product, detail.ChangedFields, true)
+				// Quantity edits only hold for products without lotes; the lotes decide otherwise
+				if detail.QuantityAfter != nil && !st.hasLotes(entry.EntityID) {
+					product.quantity = *detail.QuantityAfter
+				}
+			}
+		}
+
+	case EntityTypeProductBatchContext:
+		var detail models.ProductBatchContextChangeDetail
+		if err := json.Unmarshal(entry.Changes, &detail); err != nil {
+			log.Printf("WARN: stock replay - failed to unmarshal product batch context %s: %v", entry.ID, err)
+			return
+		}
+		if product := st.products[entry.EntityID]; product != nil && !st.hasLotes(entry.EntityID) {
+			product.quantity = detail.QuantityAfterBatch
+		}
+	}
+}
+
+// undo moves the state backward past a history entry.
+func (st *stockState) undo(entry models.History) {
+	switch entry.EntityType {
+	case EntityTypeLote:
+		var detail models.LoteChangeDetail
+		if err := json.Unmarshal(entry.Changes, &detail); err != nil {
+			log.Printf("WARN: stock replay - failed to unmarshal lote change %s: %v", entry.ID, err)
+			return
+		}
+		switch detail.Action {
+		case "created":
+			delete(st.lotes, entry.EntityID)
+		case "updated", "deleted":
+			lote := st.lotes[entry.EntityID]
+			if lote == nil {
+				lote = &loteState{id: entry.EntityID, productID: detail.ProductID}
+				st.lotes[entry.EntityID] = lote
+			}
+			if detail.QuantityBefore != nil {
+				lote.quantity = *detail.QuantityBefore
+			}
+			if detail.DataValidadeOld != nil {
+				lote.dataValidade = dateOnly(*detail.DataValidadeOld)
+			} else if detail.DataValidade != nil {
+				lote.dataValidade = dateOnly(*detail.DataValidade)
+			}
+		}
+		// Undoing the creation of a product's first lote cannot recover the quantity the
+		// product had on its own; it is left as is unless a batch context says otherwise.
+		st.syncProductWithLotes(detail.ProductID, false)
+
+	case EntityTypeProduct:
+		var detail models.ProductChange
+		if err := json.Unmarshal(entry.Changes, &detail); err != nil {
+			log.Printf("WARN: stock replay - failed to unmarshal product change %s: %v", entry.ID, err)
+			return
+		}
+		switch detail.Action {
+		case "created":
+			delete(st.products, entry.EntityID)
+			st.deleteLotesOf(entry.EntityID)
+		case "deleted":
+			// Lotes removed by ON DELETE CASCADE have no history of their own, so the
+			// product is restored with its total quantity only.
+			product := &productState{id: entry.EntityID, name: detail.ProductName, unit: detail.Unit}
+			if detail.QuantityBefore != nil {
+				product.quantity = *detail.QuantityBefore
+			}
+			st.products[entry.EntityID] = product
+		case ProductActionQuantityAdjusted:
+			// Without QuantityBefore the adjustment only baselined a product that predates
+			// its history, so its earlier quantity is unknown and left as is.
+			if product := st.products[entry.EntityID]; product != nil && detail.QuantityBefore != nil {
+				product.quantity = *detail.QuantityBefore
+			}
+		default:
+			if product := st.products[entry.EntityID]; product != nil {
+				applyChangedFields(product, detail.ChangedFields, false)
+				if detail.QuantityBefore != nil && !st.hasLotes(entry.EntityID) {
+					product.quantity = *detail.QuantityBefore
+				}
+			}
+		}
+
+	case EntityTypeProductBatchContext:
+		var detail models.ProductBatchContextChangeDetail
+		if err := json.Unmarshal(entry.Changes, &detail); err != nil {
+			log.Printf("WARN: stock replay - failed to unmarshal product batch context %s: %v", entry.ID, err)
+			return
+		}
+		if product := st.products[entry.EntityID]; product != nil && !st.hasLotes(entry.EntityID) {
+			product.quantity = detail.QuantityBeforeBatch
+		}
+	}
+}
+
+// syncProductWithLotes mirrors the products.quantity trigger. When force is false the
+// product keeps its quantity if it has no lotes left.
+func (st *stockState) syncProductWithLotes(productID string, force bool) {
+	product := st.products[productID]
+	if product == nil {
+		return
+	}
+	if !force && !st.hasLotes(productID) {
+		return
+	}
+	total := 0.0
+	for _, lote := range st.lotes {
+		if lote.productID == productID {
+			total += lote.quantity
+		}
+	}
+	product.quantity = total
+}
+
+func (st *stockState) hasLotes(productID string) bool {
+	for _, lote := range st.lotes {
+		if lote.productID == productID {
+			return true
+		}
+	}
+	return false
+}
+
+func (st *stockState) deleteLotesOf(productID string) {
+	for id, lote := range st.lotes {
+		if lote.productID == productID {
+			delete(st.lotes, id)
+		}
+	}
+}
+
+// applyChangedFields applies the new (forward) or old (backward) values of name/unit changes.
+func applyChangedFields(product *productState, fields []models.ChangedField, forward bool) {
+	for _, field := range fields {
+		value := field.OldValue
+		if forward {
+			value = field.NewValue
+		}
+		str, ok := value.(string)
+		if !ok {
+			continue
+		}
+		switch field.Field {
+		case "name":
+			product.name = str
+		case "unit":
+			product.unit = str
+		}
+	}
+}
+
+// positions converts the state into a list sorted by product name, with lotes sorted by expiry.
+func (st *stockState) positions() []models.ProductStockPosition {
+	lotesByProduct := make(map[string][]models.LoteStockPosition)
+	for _, lote := range st.lotes {
+		if _, ok := st.products[lote.productID]; !ok {
+			continue
+		}
+		lotesByProduct[lote.productID] = append(lotesByProduct[lote.productID], models.LoteStockPosition{
+			LoteID:       lote.id,
+			ProductID:    lote.productID,
+			Quantity:     lote.quantity,
+			DataValidade: lote.dataValidade,
+		})
+	}
+
+	positions := make([]models.ProductStockPosition, 0, len(st.products))
+	for _, product := range st.products {
+		lotes := lotesByProduct[product.id]
+		if lotes == nil {
+			lotes = []models.LoteStockPosition{}
+		}
+		sort.Slice(lotes, func(i, j int) bool {
+			if lotes[i].DataValidade != lotes[j].DataValidade {
+				return lotes[i].DataValidade < lotes[j].DataValidade
+			}
+			return lotes[i].LoteID < lotes[j].LoteID
+		})
+		positions = append(positions, models.ProductStockPosition{
+			ProductID:   product.id,
+			ProductName: product.name,
+			Unit:        product.unit,
+			Quantity:    product.quantity,
+			Lotes:       lotes,
+		})
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		if positions[i].ProductName != positions[j].ProductName {
+			return positions[i].ProductName < positions[j].ProductName
+		}
+		return positions[i].ProductID < positions[j].ProductID
+	})
+	return positions
+}
+
+// diffPositions pairs products and lotes by ID; an entity missing on one side counts as zero.
+func diffPositions(before, after []models.ProductStockPosition) []models.ProductStockDiff {
+	type pair struct {
+		from, to *models.ProductStockPosition
+	}
+	pairs := make(map[string]*pair)
+	var order []string
+	for i := range before {
+		p := &before[i]
+		pairs[p.ProductID] = &pair{from: p}
+		order = append(order, p.ProductID)
+	}
+	for i := range after {
+		p := &after[i]
+		if existing, ok := pairs[p.ProductID]; ok {
+			existing.to = p
+		} else {
+			pairs[p.ProductID] = &pair{to: p}
+			order = append(order, p.ProductID)
+		}
+	}
+
+	diffs := []models.ProductStockDiff{}
+	for _, productID := range order {
+		pr := pairs[productID]
+		diff := models.ProductStockDiff{ProductID: productID, Lotes: []models.LoteStockDiff{}}
+
+		lotes := make(map[string]*models.LoteStockDiff)
+		onBothSides := make(map[string]bool)
+		var loteOrder []string
+		if pr.from != nil {
+			diff.ProductName, diff.Unit, diff.QuantityFrom = pr.from.ProductName, pr.from.Unit, pr.from.Quantity
+			for _, l := range pr.from.Lotes {
+				lotes[l.LoteID] = &models.LoteStockDiff{LoteID: l.LoteID, ProductID: productID, DataValidade: l.DataValidade, QuantityFrom: l.Quantity}
+				loteOrder = append(loteOrder, l.LoteID)
+			}
+		}
+		if pr.to != nil {
+			diff.ProductName, diff.Unit, diff.QuantityTo = pr.to.ProductName, pr.to.Unit, pr.to.Quantity
+			for _, l := range pr.to.Lotes {
+				if existing, ok := lotes[l.LoteID]; ok {
+					existing.QuantityTo = l.Quantity
+					existing.DataValidade = l.DataValidade
+					onBothSides[l.LoteID] = true
+				} else {
+					lotes[l.LoteID] = &models.LoteStockDiff{LoteID: l.LoteID, ProductID: productID, DataValidade: l.DataValidade, QuantityTo: l.Quantity}
+					loteOrder = append(loteOrder, l.LoteID)
+				}
+			}
+		}
+
+		for _, loteID := range loteOrder {
+			l := lotes[loteID]
+			l.Delta = l.QuantityTo - l.QuantityFrom
+			if !isZeroQuantity(l.Delta) || !onBothSides[loteID] {
+				diff.Lotes = append(diff.Lotes, *l)
+			}
+		}
+		diff.Delta = diff.QuantityTo - diff.QuantityFrom
+
+		if !isZeroQuantity(diff.Delta) || len(diff.Lotes) > 0 || pr.from == nil || pr.to == nil {
+			diffs = append(diffs, diff)
+		}
+	}
+	return diffs
+}
+
+// isZeroQuantity treats float noise from NUMERIC -> float64 conversions as no change.
+func isZeroQuantity(q float64) bool {
+	return math.Abs(q) < 1e-9
+}
+
+// dateOnly trims a DATE scanned as a timestamp (e.g. "2025-01-31T00:00:00Z") down to YYYY-MM-DD.
+func dateOnly(s string) string {
+	if len(s) >= 10 {
+		return s[:10]
+	}
+	return s
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrUsernameTaken = errors.New("username is already in use")
+	ErrEmailTaken    = errors.New("email is already in use")
+	ErrProtectedUser = errors.New("the admin account cannot be disabled, deleted or lose the admin role")
+	ErrWrongPassword = errors.New("current password is incorrect")
+	ErrNewAccount    = errors.New("only the admin account can create users with accounts of their own")
+)
+
+// UserService manages the users of an account on behalf of its admins, and lets users change
+// their own password. Users of other accounts are reported as missing.
+type UserService interface {
+	List(accountID int) ([]models.User, error)
+	Get(id, accountID int) (*models.User, error)
+	Create(input models.UserCreateInput, accountID int) (*models.User, error)
+	Update(id int, input models.UserUpdateInput, accountID int) (*models.User, error)
+	SetActive(id int, active bool, accountID int) (*models.User, error)
+	Delete(id, accountID int) (bool, error)
+	ChangePassword(userID, accountID int, input models.PasswordChangeInput) (bool, error)
+}
+
+type userService struct {
+	repo          repository.UserRepository
+	archiveRepo   repository.HistoryArchiveRepository
+	archiveDir    string
+	adminUsername string
+}
+
+// NewUserService creates a new UserService. The account named adminUsername, created at
+// startup, cannot be disabled, deleted or lose the admin role, so an admin always remains.
+func NewUserService(repo repository.UserRepository, archiveRepo repository.HistoryArchiveRepository, archiveDir, adminUsername string) UserService {
+	return &userService{
+		repo:          repo,
+		archiveRepo:   archiveRepo,
+		archiveDir:    archiveDir,
+		adminUsername: adminUsername,
+	}
+}
+
+// optionalText trims a text field, an empty one meaning none.
+func optionalText(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*s)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
+
+// userError maps the unique violations of the users table to their service errors.
+func userError(err error) error {
+	switch {
+	case repository.IsDuplicateUsername(err):
+		return ErrUsernameTaken
+	case repository.IsDuplicateEmail(err):
+		return ErrEmailTaken
+	}
+	return err
+}
+
+// List retrieves the users of the account, by username.
+func (s *userService) List(accountID int) ([]models.User, error) {
+	return s.repo.List(accountID)
+}
+
+// Get retrieves a user of the account, or nil if there is no such user.
+func (s *userService) Get(id, accountID int) (*models.User, error) {
+	return s.repo.GetByID(id, accountID)
+}
+
+// Create creates an active user, an operator unless another role is given, as a member of the
+// account owned by accountID: the user works on its products, lotes and history. With NewAccount,
+// which only the admin account may ask, the user is instead an admin unless another role is given
+// and owns a new, empty account.
+func (s *userService) Create(input models.UserCreateInput, accountID int) (*models.User, error) {
+	if input.NewAccount {
+		protected, _, err := s.isProtected(accountID, accountID)
+		if err != nil {
+			return nil, err
+		}
+		if !protected {
+			return nil, ErrNewAccount
+		}
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	user := &models.User{
+		Username: strings.TrimSpace(input.Username),
+		Password: string(hash),
+		Email:    optionalText(input.Email),
+		FullName: optionalText(input.FullName),
+		Role:     input.Role,
+	}
+	if user.Role == "" {
+		user.Role = models.RoleOperator
+		if input.NewAccount {
+			user.Role = models.RoleAdmin
+		}
+	}
+	if input.NewAccount {
+		accountID = 0
+	}
+	if err := s.repo.Create(user, accountID); err != nil {
+		return nil, userError(err)
+	}
+	return user, nil
+}
+
+// Update replaces a user's email and full name and, when given, the role and the password, in one
+// statement; nil if the account has no such user. Tokens issued with the previous role or password stop
+// being accepted.
+func (s *userService) Update(id int, input models.UserUpdateInput, accountID int) (*models.User, error) {
+	user := &models.User{ID: id, Email: optionalText(input.Email), FullName: optionalText(input.FullName)}
+	if input.Role != nil {
+		protected, _, err := s.isProtected(id, accountID)
+		if err != nil {
+			return nil, err
+		}
+		if protected && *input.Role != models.RoleAdmin {
+			return nil, ErrProtectedUser
+		}
+		user.Role = *input.Role
+	}
+	var passwordHash string
+	if input.Password != nil {
+		hash, err := bcrypt.GenerateFromPassword([]byte(*input.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		passwordHash = string(hash)
+	}
+	found, err := s.repo.Update(user, passwordHash, accountID)
+	if err != nil || !found {
+		return nil, userError(err)
+	}
+	return user, nil
+}
+
+// isProtected reports whether the user of the account is the admin account, which must stay
+// usable. The user is nil if the account has no such user.
+func (s *userService) isProtected(id, accountID int) (bool, *models.User, error) {
+	user, err := s.repo.GetByID(id, accountID)
+	if err != nil || user == nil {
+		return false, nil, err
+	}
+	return user.Username == s.adminUsername, user, nil
+}
+
+// SetActive enables or disables a user of the account, returning nil if there is no such user. Disabled users
+// cannot sign in, and their tokens stop being accepted.
+func (s *userService) SetActive(id int, active bool, accountID int) (*models.User, error) {
+	protected, user, err := s.isProtected(id, accountID)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	if protected && !active {
+		return nil, ErrProtectedUser
+	}
+	if _, err := s.repo.SetActive(id, active, accountID); err != nil {
+		return nil, err
+	}
+	user.Active = active
+	return user, nil
+}
+
+// Delete removes a user of the account with everything the user owns, including the files of the archived
+// history and the members of the user's account, and reports whether the user existed. Members
+// own nothing: removing one leaves the account's data in place.
+func (s *userService) Delete(id, accountID int) (bool, error) {
+	protected, user, err := s.isProtected(id, accountID)
+	if err != nil || user == nil {
+		return false, err
+	}
+	if protected {
+		return false, ErrProtectedUser
+	}
+	archives, err := s.archiveRepo.List(id)
+	if err != nil {
+		return false, err
+	}
+
+	found, err := s.repo.Delete(id, accountID)
+	if err != nil || !found {
+		return found, err
+	}
+	for _, archive := range archives {
+		if err := os.Remove(filepath.Join(s.archiveDir, archive.FileName)); err != nil && !os.IsNotExist(err) {
+			log.Printf("WARN: failed to remove history archive %s of deleted user %d: %v", archive.FileName, id, err)
+		}
+	}
+	return true, nil
+}
+
+// ChangePassword replaces the user's password after checking the current one, and reports whether
+// the user exists. Every token of the user, including the caller's, stops being accepted.
+func (s *userService) ChangePassword(userID, accountID int, input models.PasswordChangeInput) (bool, error) {
+	user, err := s.repo.GetByID(userID, accountID)
+	if err != nil || user == nil {
+		return false, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.CurrentPassword)); err != nil {
+		return true, ErrWrongPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(input.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return true, err
+	}
+	return s.repo.SetPassword(userID, string(hash), accountID)
+}
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -9,6 +11,16 @@ import (
 	"time"
 
 	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/config"
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/models"
+)
+
+const (
+    backupDir = "backups"
+    // backupStatusFile records the outcome of the latest backup attempt, next to the backups
+    backupStatusFile = "last-backup.json"
+    // BackupStaleAfter is how old the latest backup may get before it is reported as stale;
+    // backups run weekly, so a missed run shows up after a day of slack.
+    BackupStaleAfter = 8 * 24 * time.Hour
 )
 
 // BackupManager handles database backups
@@ -23,10 +35,17 @@ func NewBackupManager(cfg *config.Config) *BackupManager {
     }
 }
 
-// CreateBackup creates a database backup
+// CreateBackup creates a database backup and records the outcome for Status
 func (bm *BackupManager) CreateBackup() (string, error) {
+    backupFile, err := bm.createBackup()
+    if recordErr := bm.recordAttempt(backupFile, err); recordErr != nil {
+        log.Printf("Failed to record backup status: %v", recordErr)
+    }
+    return backupFile, err
+}
+
+func (bm *BackupManager) createBackup() (string, error) {
     // Create backup directory if it doesn't exist
-    backupDir := "backups"
     if err := os.MkdirAll(backupDir, 0755); err != nil {
         return "", fmt.Errorf("failed to create backup directory: %w", err)
     }
@@ -68,6 +87,100 @@ func (bm *BackupManager) CreateBackup() (string, error) {
     return backupFile, nil
 }
 
+// recordAttempt saves the outcome of a backup attempt, keeping the last success of earlier attempts
+func (bm *BackupManager) recordAttempt(backupFile string, backupErr error) error {
+    status, err := readBackupStatus()
+    if err != nil {
+        status = &models.BackupStatus{}
+    }
+    now := time.Now()
+    status.LastAttemptAt = &now
+    status.LastError = ""
+    if backupErr != nil {
+        status.LastError = backupErr.Error()
+    } else {
+        status.LastSuccessAt = &now
+        status.LastFile = filepath.Base(backupFile)
+        status.SizeBytes = 0
+        if info, err := os.Stat(backupFile); err == nil {
+            status.SizeBytes = info.Size()
+        }
+    }
+
+    data, err := json.MarshalIndent(status, "", "  ")
+    if err != nil {
+        return fmt.Errorf("failed to encode backup status: %w", err)
+    }
+    if err := os.WriteFile(filepath.Join(backupDir, backupStatusFile), data, 0644); err != nil {
+        return fmt.Errorf("failed to write backup status: %w", err)
+    }
+    return nil
+}
+
+// Status reports the outcome of the latest backup. Without a recorded attempt (backups made before
+// attempts were recorded), the newest backup file in the backup directory is taken as the last success.
+func (bm *BackupManager) Status() (models.BackupStatus, error) {
+    status, err := readBackupStatus()
+    if errors.Is(err, os.ErrNotExist) {
+        status, err = latestBackupFile()
+    }
+    if err != nil {
+        return models.BackupStatus{Status: "never"}, err
+    }
+
+    switch {
+    case status.LastError != "":
+        status.Status = "failed"
+    case status.LastSuccessAt == nil:
+        status.Status = "never"
+    case time.Since(*status.LastSuccessAt) > BackupStaleAfter:
+        status.Status = "stale"
+    default:
+        status.Status = "ok"
+    }
+    return *status, nil
+}
+
+func readBackupStatus() (*models.BackupStatus, error) {
+    data, err := os.ReadFile(filepath.Join(backupDir, backupStatusFile))
+    if err != nil {
+        return nil, err
+    }
+    var status models.BackupStatus
+    if err := json.Unmarshal(data, &status); err != nil {
+        return nil, fmt.Errorf("failed to decode backup status: %w", err)
+    }
+    return &status, nil
+}
+
+// latestBackupFile describes the newest backup file as the last successful backup.
+func latestBackupFile() (*models.BackupStatus, error) {
+    entries, err := os.ReadDir(backupDir)
+    if errors.Is(err, os.ErrNotExist) {
+        return &models.BackupStatus{}, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to read backup directory: %w", err)
+    }
+
+    status := &models.BackupStatus{}
+    for _, entry := range entries {
+        if entry.IsDir() || filepath.Ext(entry.Name()) != ".sql" {
+            continue
+        }
+        info, err := entry.Info()
+        if err != nil {
+            continue
+        }
+        if modTime := info.ModTime(); status.LastSuccessAt == nil || modTime.After(*status.LastSuccessAt) {
+            status.LastSuccessAt = &modTime
+            status.LastFile = entry.Name()
+            status.SizeBytes = info.Size()
+        }
+    }
+    return status, nil
+}
+
 // cleanupOldBackups removes backups older than 30 days
 func (bm *BackupManager) cleanupOldBackups(backupDir string) error {
     // Keep backups for 30 days
@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/Parron01/GerenciadorEstoque/backendGo/internal/config"
+	"github.com/google/uuid"
+)
+
+// ErrSMTPNotConfigured is returned when an email is sent without an SMTP server configured.
+var ErrSMTPNotConfigured = errors.New("SMTP server not configured (set SMTP_HOST)")
+
+// EmailAttachment is a file attached to an email.
+type EmailAttachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// EmailMessage is an HTML email with optional attachments.
+type EmailMessage struct {
+	To          []string
+	Subject     string
+	HTML        string
+	Attachments []EmailAttachment
+}
+
+// Mailer sends emails through the configured SMTP server
+type Mailer struct {
+	Config config.SMTPConfig
+}
+
+// NewMailer creates a new mailer
+func NewMailer(cfg config.SMTPConfig) *Mailer {
+	return &Mailer{Config: cfg}
+}
+
+// Enabled reports whether an SMTP server is configured.
+func (m *Mailer) Enabled() bool {
+	return m.Config.Host != ""
+}
+
+// Send delivers the message to all of its recipients in one SMTP transaction.
+func (m *Mailer) Send(msg EmailMessage) error {
+	if !m.Enabled() {
+		return ErrSMTPNotConfigured
+	}
+	if len(msg.To) == 0 {
+		return errors.New("email has no recipients")
+	}
+	body, err := m.buildMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(m.Config.Host, m.Config.Port)
+	tlsConfig := &tls.Config{ServerName: m.Config.Host}
+	var conn net.Conn
+	if m.Config.TLS == "tls" {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, 30*time.Second)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server %s: %w", addr, err)
+	}
+	conn.SetDeadline(time.Now().Add(2 * time.Minute))
+
+	client, err := smtp.NewClient(conn, m.Config.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if m.Config.TLS == "starttls" {
+		// Never fall back to plaintext when TLS was asked for
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("SMTP server %s does not offer STARTTLS", addr)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if m.Config.Username != "" {
+		// PlainAuth refuses to send the password over an unencrypted connection, except to localhost
+		if err := client.Auth(smtp.PlainAuth("", m.Config.Username, m.Config.Password, m.Config.Host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+	if err := client.Mail(m.Config.From); err != nil {
+		return fmt.Errorf("SMTP server rejected sender %s: %w", m.Config.From, err)
+	}
+	for _, to := range msg.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("SMTP server rejected recipient %s: %w", to, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return client.Quit()
+}
+
+// buildMessage renders the message as MIME: the HTML body, quoted-printable, followed by the
+// attachments in base64.
+func (m *Mailer) buildMessage(msg EmailMessage) ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	headers := []string{
+		"From: " + m.Config.From,
+		"To: " + strings.Join(msg.To, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"Message-ID: <" + uuid.NewString() + "@" + m.Config.Host + ">",
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/mixed; boundary=" + mw.Boundary(),
+	}
+	buf.WriteString(strings.Join(headers, "\r\n") + "\r\n\r\n")
+
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/html; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build email: %w", err)
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write([]byte(msg.HTML)); err != nil {
+		return nil, fmt.Errorf("failed to build email: %w", err)
+	}
+	if err := qp.Close(); err != nil {
+		return nil, fmt.Errorf("failed to build email: %w", err)
+	}
+
+	for _, a := range msg.Attachments {
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {a.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Name})},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to build email: %w", err)
+		}
+		encoded := base64.StdEncoding.EncodeToString(a.Data)
+		for len(encoded) > 76 {
+			part.Write([]byte(encoded[:76] + "\r\n"))
+			encoded = encoded[76:]
+		}
+		part.Write([]byte(encoded + "\r\n"))
+	}
+	if err := mw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to build email: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,250 @@
+package utils
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// A4 page size, in points.
+const (
+	PDFPageWidth  = 595.28
+	PDFPageHeight = 841.89
+)
+
+// PDFDocument builds a PDF of A4 pages with text, lines and shaded boxes in the standard Helvetica
+// fonts, which every viewer has, so no font needs to be embedded. Coordinates are in points from
+// the top-left corner of the page. Text is encoded as WinAnsi (Windows-1252), which covers
+// Portuguese; other characters are written as '?'.
+type PDFDocument struct {
+	title   string
+	pages   []*bytes.Buffer
+	current int
+}
+
+// NewPDFDocument starts an empty document with the given title, shown by viewers.
+func NewPDFDocument(title string) *PDFDocument {
+	return &PDFDocument{title: title, current: -1}
+}
+
+// AddPage appends a blank page and makes it the current one.
+func (d *PDFDocument) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.current = len(d.pages) - 1
+}
+
+// PageCount returns the number of pages so far.
+func (d *PDFDocument) PageCount() int {
+	return len(d.pages)
+}
+
+// SetPage makes the given page (1-based) the current one, e.g. to number the pages once the
+// document is laid out.
+func (d *PDFDocument) SetPage(page int) {
+	if page >= 1 && page <= len(d.pages) {
+		d.current = page - 1
+	}
+}
+
+func (d *PDFDocument) content() *bytes.Buffer {
+	if d.current < 0 {
+		d.AddPage()
+	}
+	return d.pages[d.current]
+}
+
+// Text writes s with its baseline at y, starting at x.
+func (d *PDFDocument) Text(x, y, size float64, bold bool, s string) {
+	if s == "" {
+		return
+	}
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.content(), "BT /%s %s Tf %s %s Td (%s) Tj ET\n", font, pdfNumber(size), pdfNumber(x), pdfNumber(PDFPageHeight-y), pdfString(s))
+}
+
+// TextRight writes s with its baseline at y, ending at x.
+func (d *PDFDocument) TextRight(x, y, size float64, bold bool, s string) {
+	d.Text(x-PDFTextWidth(s, size, bold), y, size, bold, s)
+}
+
+// Line draws a line of the given width between two points.
+func (d *PDFDocument) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(d.content(), "%s w %s %s m %s %s l S\n", pdfNumber(width),
+		pdfNumber(x1), pdfNumber(PDFPageHeight-y1), pdfNumber(x2), pdfNumber(PDFPageHeight-y2))
+}
+
+// FillRect fills a box whose top-left corner is at x, y with a shade of gray (0 black, 1 white).
+func (d *PDFDocument) FillRect(x, y, width, height, gray float64) {
+	fmt.Fprintf(d.content(), "%s g %s %s %s %s re f 0 g\n", pdfNumber(gray),
+		pdfNumber(x), pdfNumber(PDFPageHeight-y-height), pdfNumber(width), pdfNumber(height))
+}
+
+// WriteTo writes the finished document to w.
+func (d *PDFDocument) WriteTo(w io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	// Objects 1-4 are the catalog, the page tree and the two fonts; each page then takes two
+	// objects, the page and its content stream.
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfNumber(PDFPageWidth), pdfNumber(PDFPageHeight), 6+2*i))
+
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		if _, err := zw.Write(page.Bytes()); err != nil {
+			return 0, fmt.Errorf("failed to compress page %d: %w", i+1, err)
+		}
+		if err := zw.Close(); err != nil {
+			return 0, fmt.Errorf("failed to compress page %d: %w", i+1, err)
+		}
+		object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", compressed.Len(), compressed.String()))
+	}
+	object(fmt.Sprintf("<< /Title (%s) /Producer (GerenciadorEstoque) /CreationDate (D:%s) >>",
+		pdfString(d.title), time.Now().Format("20060102150405")))
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, len(offsets), xref)
+
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+// PDFTextWidth returns the width of s, in points, at the given font size.
+func PDFTextWidth(s string, size float64, bold bool) float64 {
+	widths := &helveticaWidths
+	if bold {
+		widths = &helveticaBoldWidths
+	}
+	total := 0
+	for _, r := range s {
+		r = pdfBaseLetter(r)
+		switch {
+		case r >= 32 && r <= 126:
+			total += widths[r-32]
+		case r == '…' || r == '—':
+			total += 1000
+		default:
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// PDFFitText shortens s with an ellipsis so that it is at most maxWidth points wide.
+func PDFFitText(s string, size float64, bold bool, maxWidth float64) string {
+	if PDFTextWidth(s, size, bold) <= maxWidth {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		if candidate := strings.TrimRight(string(runes), " ") + "…"; PDFTextWidth(candidate, size, bold) <= maxWidth {
+			return candidate
+		}
+	}
+	return ""
+}
+
+func pdfNumber(v float64) string {
+	s := fmt.Sprintf("%.2f", v)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "" || s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// pdfString encodes s as the body of a PDF literal string in WinAnsiEncoding.
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		c, ok := winAnsi(r)
+		if !ok {
+			c = '?'
+		}
+		switch c {
+		case '(', ')', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n', '\r', '\t':
+			b.WriteByte(' ')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// winAnsiExtra are the characters of Windows-1252 outside Latin-1 that reports are likely to use.
+var winAnsiExtra = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92,
+	'“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+func winAnsi(r rune) (byte, bool) {
+	if r < 0x80 || (r >= 0xA0 && r <= 0xFF) {
+		return byte(r), true
+	}
+	c, ok := winAnsiExtra[r]
+	return c, ok
+}
+
+// pdfBaseLetter maps accented Latin-1 letters to the unaccented letter of the same width.
+func pdfBaseLetter(r rune) rune {
+	if r < 0xC0 || r > 0xFF {
+		return r
+	}
+	const upper = "AAAAAAACEEEEIIIIDNOOOOOxOUUUUYPs"
+	const lower = "aaaaaaaceeeeiiiidnooooo/ouuuuypy"
+	if r < 0xE0 {
+		return rune(upper[r-0xC0])
+	}
+	return rune(lower[r-0xE0])
+}
+
+// Advance widths of the printable ASCII characters (32-126), in thousandths of the font size,
+// from the Adobe font metrics of Helvetica and Helvetica-Bold.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
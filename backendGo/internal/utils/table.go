@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// TableWriter writes tabular exports row by row.
+type TableWriter interface {
+	WriteHeader(cells []string) error
+	WriteRow(cells []interface{}) error
+	Close() error
+}
+
+// Export formats accepted by NewTableWriter.
+const (
+	TableFormatCSV  = "csv"
+	TableFormatXLSX = "xlsx"
+)
+
+// TableContentType returns the MIME type of an export format.
+func TableContentType(format string) string {
+	if format == TableFormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// NewTableWriter creates a TableWriter for the format ("csv" or "xlsx") on w.
+// sheetName and columnWidths are only used by XLSX.
+func NewTableWriter(format string, w io.Writer, sheetName string, columnWidths ...float64) (TableWriter, error) {
+	switch format {
+	case TableFormatCSV:
+		return NewCSVWriter(w)
+	case TableFormatXLSX:
+		return NewXLSXWriter(w, sheetName, columnWidths...)
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// CSVWriter writes CSV the way a pt-BR spreadsheet opens it directly: a UTF-8 byte order mark,
+// ';' as separator and ',' as decimal separator.
+type CSVWriter struct {
+	w *csv.Writer
+}
+
+// NewCSVWriter starts a CSV file on w.
+func NewCSVWriter(w io.Writer) (*CSVWriter, error) {
+	if _, err := io.WriteString(w, "\uFEFF"); err != nil {
+		return nil, err
+	}
+	cw := csv.NewWriter(w)
+	cw.Comma = ';'
+	return &CSVWriter{w: cw}, nil
+}
+
+// WriteHeader writes the header row.
+func (c *CSVWriter) WriteHeader(cells []string) error {
+	return c.w.Write(cells)
+}
+
+// WriteRow writes a row, with the same cell types as XLSXWriter.WriteRow.
+func (c *CSVWriter) WriteRow(cells []interface{}) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		if number, ok := cellNumber(cell); ok {
+			record[i] = strings.Replace(strconv.FormatFloat(number, 'f', -1, 64), ".", ",", 1)
+			continue
+		}
+		record[i] = cellText(cell)
+	}
+	return c.w.Write(record)
+}
+
+// Close flushes the buffered rows. It does not close the underlying writer.
+func (c *CSVWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
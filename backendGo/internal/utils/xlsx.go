@@ -0,0 +1,209 @@
+package utils
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// XLSXWriter streams a single-sheet XLSX workbook row by row, so large exports never have to
+// be held in memory. Strings are written as inline strings, numbers as numeric cells.
+type XLSXWriter struct {
+	zw    *zip.Writer
+	sheet *bufio.Writer
+	rows  int
+	err   error
+}
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`
+
+// xlsxStyles defines the default cell format (0) and a bold one (1) used for the header row.
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>
+</styleSheet>`
+
+// NewXLSXWriter starts a workbook with one sheet of the given name on w, optionally setting the
+// width of the first columns (in characters). Close must be called to finish the file.
+func NewXLSXWriter(w io.Writer, sheetName string, columnWidths ...float64) (*XLSXWriter, error) {
+	zw := zip.NewWriter(w)
+	workbook := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="` + xmlEscape(sheetName) + `" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", workbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for _, part := range parts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", part.name, err)
+		}
+		if _, err := io.WriteString(f, part.body); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", part.name, err)
+		}
+	}
+
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create worksheet: %w", err)
+	}
+	x := &XLSXWriter{zw: zw, sheet: bufio.NewWriter(f)}
+	x.write(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	if len(columnWidths) > 0 {
+		x.write(`<cols>`)
+		for i, width := range columnWidths {
+			x.write(fmt.Sprintf(`<col min="%d" max="%d" width="%g" customWidth="1"/>`, i+1, i+1, width))
+		}
+		x.write(`</cols>`)
+	}
+	x.write(`<sheetData>`)
+	return x, x.err
+}
+
+// WriteHeader writes a row in bold.
+func (x *XLSXWriter) WriteHeader(cells []string) error {
+	values := make([]interface{}, len(cells))
+	for i, cell := range cells {
+		values[i] = cell
+	}
+	return x.writeRow(values, 1)
+}
+
+// WriteRow writes a row. Cells may be strings, ints, float64s, *float64s (nil for an empty cell) or nil.
+func (x *XLSXWriter) WriteRow(cells []interface{}) error {
+	return x.writeRow(cells, 0)
+}
+
+func (x *XLSXWriter) writeRow(cells []interface{}, style int) error {
+	x.rows++
+	x.write(fmt.Sprintf(`<row r="%d">`, x.rows))
+	for i, cell := range cells {
+		ref := xlsxColumn(i) + strconv.Itoa(x.rows)
+		styleAttr := ""
+		if style != 0 {
+			styleAttr = fmt.Sprintf(` s="%d"`, style)
+		}
+		if number, ok := cellNumber(cell); ok {
+			x.write(`<c r="` + ref + `"` + styleAttr + `><v>` + strconv.FormatFloat(number, 'f', -1, 64) + `</v></c>`)
+			continue
+		}
+		text := cellText(cell)
+		if text == "" {
+			continue
+		}
+		x.write(`<c r="` + ref + `"` + styleAttr + ` t="inlineStr"><is><t xml:space="preserve">` + xmlEscape(text) + `</t></is></c>`)
+	}
+	x.write(`</row>`)
+	return x.err
+}
+
+// Close finishes the sheet and the workbook. It does not close the underlying writer.
+func (x *XLSXWriter) Close() error {
+	x.write(`</sheetData></worksheet>`)
+	if x.err == nil {
+		x.err = x.sheet.Flush()
+	}
+	if err := x.zw.Close(); x.err == nil && err != nil {
+		x.err = err
+	}
+	return x.err
+}
+
+func (x *XLSXWriter) write(s string) {
+	if x.err == nil {
+		_, x.err = x.sheet.WriteString(s)
+	}
+}
+
+// xlsxColumn returns the column letters of a zero-based column index (0 is A, 26 is AA).
+func xlsxColumn(i int) string {
+	name := ""
+	for i >= 0 {
+		name = string(rune('A'+i%26)) + name
+		i = i/26 - 1
+	}
+	return name
+}
+
+// cellNumber returns the numeric value of a cell, if it holds a finite number.
+func cellNumber(cell interface{}) (float64, bool) {
+	var number float64
+	switch v := cell.(type) {
+	case int:
+		number = float64(v)
+	case int64:
+		number = float64(v)
+	case float64:
+		number = v
+	case *float64:
+		if v == nil {
+			return 0, false
+		}
+		number = *v
+	default:
+		return 0, false
+	}
+	return number, !math.IsNaN(number) && !math.IsInf(number, 0)
+}
+
+// cellText returns the text of a non-numeric cell.
+func cellText(cell interface{}) string {
+	switch v := cell.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case *string:
+		if v == nil {
+			return ""
+		}
+		return *v
+	case bool:
+		if v {
+			return "Sim"
+		}
+		return "Não"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// xmlEscape escapes text for XML, replacing characters XML cannot represent.
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s)) // Writing to a strings.Builder never fails
+	return b.String()
+}
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_stock_snapshots_user_id_taken_at;
+DROP TABLE IF EXISTS stock_snapshots;
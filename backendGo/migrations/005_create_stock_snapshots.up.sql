@@ -0,0 +1,20 @@
+-- Periodic copies of products and lotes, used as starting points when
+-- reconstructing stock at a past instant (GET /api/reports/stock-at).
+-- Rows with lote_id NULL hold the product's own quantity; rows with a
+-- lote_id hold that lote's quantity. No FK to products/product_lots on
+-- purpose: snapshots must survive the deletion of the entities they describe.
+CREATE TABLE IF NOT EXISTS stock_snapshots (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    taken_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    product_id VARCHAR(100) NOT NULL,
+    product_name VARCHAR(100) NOT NULL,
+    unit VARCHAR(10) NOT NULL,
+    lote_id UUID,
+    quantity NUMERIC NOT NULL,
+    data_validade DATE,
+    CONSTRAINT fk_stock_snapshots_user_id
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_snapshots_user_id_taken_at ON stock_snapshots(user_id, taken_at);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_products_name_trgm;
+DROP INDEX IF EXISTS idx_history_product_name_trgm;
+DROP INDEX IF EXISTS idx_history_product_id;
+DROP INDEX IF EXISTS idx_history_action;
+DROP INDEX IF EXISTS idx_history_user_id_entity_type;
+DROP INDEX IF EXISTS idx_history_user_id_date;
+-- pg_trgm is left installed; other objects may depend on it.
@@ -0,0 +1,9 @@
+-- Indexes backing the history filters (date range, entity type, action, product, product name)
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_history_user_id_date ON history(user_id, date);
+CREATE INDEX IF NOT EXISTS idx_history_user_id_entity_type ON history(user_id, entity_type);
+CREATE INDEX IF NOT EXISTS idx_history_action ON history((changes->>'action'));
+CREATE INDEX IF NOT EXISTS idx_history_product_id ON history((changes->>'productId'));
+CREATE INDEX IF NOT EXISTS idx_history_product_name_trgm ON history USING GIN ((changes->>'productName') gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_products_name_trgm ON products USING GIN (name gin_trgm_ops);
@@ -0,0 +1,9 @@
+-- Back to the RFC3339 text representation, written in UTC.
+DROP INDEX IF EXISTS idx_history_user_id_date;
+
+ALTER TABLE history ALTER COLUMN date DROP DEFAULT;
+ALTER TABLE history
+    ALTER COLUMN date TYPE VARCHAR(100)
+    USING TO_CHAR(date AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"');
+
+CREATE INDEX IF NOT EXISTS idx_history_user_id_date ON history(user_id, date);
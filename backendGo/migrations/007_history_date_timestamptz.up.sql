@@ -0,0 +1,33 @@
+-- history.date was a VARCHAR holding time.Now().Format(time.RFC3339) in the server's local
+-- timezone, so ordering and range filters compared text. Convert it to TIMESTAMPTZ.
+-- RFC3339 values carry their UTC offset and convert exactly; values without an offset are
+-- read in the session timezone. Values that cannot be parsed at all fall back to the
+-- earliest parsable date of their batch, or to the Unix epoch if the whole batch is unparsable.
+
+CREATE OR REPLACE FUNCTION pg_temp.history_date_to_timestamptz(value TEXT)
+RETURNS TIMESTAMPTZ AS $$
+BEGIN
+    RETURN value::TIMESTAMPTZ;
+EXCEPTION WHEN others THEN
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE history ADD COLUMN date_tz TIMESTAMPTZ;
+
+UPDATE history SET date_tz = pg_temp.history_date_to_timestamptz(date);
+
+UPDATE history h
+SET date_tz = COALESCE(
+    (SELECT MIN(b.date_tz) FROM history b WHERE b.batch_id = h.batch_id AND b.date_tz IS NOT NULL),
+    TO_TIMESTAMP(0)
+)
+WHERE h.date_tz IS NULL;
+
+DROP INDEX IF EXISTS idx_history_user_id_date;
+ALTER TABLE history DROP COLUMN date;
+ALTER TABLE history RENAME COLUMN date_tz TO date;
+ALTER TABLE history ALTER COLUMN date SET NOT NULL;
+ALTER TABLE history ALTER COLUMN date SET DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_history_user_id_date ON history(user_id, date);
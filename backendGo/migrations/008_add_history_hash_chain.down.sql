@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS history_chain_heads;
+
+DROP INDEX IF EXISTS idx_history_user_id_seq;
+
+ALTER TABLE history
+DROP COLUMN IF EXISTS hash,
+DROP COLUMN IF EXISTS prev_hash,
+DROP COLUMN IF EXISTS seq;
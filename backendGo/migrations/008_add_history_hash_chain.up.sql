@@ -0,0 +1,19 @@
+-- Tamper-evident history: every entry of a user is linked to the previous one by hash.
+-- Entries written before this migration keep seq/prev_hash/hash NULL and are reported
+-- as unchained by the verifier.
+ALTER TABLE history
+ADD COLUMN IF NOT EXISTS seq BIGINT,
+ADD COLUMN IF NOT EXISTS prev_hash VARCHAR(64),
+ADD COLUMN IF NOT EXISTS hash VARCHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_history_user_id_seq ON history(user_id, seq) WHERE seq IS NOT NULL;
+
+-- Last link of each user's chain; its row lock serializes appends.
+CREATE TABLE IF NOT EXISTS history_chain_heads (
+    user_id INTEGER PRIMARY KEY,
+    last_seq BIGINT NOT NULL DEFAULT 0,
+    last_hash VARCHAR(64) NOT NULL DEFAULT '',
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_history_chain_heads_user_id
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
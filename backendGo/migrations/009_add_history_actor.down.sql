@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_history_request_id;
+
+ALTER TABLE history
+DROP COLUMN IF EXISTS request_id,
+DROP COLUMN IF EXISTS user_agent,
+DROP COLUMN IF EXISTS client_ip,
+DROP COLUMN IF EXISTS actor_username,
+DROP COLUMN IF EXISTS actor_user_id;
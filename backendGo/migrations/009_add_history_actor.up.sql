@@ -0,0 +1,10 @@
+-- Who made each change: user_id stays the owner of the stock, while these columns
+-- record the authenticated actor and request. Older entries keep them empty.
+ALTER TABLE history
+ADD COLUMN IF NOT EXISTS actor_user_id INTEGER,
+ADD COLUMN IF NOT EXISTS actor_username VARCHAR(255) NOT NULL DEFAULT '',
+ADD COLUMN IF NOT EXISTS client_ip VARCHAR(64) NOT NULL DEFAULT '',
+ADD COLUMN IF NOT EXISTS user_agent VARCHAR(512) NOT NULL DEFAULT '',
+ADD COLUMN IF NOT EXISTS request_id VARCHAR(64) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_history_request_id ON history(request_id) WHERE request_id <> '';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS history_archived_links;
+DROP TABLE IF EXISTS history_archives;
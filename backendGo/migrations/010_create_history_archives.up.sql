@@ -0,0 +1,31 @@
+-- History older than the retention period is moved to compressed JSONL files.
+-- Each file is recorded here so it can be listed, checked and restored.
+CREATE TABLE IF NOT EXISTS history_archives (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    file_name VARCHAR(255) NOT NULL UNIQUE,
+    archived_before TIMESTAMP WITH TIME ZONE NOT NULL, -- Entries dated before this instant were archived
+    first_entry_date TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_entry_date TIMESTAMP WITH TIME ZONE NOT NULL,
+    entry_count INTEGER NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    sha256 VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    restored_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT fk_history_archives_user_id
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_history_archives_user_id ON history_archives(user_id, archived_before);
+
+-- Chain links of archived entries, so the hash chain can still be verified across archived ranges.
+CREATE TABLE IF NOT EXISTS history_archived_links (
+    user_id INTEGER NOT NULL,
+    seq BIGINT NOT NULL,
+    prev_hash VARCHAR(64) NOT NULL,
+    hash VARCHAR(64) NOT NULL,
+    archive_id BIGINT NOT NULL REFERENCES history_archives(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, seq)
+);
+
+CREATE INDEX IF NOT EXISTS idx_history_archived_links_archive_id ON history_archived_links(archive_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS nfe_imports;
+DROP TABLE IF EXISTS supplier_product_mappings;
@@ -0,0 +1,33 @@
+-- Product each supplier code of NF-e invoices is received as, remembered per supplier.
+-- A NULL unit_factor means quantities are converted by unit.
+CREATE TABLE IF NOT EXISTS supplier_product_mappings (
+    user_id INTEGER NOT NULL,
+    supplier_document VARCHAR(14) NOT NULL, -- CNPJ or CPF of the invoice issuer
+    supplier_code VARCHAR(60) NOT NULL,     -- cProd
+    product_id VARCHAR(100) NOT NULL,
+    unit_factor NUMERIC CHECK (unit_factor > 0),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, supplier_document, supplier_code),
+    CONSTRAINT fk_supplier_product_mappings_user_id
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_supplier_product_mappings_product_id
+        FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+-- Invoices whose stock was received, so an invoice is never received twice.
+CREATE TABLE IF NOT EXISTS nfe_imports (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    access_key CHAR(44) NOT NULL,
+    number VARCHAR(9) NOT NULL,
+    series VARCHAR(3) NOT NULL,
+    supplier_document VARCHAR(14) NOT NULL,
+    supplier_name VARCHAR(255) NOT NULL,
+    issued_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    batch_id VARCHAR(100) NOT NULL, -- History batch of the received lotes
+    lote_count INTEGER NOT NULL,
+    imported_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, access_key),
+    CONSTRAINT fk_nfe_imports_user_id
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
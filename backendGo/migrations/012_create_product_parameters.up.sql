@@ -0,0 +1,14 @@
+-- Per-product parameters kept apart from the stock, starting with the unit cost
+-- used to value it. A NULL unit_cost means the cost is unknown.
+CREATE TABLE IF NOT EXISTS product_parameters (
+    product_id VARCHAR(100) PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    unit_cost NUMERIC(14, 4) CHECK (unit_cost >= 0), -- Cost of one unit (L or kg)
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_product_parameters_user_id
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_product_parameters_product_id
+        FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_parameters_user_id ON product_parameters(user_id);
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS purchase_order_items;
+DROP TABLE IF EXISTS purchase_orders;
+
+ALTER TABLE product_parameters
+    DROP CONSTRAINT IF EXISTS chk_product_parameters_levels,
+    DROP COLUMN IF EXISTS preferred_supplier_document,
+    DROP COLUMN IF EXISTS max_quantity,
+    DROP COLUMN IF EXISTS min_quantity;
+
+DROP TABLE IF EXISTS suppliers;
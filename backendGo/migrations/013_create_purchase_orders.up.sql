@@ -0,0 +1,50 @@
+-- Suppliers with their lead time, identified by CNPJ/CPF like the issuers of NF-e invoices.
+CREATE TABLE IF NOT EXISTS suppliers (
+    user_id INTEGER NOT NULL,
+    document VARCHAR(14) NOT NULL, -- CNPJ or CPF
+    name VARCHAR(255) NOT NULL,
+    lead_time_days INTEGER NOT NULL DEFAULT 0 CHECK (lead_time_days >= 0), -- Days from order to receipt
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, document),
+    CONSTRAINT fk_suppliers_user_id
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Stock levels and preferred supplier used by the reorder suggestions.
+ALTER TABLE product_parameters
+    ADD COLUMN IF NOT EXISTS min_quantity NUMERIC CHECK (min_quantity >= 0),
+    ADD COLUMN IF NOT EXISTS max_quantity NUMERIC CHECK (max_quantity >= 0),
+    ADD COLUMN IF NOT EXISTS preferred_supplier_document VARCHAR(14),
+    ADD CONSTRAINT chk_product_parameters_levels CHECK (max_quantity >= min_quantity);
+
+-- Purchase orders, created as drafts from reorder suggestions.
+CREATE TABLE IF NOT EXISTS purchase_orders (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'draft',
+    supplier_document VARCHAR(14), -- NULL when the products have no preferred supplier
+    supplier_name VARCHAR(255),
+    expected_on DATE,              -- Order date plus the supplier's lead time
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_purchase_orders_user_id
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_purchase_orders_user_id ON purchase_orders(user_id);
+
+-- Items keep the product name and unit, so an order still reads the same if the product is removed.
+CREATE TABLE IF NOT EXISTS purchase_order_items (
+    id BIGSERIAL PRIMARY KEY,
+    order_id BIGINT NOT NULL,
+    product_id VARCHAR(100),
+    product_name VARCHAR(100) NOT NULL,
+    unit VARCHAR(10) NOT NULL,
+    quantity NUMERIC NOT NULL CHECK (quantity > 0),
+    unit_cost NUMERIC(14, 4),
+    CONSTRAINT fk_purchase_order_items_order_id
+        FOREIGN KEY (order_id) REFERENCES purchase_orders(id) ON DELETE CASCADE,
+    CONSTRAINT fk_purchase_order_items_product_id
+        FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_purchase_order_items_order_id ON purchase_order_items(order_id);
@@ -0,0 +1,3 @@
+-- Storage location of each product (warehouse, shelf...), printed on the inventory sheet.
+ALTER TABLE product_parameters
+    ADD COLUMN IF NOT EXISTS location VARCHAR(100);
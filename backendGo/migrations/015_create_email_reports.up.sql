@@ -0,0 +1,41 @@
+-- Recurring reports emailed to a list of recipients.
+CREATE TABLE IF NOT EXISTS email_reports (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    report_type VARCHAR(30) NOT NULL CHECK (report_type IN ('stock_summary', 'expiring_lotes', 'low_stock')),
+    recipients TEXT[] NOT NULL,
+    frequency VARCHAR(10) NOT NULL CHECK (frequency IN ('daily', 'weekly', 'monthly')),
+    weekday INTEGER NOT NULL DEFAULT 1 CHECK (weekday BETWEEN 0 AND 6),            -- 0 is Sunday; weekly reports only
+    day_of_month INTEGER NOT NULL DEFAULT 1 CHECK (day_of_month BETWEEN 1 AND 28), -- Monthly reports only
+    hour INTEGER NOT NULL DEFAULT 7 CHECK (hour BETWEEN 0 AND 23),                 -- Server time
+    days_ahead INTEGER NOT NULL DEFAULT 30 CHECK (days_ahead BETWEEN 1 AND 365),   -- Expiring lotes only
+    skip_empty BOOLEAN NOT NULL DEFAULT FALSE,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    last_run_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_email_reports_user_id
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_reports_user_id ON email_reports(user_id);
+
+-- Delivery log: one entry per attempt to send a report, kept after the report is removed.
+CREATE TABLE IF NOT EXISTS email_report_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    report_id BIGINT,
+    report_type VARCHAR(30) NOT NULL,
+    recipients TEXT[] NOT NULL,
+    triggered_by VARCHAR(10) NOT NULL, -- 'scheduled' or 'manual'
+    status VARCHAR(10) NOT NULL,       -- 'sent', 'failed' or 'skipped' (nothing to report)
+    error TEXT NOT NULL DEFAULT '',
+    row_count INTEGER NOT NULL DEFAULT 0, -- Rows of the report's table
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_email_report_deliveries_user_id
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_email_report_deliveries_report_id
+        FOREIGN KEY (report_id) REFERENCES email_reports(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_report_deliveries_user_id ON email_report_deliveries(user_id, created_at);
@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS trg_product_lots_closed_period ON product_lots;
+DROP TRIGGER IF EXISTS trg_products_closed_period ON products;
+DROP FUNCTION IF EXISTS prevent_stock_changes_in_closed_period();
+
+DROP TABLE IF EXISTS stock_closing_items;
+DROP TABLE IF EXISTS stock_closings;
+DROP FUNCTION IF EXISTS prevent_stock_closing_changes();
@@ -0,0 +1,104 @@
+-- Monthly closings: a frozen record of each product and lote, with its quantity and value,
+-- at the end of a month (or when the current month was closed early).
+CREATE TABLE IF NOT EXISTS stock_closings (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    period CHAR(7) NOT NULL,                         -- YYYY-MM
+    period_end TIMESTAMP WITH TIME ZONE NOT NULL,    -- Start of the next month; stock changes before it are refused
+    stock_at TIMESTAMP WITH TIME ZONE NOT NULL,      -- Instant of the recorded stock: the period end, or the closing time if earlier
+    triggered_by VARCHAR(10) NOT NULL,               -- 'manual' or 'scheduled'
+    closed_by VARCHAR(255) NOT NULL DEFAULT '',      -- Username of a manual closing
+    product_count INTEGER NOT NULL,
+    lote_count INTEGER NOT NULL,
+    total_value NUMERIC,                             -- Value of the products with a unit cost; NULL if none has one
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT uq_stock_closings_user_period UNIQUE (user_id, period),
+    CONSTRAINT fk_stock_closings_user_id
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- One row per product (lote_id NULL) and per lote. Names, units and unit costs are copied,
+-- so a closing reads the same after products are renamed, repriced or removed.
+CREATE TABLE IF NOT EXISTS stock_closing_items (
+    id BIGSERIAL PRIMARY KEY,
+    closing_id BIGINT NOT NULL,
+    product_id VARCHAR(100) NOT NULL,
+    product_name VARCHAR(100) NOT NULL,
+    unit VARCHAR(10) NOT NULL,
+    lote_id VARCHAR(100),
+    data_validade DATE,
+    quantity NUMERIC NOT NULL,
+    unit_cost NUMERIC(14, 4),
+    value NUMERIC,
+    CONSTRAINT fk_stock_closing_items_closing_id
+        FOREIGN KEY (closing_id) REFERENCES stock_closings(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_closing_items_closing_id ON stock_closing_items(closing_id, product_id);
+
+-- Closings are immutable. Rows only go away with their user (deletes cascaded from another table
+-- run at a trigger depth above 1).
+CREATE OR REPLACE FUNCTION prevent_stock_closing_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' AND pg_trigger_depth() > 1 THEN
+        RETURN OLD;
+    END IF;
+    RAISE EXCEPTION 'stock closings cannot be changed';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_stock_closings_immutable ON stock_closings;
+CREATE TRIGGER trg_stock_closings_immutable
+BEFORE UPDATE OR DELETE ON stock_closings
+FOR EACH ROW EXECUTE FUNCTION prevent_stock_closing_changes();
+
+DROP TRIGGER IF EXISTS trg_stock_closing_items_immutable ON stock_closing_items;
+CREATE TRIGGER trg_stock_closing_items_immutable
+BEFORE UPDATE OR DELETE ON stock_closing_items
+FOR EACH ROW EXECUTE FUNCTION prevent_stock_closing_changes();
+
+-- Products and lotes cannot change while the current month is closed (closed early): the change
+-- would be dated inside a closed period. Changes made by other triggers, such as the product
+-- quantity kept in sync with its lotes or cascaded deletes, were already checked at their origin.
+CREATE OR REPLACE FUNCTION prevent_stock_changes_in_closed_period()
+RETURNS TRIGGER AS $$
+DECLARE
+    owner INTEGER;
+    closed CHAR(7);
+BEGIN
+    IF pg_trigger_depth() > 1 THEN
+        IF TG_OP = 'DELETE' THEN
+            RETURN OLD;
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        owner := OLD.user_id;
+    ELSE
+        owner := NEW.user_id;
+    END IF;
+    SELECT period INTO closed FROM stock_closings
+    WHERE user_id = owner AND period_end > CURRENT_TIMESTAMP
+    ORDER BY period DESC LIMIT 1;
+    IF closed IS NOT NULL THEN
+        RAISE EXCEPTION 'stock period % is closed', closed USING ERRCODE = 'SC001';
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_products_closed_period ON products;
+CREATE TRIGGER trg_products_closed_period
+BEFORE INSERT OR UPDATE OR DELETE ON products
+FOR EACH ROW EXECUTE FUNCTION prevent_stock_changes_in_closed_period();
+
+DROP TRIGGER IF EXISTS trg_product_lots_closed_period ON product_lots;
+CREATE TRIGGER trg_product_lots_closed_period
+BEFORE INSERT OR UPDATE OR DELETE ON product_lots
+FOR EACH ROW EXECUTE FUNCTION prevent_stock_changes_in_closed_period();
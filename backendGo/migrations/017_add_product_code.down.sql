@@ -0,0 +1,2 @@
+ALTER TABLE product_parameters
+    DROP COLUMN IF EXISTS code;
@@ -0,0 +1,4 @@
+-- Code of each product in the company's item register (COD_ITEM of SPED Fiscal register 0200),
+-- used by the inventory register (Bloco H) export.
+ALTER TABLE product_parameters
+    ADD COLUMN IF NOT EXISTS code VARCHAR(60);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_users_email_lower;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS last_login,
+    DROP COLUMN IF EXISTS active,
+    DROP COLUMN IF EXISTS full_name,
+    DROP COLUMN IF EXISTS email;
@@ -0,0 +1,10 @@
+-- Profile and status of the users managed by the admin: email, full name, whether the user
+-- can sign in, and when they last did.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS email VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS full_name VARCHAR(200),
+    ADD COLUMN IF NOT EXISTS active BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD COLUMN IF NOT EXISTS last_login TIMESTAMP WITH TIME ZONE;
+
+-- Emails are unique regardless of case
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users(LOWER(email)) WHERE email IS NOT NULL;
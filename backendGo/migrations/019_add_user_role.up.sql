@@ -0,0 +1,8 @@
+-- Role of each user, which decides the routes the user may call. Existing users become managers,
+-- so they keep managing their stock; the admin account is promoted to admin at startup.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'manager';
+
+ALTER TABLE users
+    ALTER COLUMN role SET DEFAULT 'operator',
+    ADD CONSTRAINT chk_users_role CHECK (role IN ('admin', 'manager', 'operator', 'viewer'));
@@ -0,0 +1,37 @@
+DROP TRIGGER IF EXISTS trg_history_closed_period ON history;
+DROP FUNCTION IF EXISTS prevent_history_in_closed_period();
+
+-- Back to the guard of migration 016
+CREATE OR REPLACE FUNCTION prevent_stock_changes_in_closed_period()
+RETURNS TRIGGER AS $$
+DECLARE
+    owner INTEGER;
+    closed CHAR(7);
+BEGIN
+    IF pg_trigger_depth() > 1 THEN
+        IF TG_OP = 'DELETE' THEN
+            RETURN OLD;
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        owner := OLD.user_id;
+    ELSE
+        owner := NEW.user_id;
+    END IF;
+    SELECT period INTO closed FROM stock_closings
+    WHERE user_id = owner AND period_end > CURRENT_TIMESTAMP
+    ORDER BY period DESC LIMIT 1;
+    IF closed IS NOT NULL THEN
+        RAISE EXCEPTION 'stock period % is closed', closed USING ERRCODE = 'SC001';
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP FUNCTION IF EXISTS closed_stock_period(INTEGER, TIMESTAMPTZ);
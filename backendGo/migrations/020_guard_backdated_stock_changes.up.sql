@@ -0,0 +1,62 @@
+-- Months can only be closed once over, so a change is refused when it is dated inside a closed
+-- month rather than while the current month is closed. Products and lotes change at the time of
+-- the write; history entries carry their own date, which restored archives and account imports
+-- set in the past.
+CREATE OR REPLACE FUNCTION closed_stock_period(owner INTEGER, changed_at TIMESTAMPTZ)
+RETURNS CHAR(7) AS $$
+    SELECT period FROM stock_closings
+    WHERE user_id = owner AND period_end > changed_at
+    ORDER BY period DESC LIMIT 1;
+$$ LANGUAGE sql STABLE;
+
+-- Changes made by other triggers, such as the product quantity kept in sync with its lotes or
+-- cascaded deletes, were already checked at their origin.
+CREATE OR REPLACE FUNCTION prevent_stock_changes_in_closed_period()
+RETURNS TRIGGER AS $$
+DECLARE
+    owner INTEGER;
+    closed CHAR(7);
+BEGIN
+    IF pg_trigger_depth() > 1 THEN
+        IF TG_OP = 'DELETE' THEN
+            RETURN OLD;
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        owner := OLD.user_id;
+    ELSE
+        owner := NEW.user_id;
+    END IF;
+    closed := closed_stock_period(owner, CURRENT_TIMESTAMP);
+    IF closed IS NOT NULL THEN
+        RAISE EXCEPTION 'stock period % is closed', closed USING ERRCODE = 'SC001';
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- History entries are refused when dated inside a closed month. Archiving only deletes entries,
+-- which is allowed.
+CREATE OR REPLACE FUNCTION prevent_history_in_closed_period()
+RETURNS TRIGGER AS $$
+DECLARE
+    closed CHAR(7);
+BEGIN
+    closed := closed_stock_period(NEW.user_id, NEW.date);
+    IF closed IS NOT NULL THEN
+        RAISE EXCEPTION 'stock period % is closed', closed USING ERRCODE = 'SC001';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_history_closed_period ON history;
+CREATE TRIGGER trg_history_closed_period
+BEFORE INSERT OR UPDATE OF date, user_id ON history
+FOR EACH ROW EXECUTE FUNCTION prevent_history_in_closed_period();
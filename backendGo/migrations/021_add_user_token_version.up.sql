@@ -0,0 +1,4 @@
+-- Version of the tokens of each user, carried in the JWT and bumped when the password changes,
+-- so tokens issued before a password change stop being accepted.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS token_version INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+ALTER TABLE users
+    DROP CONSTRAINT IF EXISTS fk_users_account_id,
+    DROP COLUMN IF EXISTS account_id;
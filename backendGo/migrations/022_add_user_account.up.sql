@@ -0,0 +1,9 @@
+-- Account each user works on. Users created by an admin are members of the admin's account and
+-- read and change its products, lotes, history and settings, within what their role allows.
+-- NULL for account owners, whose data is their own; members are removed with their owner.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS account_id INTEGER;
+
+ALTER TABLE users
+    ADD CONSTRAINT fk_users_account_id
+        FOREIGN KEY (account_id) REFERENCES users(id) ON DELETE CASCADE;
@@ -0,0 +1,2 @@
+-- Filter of the history by the user who made each change
+CREATE INDEX IF NOT EXISTS idx_history_user_id_actor_user_id ON history(user_id, actor_user_id, date);
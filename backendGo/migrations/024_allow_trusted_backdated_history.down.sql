@@ -0,0 +1,12 @@
+CREATE OR REPLACE FUNCTION prevent_history_in_closed_period()
+RETURNS TRIGGER AS $$
+DECLARE
+    closed CHAR(7);
+BEGIN
+    closed := closed_stock_period(NEW.user_id, NEW.date);
+    IF closed IS NOT NULL THEN
+        RAISE EXCEPTION 'stock period % is closed', closed USING ERRCODE = 'SC001';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
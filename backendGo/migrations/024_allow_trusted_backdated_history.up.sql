@@ -0,0 +1,18 @@
+-- Restored archives and account imports bring back history dated inside closed months. Their
+-- transactions set stock.trusted_history locally, so only they pass the closed period guard.
+CREATE OR REPLACE FUNCTION prevent_history_in_closed_period()
+RETURNS TRIGGER AS $$
+DECLARE
+    closed CHAR(7);
+BEGIN
+    IF current_setting('stock.trusted_history', true) = 'on' THEN
+        RETURN NEW;
+    END IF;
+
+    closed := closed_stock_period(NEW.user_id, NEW.date);
+    IF closed IS NOT NULL THEN
+        RAISE EXCEPTION 'stock period % is closed', closed USING ERRCODE = 'SC001';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;